@@ -0,0 +1,173 @@
+// Package arbitrage finds sure bets and middles across the quotes of several bookmakers on the
+// same match.
+package arbitrage
+
+import (
+	"cmp"
+	"gotennis/format"
+	"slices"
+)
+
+// Leg is one side of a quote to back.
+type Leg struct {
+	Book   string        `json:"book"`
+	Market format.Market `json:"market"`
+	Line   float64       `json:"line"`
+	Side   format.Side   `json:"side"`
+	Odds   float64       `json:"odds"`
+	// Stake is the share of the total stake on the leg, so that every leg returns the same when it wins.
+	Stake float64 `json:"stake"`
+}
+
+// Arbitrage is a sure bet: backing both sides of a market, at the best odds of every book,
+// returns more than the total stake whatever the outcome.
+type Arbitrage struct {
+	Market format.Market `json:"market"`
+	Line   float64       `json:"line"`
+	Legs   []Leg         `json:"legs"`
+	// Profit is the guaranteed profit per unit staked.
+	Profit float64 `json:"profit"`
+}
+
+// Middle is a pair of handicap or total bets at different books and lines which both win when
+// the final game margin (AH) or total games (OU) lands between the lines.
+type Middle struct {
+	Market format.Market `json:"market"`
+	Legs   []Leg         `json:"legs"`
+	// Low and High bound the game margin of A or the total games for which both legs win.
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
+	// Probability is the simulated probability of both legs winning, in full or in half.
+	Probability float64 `json:"probability"`
+	// EV is the expected profit per unit staked.
+	EV float64 `json:"ev"`
+	// Worst is the smallest profit per unit staked over the simulated outcomes. A middle that
+	// cannot lose is also a sure bet.
+	Worst float64 `json:"worst"`
+}
+
+// FindArbitrages returns the sure bets on every market and line, best first.
+func FindArbitrages(quotes []Quote) []Arbitrage {
+	type key struct {
+		market format.Market
+		line   float64
+	}
+	var keys []key
+	bestA := make(map[key]Quote)
+	bestB := make(map[key]Quote)
+	for _, q := range quotes {
+		k := key{q.Market, q.Line}
+		a, ok := bestA[k]
+		if !ok {
+			keys = append(keys, k)
+		}
+		if !ok || q.OddsA > a.OddsA {
+			bestA[k] = q
+		}
+		if b, ok := bestB[k]; !ok || q.OddsB > b.OddsB {
+			bestB[k] = q
+		}
+	}
+
+	var out []Arbitrage
+	for _, k := range keys {
+		legs, book := backBoth(bestA[k], bestB[k])
+		if book >= 1 {
+			continue
+		}
+		out = append(out, Arbitrage{Market: k.market, Line: k.line, Legs: legs, Profit: 1/book - 1})
+	}
+	slices.SortStableFunc(out, func(x, y Arbitrage) int { return cmp.Compare(y.Profit, x.Profit) })
+	return out
+}
+
+// FindMiddles returns the middles between the handicap and total quotes of different books that
+// the simulated game margin or total games can land in, by expected value. The distributions are
+// those of the simulated match, see format.GetDistributions.
+func FindMiddles(quotes []Quote, dist format.Distributions) []Middle {
+	var out []Middle
+	for _, a := range quotes {
+		for _, b := range quotes {
+			if a.Book == b.Book || a.Market != b.Market {
+				continue
+			}
+
+			var m Middle
+			var pmf []format.ValueProbability
+			switch a.Market {
+			case format.Total:
+				// Over the low line of a and under the high line of b.
+				m = Middle{Low: a.Line, High: b.Line}
+				pmf = dist.TotalGames.PMF
+			case format.Handicap:
+				// A on the handicap of a and B on the handicap of b.
+				m = Middle{Low: -a.Line, High: -b.Line}
+				pmf = dist.GameMargin.PMF
+			case format.Moneyline, format.CorrectScore, format.Tiebreak, format.DoubleResult, format.Combo,
+				format.OddEven, format.TotalGames, format.Break, format.SetWinner:
+				continue
+			default:
+				continue
+			}
+			if m.Low >= m.High {
+				continue
+			}
+
+			m.Market = a.Market
+			m.Legs, _ = backBoth(a, b)
+			scoreMiddle(&m, pmf)
+			if m.Probability > 0 {
+				out = append(out, m)
+			}
+		}
+	}
+	slices.SortStableFunc(out, func(x, y Middle) int { return cmp.Compare(y.EV, x.EV) })
+	return out
+}
+
+// backBoth returns the legs of backing A (or over) at a and B (or under) at b with stakes that
+// return the same on either leg, together with the sum of the implied probabilities.
+func backBoth(a, b Quote) ([]Leg, float64) {
+	book := 1/a.OddsA + 1/b.OddsB
+	return []Leg{
+		{Book: a.Book, Market: a.Market, Line: a.Line, Side: format.SideA, Odds: a.OddsA, Stake: 1 / a.OddsA / book},
+		{Book: b.Book, Market: b.Market, Line: b.Line, Side: format.SideB, Odds: b.OddsB, Stake: 1 / b.OddsB / book},
+	}, book
+}
+
+// scoreMiddle sets the probability, expected value and worst case of the middle from the
+// distribution of the game margin or total games it is settled on.
+func scoreMiddle(m *Middle, pmf []format.ValueProbability) {
+	a, b := m.Legs[0], m.Legs[1]
+	for i, vp := range pmf {
+		v := float64(vp.Value)
+		// Both legs are settled on the margin of the final value over the middle's bounds.
+		payA := a.Stake * payout(a.Odds, v-m.Low, a.Line)
+		payB := b.Stake * payout(b.Odds, m.High-v, b.Line)
+		profit := payA + payB - 1
+
+		if payA > a.Stake && payB > b.Stake {
+			m.Probability += vp.Prob
+		}
+		m.EV += vp.Prob * profit
+		if i == 0 || profit < m.Worst {
+			m.Worst = profit
+		}
+	}
+}
+
+// payout returns what a unit stake at the odds returns when the bet wins by margin, half on each
+// neighbouring line for quarter lines.
+func payout(odds, margin, line float64) float64 {
+	if line*2 != float64(int(line*2)) {
+		return (payout(odds, margin-0.25, 0) + payout(odds, margin+0.25, 0)) / 2
+	}
+	switch {
+	case margin > 0:
+		return odds
+	case margin == 0:
+		return 1
+	default:
+		return 0
+	}
+}
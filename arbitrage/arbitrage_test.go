@@ -0,0 +1,87 @@
+package arbitrage
+
+import (
+	"gotennis/format"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testQuotes() []Quote {
+	return []Quote{
+		{Book: "one", Market: format.Moneyline, OddsA: 2.10, OddsB: 1.80},
+		{Book: "two", Market: format.Moneyline, OddsA: 1.95, OddsB: 2.05},
+		{Book: "one", Market: format.Total, Line: 21.5, OddsA: 1.90, OddsB: 1.90},
+		{Book: "two", Market: format.Total, Line: 23.5, OddsA: 1.90, OddsB: 1.90},
+		{Book: "one", Market: format.Handicap, Line: 2.5, OddsA: 1.90, OddsB: 1.90},
+		{Book: "two", Market: format.Handicap, Line: 1.5, OddsA: 1.90, OddsB: 1.90},
+	}
+}
+
+func TestFindArbitrages(t *testing.T) {
+	arbs := FindArbitrages(testQuotes())
+	require.Len(t, arbs, 1, "Expected only the moneyline to be a sure bet")
+
+	arb := arbs[0]
+	assert.Equal(t, format.Moneyline, arb.Market)
+	require.Len(t, arb.Legs, 2)
+	assert.Equal(t, "one", arb.Legs[0].Book)
+	assert.Equal(t, format.SideA, arb.Legs[0].Side)
+	assert.Equal(t, "two", arb.Legs[1].Book)
+	assert.Equal(t, format.SideB, arb.Legs[1].Side)
+	assert.InDelta(t, 1/(1/2.10+1/2.05)-1, arb.Profit, 1e-9)
+	assert.InDelta(t, 1, arb.Legs[0].Stake+arb.Legs[1].Stake, 1e-9)
+	assert.InDelta(t, arb.Legs[0].Stake*arb.Legs[0].Odds, arb.Legs[1].Stake*arb.Legs[1].Odds, 1e-9,
+		"Expected the same return on either leg")
+}
+
+func TestFindMiddles(t *testing.T) {
+	dist := format.Distributions{
+		TotalGames: format.Distribution{PMF: []format.ValueProbability{
+			{Value: 20, Prob: 0.3}, {Value: 22, Prob: 0.2}, {Value: 23, Prob: 0.2}, {Value: 25, Prob: 0.3},
+		}},
+		GameMargin: format.Distribution{PMF: []format.ValueProbability{
+			{Value: -2, Prob: 0.25}, {Value: 3, Prob: 0.75},
+		}},
+	}
+	middles := FindMiddles(testQuotes(), dist)
+	require.Len(t, middles, 2)
+
+	total := middles[0]
+	assert.Equal(t, format.Total, total.Market)
+	assert.InDelta(t, 21.5, total.Low, 1e-9)
+	assert.InDelta(t, 23.5, total.High, 1e-9)
+	assert.InDelta(t, 0.4, total.Probability, 1e-9)
+	assert.InDelta(t, 0.4*0.9-0.6*0.05, total.EV, 1e-9)
+	assert.InDelta(t, -0.05, total.Worst, 1e-9)
+
+	handicap := middles[1]
+	assert.Equal(t, format.Handicap, handicap.Market)
+	assert.InDelta(t, -2.5, handicap.Low, 1e-9)
+	assert.InDelta(t, -1.5, handicap.High, 1e-9)
+	assert.InDelta(t, 0.25, handicap.Probability, 1e-9)
+	assert.Equal(t, format.SideA, handicap.Legs[0].Side)
+	assert.InDelta(t, 2.5, handicap.Legs[0].Line, 1e-9)
+}
+
+func TestPayout(t *testing.T) {
+	tests := []struct {
+		name     string
+		margin   float64
+		line     float64
+		expected float64
+	}{
+		{"Win", 0.5, 22.5, 2},
+		{"Lose", -0.5, 22.5, 0},
+		{"Push", 0, 22, 1},
+		{"Quarter half loss", -0.25, 22.25, 0.5},
+		{"Quarter half win", 0.25, 22.75, 1.5},
+		{"Quarter win", 0.75, 22.25, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.expected, payout(2, tt.margin, tt.line), 1e-9)
+		})
+	}
+}
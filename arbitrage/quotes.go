@@ -0,0 +1,114 @@
+package arbitrage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gotennis/format"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// csvHeader is the header of a quotes CSV file.
+var csvHeader = []string{"book", "market", "line", "oddsA", "oddsB"}
+
+// Quote is the two-way price of one bookmaker on a market.
+type Quote struct {
+	Book   string        `json:"book"`
+	Market format.Market `json:"market"`
+	// Line is the game handicap of A for AH quotes and the total games for OU quotes. It is
+	// ignored for ML quotes.
+	Line float64 `json:"line"`
+	// OddsA and OddsB are the decimal odds of A (or over) and B (or under).
+	OddsA float64 `json:"oddsA"`
+	OddsB float64 `json:"oddsB"`
+}
+
+// LoadQuotes reads the quotes of a .json or .csv file, see ReadJSON and ReadCSV.
+func LoadQuotes(path string) ([]Quote, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ReadJSON(f)
+	case ".csv":
+		return ReadCSV(f)
+	default:
+		return nil, fmt.Errorf("unsupported quotes file %q: must be .json or .csv", path)
+	}
+}
+
+// ReadJSON reads a JSON array of quotes.
+func ReadJSON(r io.Reader) ([]Quote, error) {
+	var quotes []Quote
+	if err := json.NewDecoder(r).Decode(&quotes); err != nil {
+		return nil, fmt.Errorf("invalid quotes: %w", err)
+	}
+	for i := range quotes {
+		if err := quotes[i].normalize(); err != nil {
+			return nil, fmt.Errorf("quote %d: %w", i+1, err)
+		}
+	}
+	return quotes, nil
+}
+
+// ReadCSV reads quotes from CSV with the header book,market,line,oddsA,oddsB.
+func ReadCSV(r io.Reader) ([]Quote, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid quotes: %w", err)
+	}
+	if len(records) == 0 || strings.Join(records[0], ",") != strings.Join(csvHeader, ",") {
+		return nil, fmt.Errorf("invalid quotes: expected the header %s", strings.Join(csvHeader, ","))
+	}
+
+	quotes := make([]Quote, 0, len(records)-1)
+	for i, rec := range records[1:] {
+		q := Quote{Book: rec[0], Market: format.Market(strings.ToUpper(rec[1]))}
+		var errLine, errA, errB error
+		if rec[2] != "" {
+			q.Line, errLine = strconv.ParseFloat(rec[2], 64)
+		}
+		q.OddsA, errA = strconv.ParseFloat(rec[3], 64)
+		q.OddsB, errB = strconv.ParseFloat(rec[4], 64)
+		if err := errors.Join(errLine, errA, errB); err != nil {
+			return nil, fmt.Errorf("quote %d: %w", i+1, err)
+		}
+		if err := q.normalize(); err != nil {
+			return nil, fmt.Errorf("quote %d: %w", i+1, err)
+		}
+		quotes = append(quotes, q)
+	}
+	return quotes, nil
+}
+
+// normalize checks that the quote is on a supported market with a line in steps of 0.25 and odds
+// above 1, and clears the line of moneyline quotes.
+func (q *Quote) normalize() error {
+	switch q.Market {
+	case format.Moneyline:
+		q.Line = 0
+	case format.Handicap, format.Total:
+		if math.IsNaN(q.Line) || math.IsInf(q.Line, 0) || q.Line*4 != math.Trunc(q.Line*4) {
+			return fmt.Errorf("invalid line %v: must be a multiple of 0.25", q.Line)
+		}
+	case format.CorrectScore, format.Tiebreak, format.DoubleResult, format.Combo, format.OddEven,
+		format.TotalGames, format.Break, format.SetWinner:
+		return fmt.Errorf("unsupported market %q: must be ML, AH or OU", q.Market)
+	default:
+		return fmt.Errorf("unsupported market %q: must be ML, AH or OU", q.Market)
+	}
+	if !(q.OddsA > 1) || !(q.OddsB > 1) || math.IsInf(q.OddsA, 0) || math.IsInf(q.OddsB, 0) {
+		return errors.New("odds must be decimal odds greater than 1")
+	}
+	return nil
+}
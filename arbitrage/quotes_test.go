@@ -0,0 +1,71 @@
+package arbitrage
+
+import (
+	"gotennis/format"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCSV(t *testing.T) {
+	quotes, err := ReadCSV(strings.NewReader("book,market,line,oddsA,oddsB\n" +
+		"one,ML,,2.10,1.80\n" +
+		"two,ou,22.5,1.85,1.95\n" +
+		"two,AH,-3.25,1.9,1.9\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []Quote{
+		{Book: "one", Market: format.Moneyline, OddsA: 2.1, OddsB: 1.8},
+		{Book: "two", Market: format.Total, Line: 22.5, OddsA: 1.85, OddsB: 1.95},
+		{Book: "two", Market: format.Handicap, Line: -3.25, OddsA: 1.9, OddsB: 1.9},
+	}, quotes)
+
+	for name, input := range map[string]string{
+		"Missing header": "one,ML,,2.10,1.80\n",
+		"Invalid odds":   "book,market,line,oddsA,oddsB\none,ML,,1.0,1.80\n",
+		"Invalid line":   "book,market,line,oddsA,oddsB\none,OU,22.1,1.9,1.9\n",
+		"Unknown market": "book,market,line,oddsA,oddsB\none,CS,,1.9,1.9\n",
+		"Not a number":   "book,market,line,oddsA,oddsB\none,OU,x,1.9,1.9\n",
+	} {
+		_, err := ReadCSV(strings.NewReader(input))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	quotes, err := ReadJSON(strings.NewReader(
+		`[{"book":"one","market":"ML","line":1.5,"oddsA":2.1,"oddsB":1.8}]`,
+	))
+	require.NoError(t, err)
+	assert.Equal(t, []Quote{{Book: "one", Market: format.Moneyline, OddsA: 2.1, OddsB: 1.8}}, quotes,
+		"Expected the line of a moneyline quote to be cleared")
+
+	_, err = ReadJSON(strings.NewReader(`[{"book":"one","market":"OU","line":22.5,"oddsA":0.5,"oddsB":1.8}]`))
+	assert.Error(t, err)
+	_, err = ReadJSON(strings.NewReader(`{"book":"one"}`))
+	assert.Error(t, err)
+}
+
+func TestLoadQuotes(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "quotes.csv")
+	require.NoError(t, os.WriteFile(csvPath, []byte("book,market,line,oddsA,oddsB\none,ML,,2.1,1.8\n"), 0o600))
+	jsonPath := filepath.Join(dir, "quotes.json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(`[{"book":"one","market":"ML","oddsA":2.1,"oddsB":1.8}]`), 0o600))
+	txtPath := filepath.Join(dir, "quotes.txt")
+	require.NoError(t, os.WriteFile(txtPath, nil, 0o600))
+
+	fromCSV, err := LoadQuotes(csvPath)
+	require.NoError(t, err)
+	fromJSON, err := LoadQuotes(jsonPath)
+	require.NoError(t, err)
+	assert.Equal(t, fromCSV, fromJSON)
+
+	_, err = LoadQuotes(txtPath)
+	assert.Error(t, err, "Expected error for an unsupported file type")
+	_, err = LoadQuotes(filepath.Join(dir, "missing.csv"))
+	assert.Error(t, err, "Expected error for a missing file")
+}
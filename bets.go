@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"gotennis/format"
+	"gotennis/ledger"
+	"gotennis/settlement"
+	"gotennis/sim"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// PlaceBetRequest is a bet to record together with the inputs of the model price at the time.
+type PlaceBetRequest struct {
+	Match       string  `json:"match"`
+	P1          float64 `json:"p1"`
+	P2          float64 `json:"p2"`
+	BestOf      int     `json:"bestof"`
+	Simulations int     `json:"simulations"`
+	// Market, Line, Side and Odds are as for the offers of /value.
+	Market string      `json:"market"`
+	Line   string      `json:"line"`
+	Side   format.Side `json:"side"`
+	Odds   float64     `json:"odds"`
+	Stake  float64     `json:"stake"`
+}
+
+// SettleBetsRequest is the final score of a match to settle its open bets with.
+type SettleBetsRequest struct {
+	Match string `json:"match"`
+	// Score is the score in games of every set, e.g. "6-4 3-6 7-6".
+	Score   string `json:"score"`
+	Retired string `json:"retired,omitempty"`
+	// Retirement is the rule for retired matches, void (default) or settle.
+	Retirement    format.RetirementRule `json:"retirement,omitempty"`
+	CompletedSets bool                  `json:"completedSets,omitempty"`
+}
+
+// ClosingOddsRequest records the closing odds of a bet.
+type ClosingOddsRequest struct {
+	ID   int     `json:"id"`
+	Odds float64 `json:"odds"`
+}
+
+// lazyLedger opens the bet ledger on first use, so that a ledger file that cannot be read only
+// takes down the bet endpoints. Opening is retried on every request until it succeeds.
+type lazyLedger struct {
+	mu     sync.Mutex
+	path   string
+	ledger *ledger.Ledger
+}
+
+// handle serves the handler built on the ledger, or 503 Service Unavailable when the ledger
+// cannot be opened.
+func (ll *lazyLedger) handle(h func(l *ledger.Ledger) http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, err := ll.open()
+		if err != nil {
+			http.Error(w, "Service Unavailable: bet ledger: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		h(l).ServeHTTP(w, r)
+	}
+}
+
+func (ll *lazyLedger) open() (*ledger.Ledger, error) {
+	ll.mu.Lock()
+	defer ll.mu.Unlock()
+	if ll.ledger == nil {
+		l, err := ledger.Open(ll.path)
+		if err != nil {
+			return nil, err
+		}
+		ll.ledger = l
+	}
+	return ll.ledger, nil
+}
+
+// placeBet prices the bet with the model and records it in the ledger.
+func placeBet(l *ledger.Ledger, req PlaceBetRequest) (ledger.Bet, error) {
+	bet := ledger.Bet{
+		Match:  req.Match,
+		BestOf: req.BestOf,
+		Market: req.Market,
+		Line:   req.Line,
+		Side:   req.Side,
+		Odds:   req.Odds,
+		Stake:  req.Stake,
+	}
+	if err := bet.Validate(); err != nil {
+		return ledger.Bet{}, err
+	}
+	if err := validateInputs(req.P1, req.P2, req.BestOf, nil, nil, nil); err != nil {
+		return ledger.Bet{}, err
+	}
+	if req.Simulations <= 0 {
+		req.Simulations = 1000000
+	}
+
+	cfg := format.MarketConfig{
+		BestOf: req.BestOf,
+		Lines:  format.LineOptions{Type: format.QuarterLines},
+		P1:     req.P1,
+		P2:     req.P2,
+	}
+	cfg.Lines.AddLine(req.Market, req.Line)
+	sel, err := registry.SelectMarkets([]string{req.Market})
+	if err != nil {
+		return ledger.Bet{}, err
+	}
+	matches, err := sim.SimulateMatchWithOptions(
+		req.P1,
+		req.P2,
+		req.BestOf,
+		sim.Options{Simulations: req.Simulations, RecordGames: sel.NeedsGames()},
+	)
+	if err != nil {
+		return ledger.Bet{}, err
+	}
+	values, err := format.GetValues(
+		registry.Probabilities(deriveProbabilities(matches, cfg, sel)),
+		[]format.Offer{{Market: req.Market, Line: req.Line, Side: req.Side, Odds: req.Odds}},
+		format.StakeConfig{KellyFraction: 1},
+	)
+	if err != nil {
+		return ledger.Bet{}, err
+	}
+
+	bet.Type = values[0].Type
+	bet.ModelProbability = values[0].Probability
+	bet.ModelOdds = values[0].FairOdds
+	return l.Place(bet)
+}
+
+// settleBets settles the open bets of a match from its final score.
+func settleBets(l *ledger.Ledger, req SettleBetsRequest) ([]ledger.Bet, error) {
+	score, err := settlement.ParseScore(req.Score, req.Retired)
+	if err != nil {
+		return nil, err
+	}
+	rules := settlement.Rules{Retirement: format.RetirementVoid, CompletedSets: req.CompletedSets}
+	if req.Retirement != "" {
+		rules.Retirement = req.Retirement
+	}
+	return l.Settle(registry, req.Match, score, rules)
+}
+
+// betsHandler lists the bets of the ledger on GET and places a bet, see PlaceBetRequest, on POST.
+func betsHandler(l *ledger.Ledger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			bets, err := l.Bets()
+			writeLedgerResponse(w, bets, err, http.StatusInternalServerError)
+		case http.MethodPost:
+			var req PlaceBetRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid bet: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			bet, err := placeBet(l, req)
+			writeLedgerResponse(w, bet, err, http.StatusBadRequest)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// settleBetsHandler settles the open bets of a match, see SettleBetsRequest.
+func settleBetsHandler(l *ledger.Ledger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req SettleBetsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid settlement: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		bets, err := settleBets(l, req)
+		writeLedgerResponse(w, bets, err, http.StatusBadRequest)
+	}
+}
+
+// closingOddsHandler records the closing odds of a bet, see ClosingOddsRequest.
+func closingOddsHandler(l *ledger.Ledger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req ClosingOddsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid closing odds: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		bet, err := l.SetClosingOdds(req.ID, req.Odds)
+		writeLedgerResponse(w, bet, err, http.StatusBadRequest)
+	}
+}
+
+// reportHandler reports the profit and loss of the ledger overall and by market type.
+func reportHandler(l *ledger.Ledger) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		bets, err := l.Bets()
+		if err != nil {
+			writeLedgerResponse(w, nil, err, http.StatusInternalServerError)
+			return
+		}
+		writeLedgerResponse(w, ledger.GetReport(bets), nil, http.StatusOK)
+	}
+}
+
+// writeLedgerResponse writes v as JSON, or err with the given status.
+func writeLedgerResponse(w http.ResponseWriter, v any, err error, status int) {
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// ledgerPath returns the ledger file named by GOTENNIS_LEDGER, ledger.json by default.
+func ledgerPath() string {
+	if path := os.Getenv("GOTENNIS_LEDGER"); path != "" {
+		return path
+	}
+	return "ledger.json"
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"gotennis/format"
+	"gotennis/ledger"
+	"gotennis/settlement"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveLedger(t *testing.T, h http.HandlerFunc, method, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, "/bets", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestBetsHandlers(t *testing.T) {
+	l, err := ledger.Open(filepath.Join(t.TempDir(), "ledger.json"))
+	require.NoError(t, err)
+	place := `{"match":"m1","p1":0.65,"p2":0.6,"bestof":3,"simulations":2000,` +
+		`"market":"%s","line":"%s","side":"%s","odds":%s,"stake":10}`
+
+	for _, tt := range []struct {
+		name, market, line, side, odds string
+		expectedStatus                 int
+		expectedType                   format.Market
+	}{
+		{"Moneyline", "Moneyline", "ml", "A", "1.9", http.StatusOK, format.Moneyline},
+		{"Quoted total", "GameOU", "24.75", "A", "1.9", http.StatusOK, format.Total},
+		{"Unknown market", "Nope", "ml", "A", "1.9", http.StatusBadRequest, ""},
+		{"Invalid side", "Moneyline", "ml", "C", "1.9", http.StatusBadRequest, ""},
+		{"Invalid odds", "Moneyline", "ml", "A", "0.5", http.StatusBadRequest, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			body := fmt.Sprintf(place, tt.market, tt.line, tt.side, tt.odds)
+			rr := serveLedger(t, betsHandler(l), http.MethodPost, body)
+			require.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			var bet ledger.Bet
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &bet), "Failed to parse JSON response")
+			assert.Equal(t, tt.expectedType, bet.Type)
+			assert.Positive(t, bet.ModelProbability)
+			assert.Greater(t, bet.ModelOdds, 1.0)
+		})
+	}
+
+	rr := serveLedger(t, betsHandler(l), http.MethodGet, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var bets []ledger.Bet
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &bets), "Failed to parse JSON response")
+	require.Len(t, bets, 2)
+
+	rr = serveLedger(t, closingOddsHandler(l), http.MethodPost, `{"id":1,"odds":1.8}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	rr = serveLedger(t, closingOddsHandler(l), http.MethodPost, `{"id":9,"odds":1.8}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = serveLedger(t, settleBetsHandler(l), http.MethodPost, `{"match":"m1","score":"6-4 3-6 6-0"}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &bets), "Failed to parse JSON response")
+	require.Len(t, bets, 2)
+	assert.Equal(t, settlement.Win, bets[0].Outcome)
+	assert.Equal(t, settlement.HalfWin, bets[1].Outcome)
+
+	for name, body := range map[string]string{
+		"Invalid score":   `{"match":"m1","score":"6-x"}`,
+		"No open bets":    `{"match":"m1","score":"6-4 6-4"}`,
+		"Malformed input": `{`,
+	} {
+		rr := serveLedger(t, settleBetsHandler(l), http.MethodPost, body)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, name)
+	}
+	rr = serveLedger(t, settleBetsHandler(l), http.MethodGet, "")
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+
+	rr = serveLedger(t, reportHandler(l), http.MethodGet, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var report ledger.Report
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report), "Failed to parse JSON response")
+	assert.Equal(t, 2, report.Total.Settled)
+	assert.InDelta(t, 13.5, report.Total.Profit, 1e-9)
+	assert.InDelta(t, 1.9/1.8-1, report.ByType[format.Moneyline].CLV, 1e-9)
+}
+
+func TestLazyLedger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.json")
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+	bets := &lazyLedger{path: path}
+
+	rr := serveLedger(t, bets.handle(betsHandler), http.MethodGet, "")
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code, "Expected the bets to be unavailable")
+	rr = serveLedger(t, bets.handle(reportHandler), http.MethodGet, "")
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code, "Expected the report to be unavailable")
+
+	require.NoError(t, os.Remove(path))
+	rr = serveLedger(t, bets.handle(betsHandler), http.MethodGet, "")
+	assert.Equal(t, http.StatusOK, rr.Code, "Expected the ledger to open once the file is fixed")
+}
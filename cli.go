@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"gotennis/arbitrage"
+	"gotennis/format"
+	"gotennis/ledger"
+	"gotennis/sim"
+	"io"
+	"strings"
+)
+
+// ArbitrageReport is the output of the arbitrage command.
+type ArbitrageReport struct {
+	Arbitrages []arbitrage.Arbitrage `json:"arbitrages"`
+	Middles    []arbitrage.Middle    `json:"middles"`
+}
+
+// runCommand runs the subcommand named by the first argument. It reports false when there is no
+// such subcommand, in which case the HTTP server is started instead.
+func runCommand(args []string, out io.Writer) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+	switch args[0] {
+	case "arbitrage":
+		return true, runArbitrage(args[1:], out)
+	case "ledger":
+		return true, runLedger(args[1:], out)
+	default:
+		return false, nil
+	}
+}
+
+// runArbitrage reads the quotes of several books from a JSON or CSV file and writes the sure bets
+// and middles among them as JSON. Middles are scored on a simulation of the match.
+func runArbitrage(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("arbitrage", flag.ContinueOnError)
+	fs.SetOutput(out)
+	quotesPath := fs.String("quotes", "", "JSON or CSV file of quotes (required)")
+	p1 := fs.Float64("p1", 0, "probability of player 1 winning a point on serve (required)")
+	p2 := fs.Float64("p2", 0, "probability of player 2 winning a point on serve (required)")
+	bestof := fs.Int("bestof", 3, "number of sets, 3 or 5")
+	simulations := fs.Int("simulations", 1000000, "number of simulations")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *quotesPath == "" {
+		return errors.New("the quotes file is required")
+	}
+	if err := validateInputs(*p1, *p2, *bestof, nil, nil, nil); err != nil {
+		return err
+	}
+	if *simulations <= 0 {
+		return errors.New("simulations must be positive")
+	}
+
+	quotes, err := arbitrage.LoadQuotes(*quotesPath)
+	if err != nil {
+		return err
+	}
+	matches, err := sim.SimulateMatchWithOptions(*p1, *p2, *bestof, sim.Options{Simulations: *simulations})
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(ArbitrageReport{
+		Arbitrages: arbitrage.FindArbitrages(quotes),
+		Middles:    arbitrage.FindMiddles(quotes, format.GetDistributions(matches)),
+	})
+}
+
+// runLedger runs an action on the bet ledger: place, settle, closing, list or report. The ledger
+// file is named by the -ledger flag of every action, or by GOTENNIS_LEDGER.
+func runLedger(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("expected a ledger action: place, settle, closing, list or report")
+	}
+	fs := flag.NewFlagSet("ledger "+args[0], flag.ContinueOnError)
+	fs.SetOutput(out)
+	path := fs.String("ledger", ledgerPath(), "ledger file")
+
+	var run func(l *ledger.Ledger) (any, error)
+	switch args[0] {
+	case "place":
+		var req PlaceBetRequest
+		fs.StringVar(&req.Match, "match", "", "match identifier (required)")
+		fs.Float64Var(&req.P1, "p1", 0, "probability of player 1 winning a point on serve (required)")
+		fs.Float64Var(&req.P2, "p2", 0, "probability of player 2 winning a point on serve (required)")
+		fs.IntVar(&req.BestOf, "bestof", 3, "number of sets, 3 or 5")
+		fs.IntVar(&req.Simulations, "simulations", 1000000, "number of simulations")
+		fs.StringVar(&req.Market, "market", "", "market name, e.g. Moneyline or GameOU (required)")
+		fs.StringVar(&req.Line, "line", "ml", "line of the market")
+		side := fs.String("side", "A", "side of the market, A or B")
+		fs.Float64Var(&req.Odds, "odds", 0, "decimal odds taken (required)")
+		fs.Float64Var(&req.Stake, "stake", 0, "stake (required)")
+		run = func(l *ledger.Ledger) (any, error) {
+			req.Side = format.Side(strings.ToUpper(*side))
+			return placeBet(l, req)
+		}
+	case "settle":
+		var req SettleBetsRequest
+		fs.StringVar(&req.Match, "match", "", "match identifier (required)")
+		fs.StringVar(&req.Score, "score", "", "games of every set, e.g. \"6-4 3-6 7-6\"")
+		fs.StringVar(&req.Retired, "retired", "", "player that retired, A or B")
+		retirement := fs.String("retirement", string(format.RetirementVoid), "retirement rule, void or settle")
+		fs.BoolVar(&req.CompletedSets, "completed-sets", false, "let bets on completed sets stand")
+		run = func(l *ledger.Ledger) (any, error) {
+			req.Retirement = format.RetirementRule(*retirement)
+			return settleBets(l, req)
+		}
+	case "closing":
+		id := fs.Int("id", 0, "bet ID (required)")
+		odds := fs.Float64("odds", 0, "closing decimal odds (required)")
+		run = func(l *ledger.Ledger) (any, error) { return l.SetClosingOdds(*id, *odds) }
+	case "list":
+		run = func(l *ledger.Ledger) (any, error) { return l.Bets() }
+	case "report":
+		run = func(l *ledger.Ledger) (any, error) {
+			bets, err := l.Bets()
+			return ledger.GetReport(bets), err
+		}
+	default:
+		return fmt.Errorf("unknown ledger action %q: expected place, settle, closing, list or report", args[0])
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	l, err := ledger.Open(*path)
+	if err != nil {
+		return err
+	}
+	v, err := run(l)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"gotennis/format"
+	"gotennis/ledger"
+	"gotennis/settlement"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCommand(t *testing.T) {
+	ok, err := runCommand(nil, &bytes.Buffer{})
+	assert.False(t, ok, "Expected the server without arguments")
+	require.NoError(t, err)
+
+	ok, err = runCommand([]string{"serve"}, &bytes.Buffer{})
+	assert.False(t, ok, "Expected the server for an unknown command")
+	require.NoError(t, err)
+}
+
+func TestRunArbitrage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotes.csv")
+	require.NoError(t, os.WriteFile(path, []byte("book,market,line,oddsA,oddsB\n"+
+		"one,ML,,1.60,2.70\n"+
+		"two,ML,,1.45,2.75\n"+
+		"one,OU,21.5,1.90,1.90\n"+
+		"two,OU,23.5,1.90,1.90\n"), 0o600))
+
+	var out bytes.Buffer
+	ok, err := runCommand(
+		[]string{"arbitrage", "-quotes", path, "-p1", "0.65", "-p2", "0.6", "-simulations", "2000"},
+		&out,
+	)
+	require.True(t, ok)
+	require.NoError(t, err)
+
+	var report ArbitrageReport
+	require.NoError(t, json.Unmarshal(out.Bytes(), &report), "Failed to parse JSON output")
+	require.Len(t, report.Arbitrages, 1)
+	assert.Positive(t, report.Arbitrages[0].Profit)
+	require.Len(t, report.Middles, 1)
+	assert.Positive(t, report.Middles[0].Probability)
+
+	for name, args := range map[string][]string{
+		"Missing quotes": {"-p1", "0.65", "-p2", "0.6"},
+		"Invalid bestof": {"-quotes", path, "-p1", "0.65", "-p2", "0.6", "-bestof", "4"},
+		"Missing file":   {"-quotes", path + ".json", "-p1", "0.65", "-p2", "0.6"},
+		"Unknown flag":   {"-quotes", path, "-odds", "2"},
+	} {
+		_, err := runCommand(append([]string{"arbitrage"}, args...), &bytes.Buffer{})
+		assert.Error(t, err, name)
+	}
+}
+
+func TestRunLedger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.json")
+	run := func(args ...string) (bytes.Buffer, error) {
+		var out bytes.Buffer
+		ok, err := runCommand(append(append([]string{"ledger"}, args...), "-ledger", path), &out)
+		require.True(t, ok)
+		return out, err
+	}
+
+	out, err := run("place", "-match", "m1", "-p1", "0.65", "-p2", "0.6", "-simulations", "2000",
+		"-market", "Moneyline", "-side", "b", "-odds", "3", "-stake", "10")
+	require.NoError(t, err)
+	var bet ledger.Bet
+	require.NoError(t, json.Unmarshal(out.Bytes(), &bet), "Failed to parse JSON output")
+	assert.Equal(t, format.SideB, bet.Side)
+	assert.Equal(t, format.Moneyline, bet.Type)
+
+	_, err = run("closing", "-id", "1", "-odds", "2.5")
+	require.NoError(t, err)
+	_, err = run("settle", "-match", "m1", "-score", "6-4 6-4")
+	require.NoError(t, err)
+
+	out, err = run("list")
+	require.NoError(t, err)
+	var bets []ledger.Bet
+	require.NoError(t, json.Unmarshal(out.Bytes(), &bets), "Failed to parse JSON output")
+	require.Len(t, bets, 1)
+	assert.Equal(t, settlement.Lose, bets[0].Outcome)
+
+	out, err = run("report")
+	require.NoError(t, err)
+	var report ledger.Report
+	require.NoError(t, json.Unmarshal(out.Bytes(), &report), "Failed to parse JSON output")
+	assert.InDelta(t, -10, report.Total.Profit, 1e-9)
+	assert.InDelta(t, 0.2, report.Total.CLV, 1e-9)
+
+	for name, args := range map[string][]string{
+		"Missing action":  nil,
+		"Unknown action":  {"cancel"},
+		"Invalid bet":     {"place", "-match", "m1", "-p1", "0.65", "-p2", "0.6", "-market", "Moneyline"},
+		"Unknown flag":    {"list", "-odds", "2"},
+		"No open bets":    {"settle", "-match", "m1", "-score", "6-4 6-4"},
+		"Invalid closing": {"closing", "-id", "1", "-odds", "1"},
+	} {
+		var err error
+		if args == nil {
+			_, err = runCommand([]string{"ledger"}, &bytes.Buffer{})
+		} else {
+			_, err = run(args...)
+		}
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,238 @@
+package format
+
+import (
+	"fmt"
+	"gotennis/sim"
+	"math"
+	"strconv"
+)
+
+// BREAK_SPREAD is how far break total lines reach either side of the expected number of breaks.
+const BREAK_SPREAD float64 = 3
+
+// BreakMarkets holds the break of serve markets of a match. They need the games recorded by the
+// simulation (sim.Options.RecordGames) and are empty otherwise. Tiebreaks are not breaks of serve.
+type BreakMarkets struct {
+	// TotalBreaks are over/under lines on the number of breaks in the match.
+	TotalBreaks []Probability `json:"TotalBreaks"`
+	// BreaksByA and BreaksByB are over/under lines on the number of times each player breaks serve.
+	BreaksByA []Probability `json:"BreaksByA"`
+	BreaksByB []Probability `json:"BreaksByB"`
+	// BrokenInSet1 is the probability of each player being broken in the first set.
+	BrokenInSet1 []Probability `json:"BrokenInSet1"`
+	// FirstBreakGame is the probability of the first break of the first set coming in each game,
+	// or of there being none.
+	FirstBreakGame []Probability `json:"FirstBreakGame"`
+}
+
+// PricedBreakMarkets is a BreakMarkets with every probability quoted as odds.
+type PricedBreakMarkets struct {
+	TotalBreaks    []Price `json:"TotalBreaks"`
+	BreaksByA      []Price `json:"BreaksByA"`
+	BreaksByB      []Price `json:"BreaksByB"`
+	BrokenInSet1   []Price `json:"BrokenInSet1"`
+	FirstBreakGame []Price `json:"FirstBreakGame"`
+}
+
+// breakCount holds the breaks of a single match.
+type breakCount struct {
+	byA, byB int
+	// set1A and set1B report whether A and B were broken in the first set.
+	set1A, set1B bool
+	// firstSet1 is the game number of the first break of the first set, 0 if there was none.
+	firstSet1 int
+}
+
+// GetBreakMarkets calculates the break of serve markets from the games recorded in each match.
+func GetBreakMarkets(results []sim.SimulatedMatch) BreakMarkets {
+	return getBreakMarkets(results, nil)
+}
+
+// getBreakMarkets calculates the break markets with the extra lines of the break totals, keyed as
+// LineOptions.Extra.
+func getBreakMarkets(results []sim.SimulatedMatch, extra map[string][]float64) BreakMarkets {
+	var res BreakMarkets
+	counts := make([]breakCount, 0, len(results))
+	for _, m := range results {
+		c, ok := countBreaks(m)
+		if !ok {
+			return res
+		}
+		counts = append(counts, c)
+	}
+	if len(counts) == 0 {
+		return res
+	}
+
+	res.TotalBreaks = getBreakTotals(counts, extra["Breaks.TotalBreaks"], func(c breakCount) int {
+		return c.byA + c.byB
+	})
+	res.BreaksByA = getBreakTotals(counts, extra["Breaks.BreaksByA"], func(c breakCount) int { return c.byA })
+	res.BreaksByB = getBreakTotals(counts, extra["Breaks.BreaksByB"], func(c breakCount) int { return c.byB })
+	res.BrokenInSet1 = []Probability{
+		getBreakProbability(counts, "A", func(c breakCount) bool { return c.set1A }),
+		getBreakProbability(counts, "B", func(c breakCount) bool { return c.set1B }),
+	}
+
+	for game := 1; game <= 12; game++ {
+		res.FirstBreakGame = append(res.FirstBreakGame, getBreakProbability(
+			counts,
+			strconv.Itoa(game),
+			func(c breakCount) bool { return c.firstSet1 == game },
+		))
+	}
+	res.FirstBreakGame = append(res.FirstBreakGame, getBreakProbability(
+		counts,
+		"none",
+		func(c breakCount) bool { return c.firstSet1 == 0 },
+	))
+	return res
+}
+
+// countBreaks counts the breaks of serve in a match. It returns false if the games of a set were
+// not recorded.
+func countBreaks(m sim.SimulatedMatch) (breakCount, bool) {
+	var c breakCount
+	for i, set := range m.SetResults {
+		if set.Games == nil && set.AGames+set.BGames > 0 {
+			return c, false
+		}
+		for j, g := range set.Games {
+			if set.Tiebreak && j == len(set.Games)-1 {
+				break
+			}
+			brokenA := g.ServingA && g.B == 1
+			brokenB := !g.ServingA && g.A == 1
+			if brokenB {
+				c.byA++
+			}
+			if brokenA {
+				c.byB++
+			}
+			if i == 0 {
+				c.set1A = c.set1A || brokenA
+				c.set1B = c.set1B || brokenB
+				if c.firstSet1 == 0 && (brokenA || brokenB) {
+					c.firstSet1 = j + 1
+				}
+			}
+		}
+	}
+	return c, true
+}
+
+// getBreakTotals calculates the over/under lines on the breaks around their expected number and
+// on the extra lines.
+func getBreakTotals(counts []breakCount, extra []float64, breaks func(breakCount) int) []Probability {
+	sum := 0
+	for _, c := range counts {
+		sum += breaks(c)
+	}
+	expected := math.Floor(float64(sum) / float64(len(counts)))
+
+	var lines []float64
+	for i := math.Max(0.5, expected-BREAK_SPREAD+0.5); i <= expected+BREAK_SPREAD+0.5; i++ {
+		lines = append(lines, i)
+	}
+
+	var out []Probability
+	for _, i := range withExtraLines(lines, extra) {
+		n := 0
+		for _, c := range counts {
+			if float64(breaks(c)) > i {
+				n++
+			}
+		}
+		out = append(out, Probability{
+			Market: Total,
+			Line:   fmt.Sprintf("%.1f", i),
+			ProbA:  float64(n) / float64(len(counts)),
+			ProbB:  1 - float64(n)/float64(len(counts)),
+		})
+	}
+	return out
+}
+
+func getBreakProbability(counts []breakCount, line string, yes func(breakCount) bool) Probability {
+	n := 0
+	for _, c := range counts {
+		if yes(c) {
+			n++
+		}
+	}
+
+	return Probability{
+		Market: Break,
+		Line:   line,
+		ProbA:  float64(n) / float64(len(counts)),
+		ProbB:  1 - float64(n)/float64(len(counts)),
+	}
+}
+
+// Price quotes every break market as odds.
+func (m BreakMarkets) Price(cfg OddsConfig) (PricedBreakMarkets, error) {
+	var priced PricedBreakMarkets
+	var err error
+
+	if priced.TotalBreaks, err = GetPrices(m.TotalBreaks, cfg); err != nil {
+		return priced, err
+	}
+	if priced.BreaksByA, err = GetPrices(m.BreaksByA, cfg); err != nil {
+		return priced, err
+	}
+	if priced.BreaksByB, err = GetPrices(m.BreaksByB, cfg); err != nil {
+		return priced, err
+	}
+	if priced.BrokenInSet1, err = GetPrices(m.BrokenInSet1, cfg); err != nil {
+		return priced, err
+	}
+	if priced.FirstBreakGame, err = GetPrices(m.FirstBreakGame, cfg); err != nil {
+		return priced, err
+	}
+	return priced, nil
+}
+
+// breakFamilies returns the market family of the break markets, and the analytic hold
+// probabilities of each player, which are returned as they are when pricing.
+func breakFamilies() []MarketFamily {
+	return []MarketFamily{
+		marketFamily[BreakMarkets]{
+			name:  "Breaks",
+			games: true,
+			derive: func(results []sim.SimulatedMatch, cfg MarketConfig) BreakMarkets {
+				return getBreakMarkets(results, cfg.Lines.Extra)
+			},
+			price: func(m BreakMarkets, odds OddsConfig) (any, error) {
+				return m.Price(odds)
+			},
+			lines: func(m BreakMarkets) map[string][]Probability {
+				return map[string][]Probability{
+					"Breaks.TotalBreaks": m.TotalBreaks,
+					"Breaks.BreaksByA":   m.BreaksByA,
+					"Breaks.BreaksByB":   m.BreaksByB,
+				}
+			},
+			probs: func(m BreakMarkets) map[string][]Probability {
+				return map[string][]Probability{
+					"Breaks.TotalBreaks":    m.TotalBreaks,
+					"Breaks.BreaksByA":      m.BreaksByA,
+					"Breaks.BreaksByB":      m.BreaksByB,
+					"Breaks.BrokenInSet1":   m.BrokenInSet1,
+					"Breaks.FirstBreakGame": m.FirstBreakGame,
+				}
+			},
+		},
+		marketFamily[float64]{
+			name: "HoldA",
+			derive: func(_ []sim.SimulatedMatch, cfg MarketConfig) float64 {
+				return sim.HoldProbability(cfg.P1)
+			},
+		},
+		marketFamily[float64]{
+			name: "HoldB",
+			derive: func(_ []sim.SimulatedMatch, cfg MarketConfig) float64 {
+				return sim.HoldProbability(cfg.P2)
+			},
+		},
+	}
+}
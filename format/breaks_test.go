@@ -0,0 +1,122 @@
+package format
+
+import (
+	"gotennis/sim"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordedSet builds a set from the winners of its games, A serving the odd games.
+func recordedSet(winners string) sim.SimulatedSet {
+	var set sim.SimulatedSet
+	for i, w := range winners {
+		g := sim.SimResult{ServingA: i%2 == 0}
+		if w == 'A' {
+			g.A = 1
+			set.AGames++
+		} else {
+			g.B = 1
+			set.BGames++
+		}
+		set.Games = append(set.Games, g)
+	}
+	set.Tiebreak = set.AGames+set.BGames == 13
+	return set
+}
+
+func TestGetBreakMarkets(t *testing.T) {
+	results := []sim.SimulatedMatch{
+		{
+			// B broken in game 4, then a tiebreak set without breaks
+			ASets: 2,
+			SetResults: []sim.SimulatedSet{
+				recordedSet("ABAAABABA"),
+				recordedSet("ABABABABABABA"),
+			},
+		},
+		{
+			// A broken in game 1, then three times in the second set
+			BSets: 2,
+			SetResults: []sim.SimulatedSet{
+				recordedSet("BBABABABAB"),
+				recordedSet("BBBBBB"),
+			},
+		},
+	}
+	breaks := GetBreakMarkets(results)
+
+	t.Run("Broken in set 1", func(t *testing.T) {
+		require.Len(t, breaks.BrokenInSet1, 2)
+		assert.Equal(t, Break, breaks.BrokenInSet1[0].Market)
+		assert.Equal(t, "A", breaks.BrokenInSet1[0].Line)
+		assert.InDelta(t, 0.5, breaks.BrokenInSet1[0].ProbA, 0.001, "A was broken in the first set once")
+		assert.InDelta(t, 0.5, breaks.BrokenInSet1[1].ProbA, 0.001, "B was broken in the first set once")
+	})
+
+	t.Run("First break game", func(t *testing.T) {
+		require.Len(t, breaks.FirstBreakGame, 13, "Expected games 1 to 12 and none")
+		total := 0.0
+		for _, prob := range breaks.FirstBreakGame {
+			total += prob.ProbA
+		}
+		assert.InDelta(t, 1.0, total, 0.001, "First break probabilities should sum to 1")
+		assert.InDelta(t, 0.5, breaks.FirstBreakGame[0].ProbA, 0.001, "First break in game 1 once")
+		assert.InDelta(t, 0.5, breaks.FirstBreakGame[3].ProbA, 0.001, "First break in game 4 once")
+		assert.Equal(t, "none", breaks.FirstBreakGame[12].Line)
+	})
+
+	t.Run("Break totals", func(t *testing.T) {
+		require.NotEmpty(t, breaks.TotalBreaks)
+		for _, prob := range breaks.TotalBreaks {
+			assert.Equal(t, Total, prob.Market)
+			assert.InDelta(t, 1.0, prob.ProbA+prob.ProbB, 0.001)
+			if prob.Line == "0.5" {
+				assert.InDelta(t, 1.0, prob.ProbA, 0.001, "Both matches had a break")
+			}
+		}
+		assert.NotEmpty(t, breaks.BreaksByA)
+		assert.NotEmpty(t, breaks.BreaksByB)
+	})
+
+	t.Run("Without recorded games", func(t *testing.T) {
+		assert.Equal(t, BreakMarkets{}, GetBreakMarkets(createTestSimulatedMatches()))
+	})
+}
+
+func TestCountBreaks(t *testing.T) {
+	tests := []struct {
+		name     string
+		sets     []string
+		expected breakCount
+	}{
+		{"No breaks", []string{"ABABABABABABA"}, breakCount{}},
+		{"Tiebreak is not a break", []string{"ABABABABABABB"}, breakCount{}},
+		{"Break by A", []string{"ABAAABABA"}, breakCount{byA: 1, set1B: true, firstSet1: 4}},
+		{"Break by B", []string{"BBABABABAB"}, breakCount{byB: 1, set1A: true, firstSet1: 1}},
+		{"Second set breaks", []string{"AAAAAA", "BBBBBB"}, breakCount{byA: 3, byB: 3, set1B: true, firstSet1: 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m sim.SimulatedMatch
+			for _, s := range tt.sets {
+				m.SetResults = append(m.SetResults, recordedSet(s))
+			}
+			c, ok := countBreaks(m)
+			require.True(t, ok)
+			assert.Equal(t, tt.expected, c)
+		})
+	}
+}
+
+func TestGetBreakMarketsSimulated(t *testing.T) {
+	results, err := sim.SimulateMatchWithOptions(0.6, 0.6, 3, sim.Options{Simulations: 5000, RecordGames: true})
+	require.NoError(t, err)
+
+	breaks := GetBreakMarkets(results)
+	require.Len(t, breaks.BrokenInSet1, 2)
+	assert.InDelta(t, breaks.BrokenInSet1[0].ProbA, breaks.BrokenInSet1[1].ProbA, 0.05,
+		"Equal players should be broken equally often")
+}
@@ -0,0 +1,154 @@
+package format
+
+import (
+	"fmt"
+	"gotennis/sim"
+	"math"
+)
+
+// COMBO_TOTAL_SPREAD is how far the total lines of winner and total combos reach either side of
+// the expected total games.
+const COMBO_TOTAL_SPREAD float64 = 3
+
+// ComboMarkets holds the markets that depend on several events of the same match.
+type ComboMarkets struct {
+	// DoubleResult is the first set winner combined with the match winner, e.g. "A/B" for A
+	// winning the first set and B the match.
+	DoubleResult []Probability `json:"DoubleResult"`
+	// WinASet is the probability of each player winning at least one set.
+	WinASet []Probability `json:"WinASet"`
+	// WinToNil is the probability of each player winning without dropping a set.
+	WinToNil []Probability `json:"WinToNil"`
+	// WinAndTotal combines the match winner with the total games going over or under a line,
+	// e.g. "A/over 22.5".
+	WinAndTotal []Probability `json:"WinAndTotal"`
+}
+
+// PricedComboMarkets is a ComboMarkets with every probability quoted as odds.
+type PricedComboMarkets struct {
+	DoubleResult []Price `json:"DoubleResult"`
+	WinASet      []Price `json:"WinASet"`
+	WinToNil     []Price `json:"WinToNil"`
+	WinAndTotal  []Price `json:"WinAndTotal"`
+}
+
+// GetComboMarkets calculates the combined markets from the joint outcomes of each simulated match.
+func GetComboMarkets(results []sim.SimulatedMatch) ComboMarkets {
+	return getComboMarkets(results, nil)
+}
+
+// getComboMarkets calculates the combined markets with the extra total lines of WinAndTotal.
+func getComboMarkets(results []sim.SimulatedMatch, extraTotals []float64) ComboMarkets {
+	var res ComboMarkets
+
+	for _, setA := range []bool{true, false} {
+		for _, matchA := range []bool{true, false} {
+			res.DoubleResult = append(res.DoubleResult, getMatchProbability(
+				results,
+				DoubleResult,
+				playerName(setA)+"/"+playerName(matchA),
+				func(m sim.SimulatedMatch) bool {
+					if m.ASets+m.BSets == 0 {
+						return false
+					}
+					first := m.SetResults[0]
+					return (first.AGames > first.BGames) == setA && m.AWins() == matchA
+				},
+			))
+		}
+	}
+
+	res.WinASet = []Probability{
+		getMatchProbability(results, Combo, "A", func(m sim.SimulatedMatch) bool { return m.ASets > 0 }),
+		getMatchProbability(results, Combo, "B", func(m sim.SimulatedMatch) bool { return m.BSets > 0 }),
+	}
+	res.WinToNil = []Probability{
+		getMatchProbability(results, Combo, "A", func(m sim.SimulatedMatch) bool {
+			return m.Ending == sim.Completed && m.AWins() && m.BSets == 0
+		}),
+		getMatchProbability(results, Combo, "B", func(m sim.SimulatedMatch) bool {
+			return m.Ending == sim.Completed && !m.AWins() && m.ASets == 0
+		}),
+	}
+
+	sum := 0
+	for _, m := range results {
+		aGames, bGames := getMatchGames(m)
+		sum += aGames + bGames
+	}
+	expected := math.Floor(float64(sum) / float64(len(results)))
+	var totals []float64
+	for i := expected - COMBO_TOTAL_SPREAD + 0.5; i <= expected+COMBO_TOTAL_SPREAD+0.5; i++ {
+		totals = append(totals, i)
+	}
+	for _, i := range withExtraLines(totals, extraTotals) {
+		for _, winnerA := range []bool{true, false} {
+			for _, over := range []bool{true, false} {
+				side := "under"
+				if over {
+					side = "over"
+				}
+				res.WinAndTotal = append(res.WinAndTotal, getMatchProbability(
+					results,
+					Combo,
+					fmt.Sprintf("%s/%s %.1f", playerName(winnerA), side, i),
+					func(m sim.SimulatedMatch) bool {
+						aGames, bGames := getMatchGames(m)
+						return m.AWins() == winnerA && (float64(aGames+bGames) > i) == over
+					},
+				))
+			}
+		}
+	}
+	return res
+}
+
+func playerName(a bool) string {
+	if a {
+		return "A"
+	}
+	return "B"
+}
+
+// Price quotes every combined market as odds.
+func (m ComboMarkets) Price(cfg OddsConfig) (PricedComboMarkets, error) {
+	var priced PricedComboMarkets
+	var err error
+
+	if priced.DoubleResult, err = GetPrices(m.DoubleResult, cfg); err != nil {
+		return priced, err
+	}
+	if priced.WinASet, err = GetPrices(m.WinASet, cfg); err != nil {
+		return priced, err
+	}
+	if priced.WinToNil, err = GetPrices(m.WinToNil, cfg); err != nil {
+		return priced, err
+	}
+	if priced.WinAndTotal, err = GetPrices(m.WinAndTotal, cfg); err != nil {
+		return priced, err
+	}
+	return priced, nil
+}
+
+// comboFamilies returns the market family of the combined markets.
+func comboFamilies() []MarketFamily {
+	return []MarketFamily{
+		marketFamily[ComboMarkets]{
+			name: "Combos",
+			derive: func(results []sim.SimulatedMatch, cfg MarketConfig) ComboMarkets {
+				return getComboMarkets(results, cfg.Lines.Extra["Combos.WinAndTotal"])
+			},
+			price: func(m ComboMarkets, odds OddsConfig) (any, error) {
+				return m.Price(odds)
+			},
+			probs: func(m ComboMarkets) map[string][]Probability {
+				return map[string][]Probability{
+					"Combos.DoubleResult": m.DoubleResult,
+					"Combos.WinASet":      m.WinASet,
+					"Combos.WinToNil":     m.WinToNil,
+					"Combos.WinAndTotal":  m.WinAndTotal,
+				}
+			},
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package format
+
+import (
+	"gotennis/sim"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetComboMarkets(t *testing.T) {
+	// first sets 6-4 (A), 6-2 (A), 3-6 (B), 7-6 (A); winners A, A, B, A; totals 29, 15, 17, 32
+	combos := GetComboMarkets(createTestSimulatedMatches())
+
+	t.Run("Double result", func(t *testing.T) {
+		expected := map[string]float64{"A/A": 0.75, "A/B": 0, "B/A": 0, "B/B": 0.25}
+		require.Len(t, combos.DoubleResult, len(expected), "Expected every double result")
+		total := 0.0
+		for _, prob := range combos.DoubleResult {
+			assert.Equal(t, DoubleResult, prob.Market, "Expected market %s", DoubleResult)
+			assert.InDelta(t, expected[prob.Line], prob.ProbA, 0.001, "Expected ProbA for %s", prob.Line)
+			total += prob.ProbA
+		}
+		assert.InDelta(t, 1.0, total, 0.001, "Double results should sum to 1")
+	})
+
+	t.Run("Win a set", func(t *testing.T) {
+		require.Len(t, combos.WinASet, 2)
+		assert.InDelta(t, 0.75, combos.WinASet[0].ProbA, 0.001, "A won a set in 3 of 4 matches")
+		assert.InDelta(t, 0.75, combos.WinASet[1].ProbA, 0.001, "B won a set in 3 of 4 matches")
+	})
+
+	t.Run("Win to nil", func(t *testing.T) {
+		require.Len(t, combos.WinToNil, 2)
+		assert.InDelta(t, 0.25, combos.WinToNil[0].ProbA, 0.001, "A won to nil once")
+		assert.InDelta(t, 0.25, combos.WinToNil[1].ProbA, 0.001, "B won to nil once")
+	})
+
+	t.Run("Win and total", func(t *testing.T) {
+		require.Len(t, combos.WinAndTotal, 4*int(2*COMBO_TOTAL_SPREAD+1), "Expected four combos per line")
+		for _, prob := range combos.WinAndTotal {
+			assert.Equal(t, Combo, prob.Market, "Expected market %s", Combo)
+			if prob.Line == "A/over 23.5" {
+				assert.InDelta(t, 0.5, prob.ProbA, 0.001, "A won both matches over 23.5")
+			}
+			if prob.Line == "B/under 23.5" {
+				assert.InDelta(t, 0.25, prob.ProbA, 0.001, "B won one match under 23.5")
+			}
+		}
+		for i := 0; i < len(combos.WinAndTotal); i += 4 {
+			total := 0.0
+			for _, prob := range combos.WinAndTotal[i : i+4] {
+				total += prob.ProbA
+			}
+			assert.InDelta(t, 1.0, total, 0.001, "Combos of a line should sum to 1")
+		}
+	})
+}
+
+func TestGetComboMarketsCorrelation(t *testing.T) {
+	results, err := sim.SimulateMatch(0.7, 0.6, 3, 5000)
+	require.NoError(t, err)
+
+	combos := GetComboMarkets(results)
+	ml := GetMoneyline(results)
+	setA := 0.0
+	for _, prob := range combos.DoubleResult {
+		if prob.Line == "A/A" || prob.Line == "A/B" {
+			setA += prob.ProbA
+		}
+	}
+	for _, prob := range combos.DoubleResult {
+		if prob.Line == "A/A" {
+			assert.Greater(t, prob.ProbA, setA*ml.ProbA, "Winning the first set and the match should be correlated")
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package format
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// DevigMethod is the way the bookmaker margin is removed from quoted odds.
+type DevigMethod string
+
+const (
+	// DevigMultiplicative scales the implied probabilities down by the same factor.
+	DevigMultiplicative DevigMethod = "multiplicative"
+	// DevigAdditive subtracts the same amount from every implied probability.
+	DevigAdditive DevigMethod = "additive"
+	// DevigPower raises every implied probability to the same power.
+	DevigPower DevigMethod = "power"
+	// DevigShin assumes the margin protects the bookmaker against a share of insider bettors.
+	DevigShin DevigMethod = "shin"
+)
+
+// Devig returns the fair probabilities implied by the decimal odds of every outcome of a market.
+func Devig(odds []float64, method DevigMethod) ([]float64, error) {
+	if len(odds) < 2 {
+		return nil, errors.New("at least two odds are required")
+	}
+
+	implied := make([]float64, len(odds))
+	booksum := 0.0
+	for i, o := range odds {
+		if o <= 1 {
+			return nil, errors.New("odds must be greater than 1")
+		}
+		implied[i] = 1 / o
+		booksum += implied[i]
+	}
+
+	out := make([]float64, len(odds))
+	switch method {
+	case DevigMultiplicative:
+		for i, q := range implied {
+			out[i] = q / booksum
+		}
+	case DevigAdditive:
+		for i, q := range implied {
+			out[i] = q - (booksum-1)/float64(len(implied))
+			if out[i] < 0 {
+				return nil, errors.New("additive method gives a negative probability for these odds")
+			}
+		}
+	case DevigPower:
+		k := devigExponent(implied)
+		for i, q := range implied {
+			out[i] = math.Pow(q, k)
+		}
+	case DevigShin:
+		z := shinInsiderShare(implied, booksum)
+		for i, q := range implied {
+			out[i] = shinProbability(q, booksum, z)
+		}
+	default:
+		return nil, fmt.Errorf("unknown devig method %q", method)
+	}
+	return out, nil
+}
+
+// devigExponent finds k such that the implied probabilities raised to the power k sum to one.
+func devigExponent(implied []float64) float64 {
+	sum := func(k float64) float64 {
+		s := 0.0
+		for _, q := range implied {
+			s += math.Pow(q, k)
+		}
+		return s
+	}
+
+	lo, hi := 0.01, 100.0
+	for range 100 {
+		mid := (lo + hi) / 2
+		if sum(mid) > 1 {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// shinInsiderShare finds the share of insider trading z for which Shin's probabilities sum to one.
+func shinInsiderShare(implied []float64, booksum float64) float64 {
+	if booksum <= 1 {
+		return 0
+	}
+	sum := func(z float64) float64 {
+		s := 0.0
+		for _, q := range implied {
+			s += shinProbability(q, booksum, z)
+		}
+		return s
+	}
+
+	lo, hi := 0.0, 0.999
+	for range 100 {
+		mid := (lo + hi) / 2
+		if sum(mid) > 1 {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// shinProbability is the fair probability of an outcome with implied probability q under Shin's
+// model with insider share z.
+func shinProbability(q, booksum, z float64) float64 {
+	return (math.Sqrt(z*z+4*(1-z)*q*q/booksum) - z) / (2 * (1 - z))
+}
+
+// DevigProbability returns the fair Probability of a two-way market quoted at oddsA and oddsB, so
+// that bookmaker prices can be compared with the simulated probabilities.
+func DevigProbability(market Market, line string, oddsA, oddsB float64, method DevigMethod) (Probability, error) {
+	fair, err := Devig([]float64{oddsA, oddsB}, method)
+	if err != nil {
+		return Probability{}, err
+	}
+
+	return Probability{
+		Market: market,
+		Line:   line,
+		ProbA:  fair[0],
+		ProbB:  fair[1],
+	}, nil
+}
@@ -0,0 +1,89 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDevig(t *testing.T) {
+	methods := []DevigMethod{DevigMultiplicative, DevigAdditive, DevigPower, DevigShin}
+	markets := []struct {
+		name string
+		odds []float64
+	}{
+		{"Even market", []float64{1.91, 1.91}},
+		{"Favourite and underdog", []float64{1.30, 3.60}},
+		{"Three-way", []float64{2.10, 3.40, 3.90}},
+		{"No margin", []float64{2.0, 2.0}},
+	}
+
+	for _, method := range methods {
+		for _, m := range markets {
+			t.Run(string(method)+" "+m.name, func(t *testing.T) {
+				fair, err := Devig(m.odds, method)
+				require.NoError(t, err)
+				require.Len(t, fair, len(m.odds))
+				total := 0.0
+				for i, p := range fair {
+					assert.GreaterOrEqual(t, p, 0.0, "probability should be >= 0")
+					assert.LessOrEqual(t, p, 1/m.odds[i]+1e-9, "fair probability should not exceed the implied one")
+					total += p
+				}
+				assert.InDelta(t, 1.0, total, 1e-6, "fair probabilities should sum to 1")
+			})
+		}
+	}
+}
+
+func TestDevigKnownValues(t *testing.T) {
+	odds := []float64{1.30, 3.60}
+
+	multiplicative, err := Devig(odds, DevigMultiplicative)
+	require.NoError(t, err)
+	assert.InDelta(t, (1/1.30)/(1/1.30+1/3.60), multiplicative[0], 1e-9, "multiplicative favourite")
+
+	additive, err := Devig(odds, DevigAdditive)
+	require.NoError(t, err)
+	assert.InDelta(t, 1/1.30-(1/1.30+1/3.60-1)/2, additive[0], 1e-9, "additive favourite")
+
+	// power and Shin attribute more of the margin to the longshot than the multiplicative method
+	for _, method := range []DevigMethod{DevigPower, DevigShin} {
+		fair, err := Devig(odds, method)
+		require.NoError(t, err)
+		assert.Greater(t, fair[0], multiplicative[0], "%s should favour the favourite", method)
+	}
+}
+
+func TestDevigErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		odds   []float64
+		method DevigMethod
+	}{
+		{"Single outcome", []float64{1.5}, DevigMultiplicative},
+		{"Odds of one", []float64{1.0, 2.0}, DevigMultiplicative},
+		{"Unknown method", []float64{1.9, 1.9}, DevigMethod("magic")},
+		{"Negative additive probability", []float64{1.2, 1.2, 50}, DevigAdditive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Devig(tt.odds, tt.method)
+			assert.Error(t, err, "Expected error for %v", tt.odds)
+		})
+	}
+}
+
+func TestDevigProbability(t *testing.T) {
+	prob, err := DevigProbability(Total, "22.5", 1.85, 1.95, DevigShin)
+	require.NoError(t, err)
+	assert.Equal(t, Total, prob.Market, "Expected market %s", Total)
+	assert.Equal(t, "22.5", prob.Line, "Expected line 22.5")
+	assert.Greater(t, prob.ProbA, prob.ProbB, "shorter odds should be more likely")
+	assert.InDelta(t, 1.0, prob.ProbA+prob.ProbB, 1e-6, "probabilities should sum to 1")
+
+	_, err = DevigProbability(Moneyline, "ml", 0.5, 1.95, DevigShin)
+	assert.Error(t, err, "Expected error for odds below 1")
+}
@@ -0,0 +1,161 @@
+package format
+
+import (
+	"gotennis/sim"
+	"math"
+	"slices"
+)
+
+// QUANTILES are the quantiles reported for every Distribution.
+var QUANTILES = []float64{0.05, 0.1, 0.25, 0.5, 0.75, 0.9, 0.95}
+
+// Distributions holds the full distributions the line markets are derived from.
+type Distributions struct {
+	// SetScores is the probability of every final score in sets.
+	SetScores  []ScoreProbability `json:"SetScores"`
+	SetMargin  Distribution       `json:"SetMargin"`
+	GameMargin Distribution       `json:"GameMargin"`
+	TotalGames Distribution       `json:"TotalGames"`
+	PlayerA    Distribution       `json:"PlayerAGames"`
+	PlayerB    Distribution       `json:"PlayerBGames"`
+}
+
+// ScoreProbability is the probability of a match ending with a score in sets.
+type ScoreProbability struct {
+	ASets int     `json:"ASets"`
+	BSets int     `json:"BSets"`
+	Prob  float64 `json:"prob"`
+}
+
+// Distribution is the probability mass function of a whole number match statistic, such as the
+// total games, together with its summary statistics. Margins are from A's point of view.
+type Distribution struct {
+	PMF       []ValueProbability `json:"pmf"`
+	Mean      float64            `json:"mean"`
+	Median    int                `json:"median"`
+	StdDev    float64            `json:"stdDev"`
+	Quantiles []Quantile         `json:"quantiles"`
+}
+
+// ValueProbability is the probability of a statistic taking a value.
+type ValueProbability struct {
+	Value int     `json:"value"`
+	Prob  float64 `json:"prob"`
+}
+
+// Quantile is the smallest value at which the cumulative probability reaches Q.
+type Quantile struct {
+	Q     float64 `json:"q"`
+	Value int     `json:"value"`
+}
+
+// GetDistributions calculates the set score distribution and the distributions of the set margin,
+// game margin, total games and the games of each player.
+func GetDistributions(results []sim.SimulatedMatch) Distributions {
+	return Distributions{
+		SetScores: getSetScores(results),
+		SetMargin: getDistribution(results, func(m sim.SimulatedMatch) int { return m.ASets - m.BSets }),
+		GameMargin: getDistribution(results, func(m sim.SimulatedMatch) int {
+			aGames, bGames := getMatchGames(m)
+			return aGames - bGames
+		}),
+		TotalGames: getDistribution(results, func(m sim.SimulatedMatch) int {
+			aGames, bGames := getMatchGames(m)
+			return aGames + bGames
+		}),
+		PlayerA: getDistribution(results, func(m sim.SimulatedMatch) int {
+			aGames, _ := getMatchGames(m)
+			return aGames
+		}),
+		PlayerB: getDistribution(results, func(m sim.SimulatedMatch) int {
+			_, bGames := getMatchGames(m)
+			return bGames
+		}),
+	}
+}
+
+// getSetScores returns the probability of every score in sets that occurred, ordered from the
+// biggest win for A to the biggest win for B.
+func getSetScores(results []sim.SimulatedMatch) []ScoreProbability {
+	type score struct{ a, b int }
+	counts := make(map[score]int)
+	for _, m := range results {
+		counts[score{m.ASets, m.BSets}]++
+	}
+
+	out := make([]ScoreProbability, 0, len(counts))
+	for s, n := range counts {
+		out = append(out, ScoreProbability{ASets: s.a, BSets: s.b, Prob: float64(n) / float64(len(results))})
+	}
+	slices.SortFunc(out, func(x, y ScoreProbability) int {
+		if d := (y.ASets - y.BSets) - (x.ASets - x.BSets); d != 0 {
+			return d
+		}
+		return y.ASets - x.ASets
+	})
+	return out
+}
+
+// getDistribution returns the distribution of 'value' over the simulated matches.
+func getDistribution(results []sim.SimulatedMatch, value func(sim.SimulatedMatch) int) Distribution {
+	var dist Distribution
+	if len(results) == 0 {
+		return dist
+	}
+
+	counts := make(map[int]int)
+	for _, m := range results {
+		counts[value(m)]++
+	}
+	values := make([]int, 0, len(counts))
+	for v := range counts {
+		values = append(values, v)
+	}
+	slices.Sort(values)
+
+	n := float64(len(results))
+	for _, v := range values {
+		p := float64(counts[v]) / n
+		dist.PMF = append(dist.PMF, ValueProbability{Value: v, Prob: p})
+		dist.Mean += float64(v) * p
+	}
+
+	variance := 0.0
+	for _, vp := range dist.PMF {
+		variance += (float64(vp.Value) - dist.Mean) * (float64(vp.Value) - dist.Mean) * vp.Prob
+	}
+	dist.StdDev = math.Sqrt(variance)
+
+	for _, q := range QUANTILES {
+		dist.Quantiles = append(dist.Quantiles, Quantile{Q: q, Value: quantile(values, counts, len(results), q)})
+	}
+	dist.Median = quantile(values, counts, len(results), 0.5)
+	return dist
+}
+
+// quantile returns the smallest of the sorted values whose cumulative count reaches q of the total.
+func quantile(values []int, counts map[int]int, total int, q float64) int {
+	// compare counts rather than probabilities to avoid rounding errors at exact quantiles
+	target := q * float64(total)
+	cumulative := 0
+	for _, v := range values {
+		cumulative += counts[v]
+		if float64(cumulative) >= target-1e-9 {
+			return v
+		}
+	}
+	return values[len(values)-1]
+}
+
+// distributionFamilies returns the market family of the distributions, which are returned as they
+// are when pricing.
+func distributionFamilies() []MarketFamily {
+	return []MarketFamily{
+		marketFamily[Distributions]{
+			name: "Distributions",
+			derive: func(results []sim.SimulatedMatch, _ MarketConfig) Distributions {
+				return GetDistributions(results)
+			},
+		},
+	}
+}
@@ -0,0 +1,86 @@
+package format
+
+import (
+	"gotennis/sim"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDistributions(t *testing.T) {
+	// set scores 2-1, 2-0, 0-2, 2-1; game margins +3, +9, -7, 0; totals 29, 15, 17, 32
+	dists := GetDistributions(createTestSimulatedMatches())
+
+	t.Run("Set scores", func(t *testing.T) {
+		assert.Equal(t, []ScoreProbability{
+			{ASets: 2, BSets: 0, Prob: 0.25},
+			{ASets: 2, BSets: 1, Prob: 0.5},
+			{ASets: 0, BSets: 2, Prob: 0.25},
+		}, dists.SetScores)
+	})
+
+	t.Run("Game margin", func(t *testing.T) {
+		require.Len(t, dists.GameMargin.PMF, 4)
+		assert.Equal(t, -7, dists.GameMargin.PMF[0].Value, "Expected values in order")
+		assert.Equal(t, 9, dists.GameMargin.PMF[3].Value, "Expected values in order")
+		assert.InDelta(t, 1.25, dists.GameMargin.Mean, 0.001)
+		assert.Equal(t, 0, dists.GameMargin.Median)
+	})
+
+	t.Run("Total games", func(t *testing.T) {
+		total := dists.TotalGames
+		assert.InDelta(t, 23.25, total.Mean, 0.001)
+		assert.InDelta(t, 7.3612, total.StdDev, 0.001)
+		assert.Equal(t, 17, total.Median)
+		require.Len(t, total.Quantiles, len(QUANTILES))
+		assert.Equal(t, Quantile{Q: 0.05, Value: 15}, total.Quantiles[0])
+		assert.Equal(t, Quantile{Q: 0.75, Value: 29}, total.Quantiles[4])
+		assert.Equal(t, Quantile{Q: 0.95, Value: 32}, total.Quantiles[6])
+	})
+
+	t.Run("Player games", func(t *testing.T) {
+		assert.InDelta(t, 12.25, dists.PlayerA.Mean, 0.001, "A won 16, 12, 5 and 16 games")
+		assert.InDelta(t, 11, dists.PlayerB.Mean, 0.001, "B won 13, 3, 12 and 16 games")
+		assert.InDelta(t, dists.TotalGames.Mean, dists.PlayerA.Mean+dists.PlayerB.Mean, 0.001)
+	})
+
+	t.Run("Set margin", func(t *testing.T) {
+		assert.Equal(t, []ValueProbability{{-2, 0.25}, {1, 0.5}, {2, 0.25}}, dists.SetMargin.PMF)
+	})
+}
+
+func TestGetDistributionsSimulated(t *testing.T) {
+	results, err := sim.SimulateMatch(0.65, 0.6, 3, 5000)
+	require.NoError(t, err)
+
+	dists := GetDistributions(results)
+	for name, dist := range map[string]Distribution{
+		"SetMargin":  dists.SetMargin,
+		"GameMargin": dists.GameMargin,
+		"TotalGames": dists.TotalGames,
+		"PlayerA":    dists.PlayerA,
+		"PlayerB":    dists.PlayerB,
+	} {
+		total := 0.0
+		for _, vp := range dist.PMF {
+			total += vp.Prob
+		}
+		assert.InDelta(t, 1.0, total, 0.001, "%s should sum to 1", name)
+		for i := 1; i < len(dist.Quantiles); i++ {
+			assert.GreaterOrEqual(t, dist.Quantiles[i].Value, dist.Quantiles[i-1].Value, "%s quantiles should grow", name)
+		}
+	}
+
+	pmf := GetGameTotalDistribution(results)
+	for _, vp := range dists.TotalGames.PMF {
+		for _, p := range pmf {
+			if p.Line == strconv.Itoa(vp.Value) {
+				assert.InDelta(t, p.ProbA, vp.Prob, 1e-9, "Expected the same total games PMF")
+			}
+		}
+	}
+
+	assert.Equal(t, Distribution{}, getDistribution(nil, func(sim.SimulatedMatch) int { return 0 }))
+}
@@ -3,6 +3,7 @@ package format
 import (
 	"fmt"
 	"gotennis/sim"
+	"math"
 )
 
 type Market string
@@ -11,6 +12,24 @@ const (
 	Moneyline Market = "ML"
 	Handicap  Market = "AH"
 	Total     Market = "OU"
+	// CorrectScore markets are quoted per score, with ProbA the probability of the score and
+	// ProbB the probability of any other score.
+	CorrectScore Market = "CS"
+	// Tiebreak markets are yes/no markets on tiebreaks being played, with ProbA the probability of yes.
+	Tiebreak Market = "TB"
+	// DoubleResult markets combine the first set winner with the match winner, quoted per outcome.
+	DoubleResult Market = "DR"
+	// Combo markets are yes/no markets on combinations of match events, with ProbA the probability of yes.
+	Combo Market = "CB"
+	// OddEven is the total games being odd (ProbA) or even (ProbB).
+	OddEven Market = "OE"
+	// TotalGames markets are quoted per exact total or band of totals, with ProbA the probability
+	// of the total landing on the line and ProbB of it landing anywhere else.
+	TotalGames Market = "TG"
+	// Break markets are yes/no markets on breaks of serve, with ProbA the probability of yes.
+	Break Market = "BK"
+	// SetWinner is the winner of a single set, only offered as a leg of a same match parlay.
+	SetWinner Market = "SW"
 )
 
 type Probability struct {
@@ -18,11 +37,26 @@ type Probability struct {
 	Line   string  `json:"Line"`
 	ProbA  float64 `json:"probA"`
 	ProbB  float64 `json:"probB"`
+	// Push is the probability of the stake being returned, only possible on whole and quarter lines.
+	Push float64 `json:"push,omitempty"`
 }
 
+// RetirementRule decides how bets are settled when a match is not completed.
+type RetirementRule string
+
+const (
+	// RetirementVoid voids every bet on a match that was not completed.
+	RetirementVoid RetirementRule = "void"
+	// RetirementSettle settles bets on retired matches as if the score at retirement was final,
+	// with the retiring player losing the match.
+	RetirementSettle RetirementRule = "settle"
+)
+
 const (
 	BO3_GAME_SPREAD float64 = 8.5
 	BO5_GAME_SPREAD float64 = 12.5
+	// PLAYER_GAME_SPREAD is how far player game total lines reach either side of the expected games.
+	PLAYER_GAME_SPREAD float64 = 4
 )
 
 func mapBOToGameSpread(bo int) float64 {
@@ -36,11 +70,45 @@ func mapBOToGameSpread(bo int) float64 {
 	}
 }
 
+// ApplyRetirementRule returns the simulated matches that settle under the given rule. Voided
+// matches are dropped, so probabilities derived from the result are conditional on the bet
+// standing. Walkovers are void under either rule as no play took place.
+func ApplyRetirementRule(results []sim.SimulatedMatch, rule RetirementRule) ([]sim.SimulatedMatch, error) {
+	if rule != RetirementVoid && rule != RetirementSettle {
+		return nil, fmt.Errorf("unknown retirement rule %q", rule)
+	}
+
+	allCompleted := true
+	for _, m := range results {
+		if m.Ending != sim.Completed {
+			allCompleted = false
+			break
+		}
+	}
+	if allCompleted {
+		return results, nil
+	}
+
+	out := make([]sim.SimulatedMatch, 0, len(results))
+	for _, m := range results {
+		switch m.Ending {
+		case sim.Completed:
+			out = append(out, m)
+		case sim.Retired:
+			if rule == RetirementSettle {
+				out = append(out, m)
+			}
+		case sim.Walkover:
+		}
+	}
+	return out, nil
+}
+
 // GetMoneyline calculates the moneyline Probability for A win.
 func GetMoneyline(sim []sim.SimulatedMatch) Probability {
 	n := 0
 	for _, s := range sim {
-		if s.ASets > s.BSets {
+		if s.AWins() {
 			n++
 		}
 	}
@@ -53,31 +121,36 @@ func GetMoneyline(sim []sim.SimulatedMatch) Probability {
 	}
 }
 
+// GetGameHandicaps calculates the game handicap probabilities for A on half lines.
 func GetGameHandicaps(sim []sim.SimulatedMatch, bestof int) []Probability {
+	return GetGameHandicapLines(sim, bestof, HalfLines)
+}
+
+// GetGameHandicapLines calculates the game handicap probabilities for A on the given type of lines.
+func GetGameHandicapLines(sim []sim.SimulatedMatch, bestof int, lines LineType) []Probability {
 	var out []Probability
 
 	r := mapBOToGameSpread(bestof)
-	for i := -r; i <= r; i++ {
+	for i := -r; i <= r; i += lineStep(lines) {
 		out = append(out, getGameHandicap(sim, i))
 	}
 	return out
 }
 
-func getGameHandicap(sim []sim.SimulatedMatch, handicap float64) Probability {
-	n := 0
-	for _, m := range sim {
-		aGames, bGames := getMatchGames(m)
-		if float64(aGames)+handicap > float64(bGames) {
-			n++
-		}
+// GetGameHandicapsAt calculates the game handicap probabilities for A on the given lines only.
+func GetGameHandicapsAt(results []sim.SimulatedMatch, lines []float64) []Probability {
+	out := make([]Probability, 0, len(lines))
+	for _, line := range lines {
+		out = append(out, getGameHandicap(results, line))
 	}
+	return out
+}
 
-	return Probability{
-		Market: Handicap,
-		Line:   fmt.Sprintf("%.1f", handicap),
-		ProbA:  float64(n) / float64(len(sim)),
-		ProbB:  1 - float64(n)/float64(len(sim)),
-	}
+func getGameHandicap(results []sim.SimulatedMatch, handicap float64) Probability {
+	return getLineProbability(results, Handicap, handicap, func(m sim.SimulatedMatch, line float64) float64 {
+		aGames, bGames := getMatchGames(m)
+		return float64(aGames) + line - float64(bGames)
+	})
 }
 
 func getMatchGames(sim sim.SimulatedMatch) (int, int) {
@@ -89,20 +162,101 @@ func getMatchGames(sim sim.SimulatedMatch) (int, int) {
 	return aGames, bGames
 }
 
+// getMatchProbability returns the share of matches for which 'aWins' holds as a Probability.
+func getMatchProbability(
+	results []sim.SimulatedMatch,
+	market Market,
+	line string,
+	aWins func(sim.SimulatedMatch) bool,
+) Probability {
+	n := 0
+	for _, m := range results {
+		if aWins(m) {
+			n++
+		}
+	}
+
+	return Probability{
+		Market: market,
+		Line:   line,
+		ProbA:  float64(n) / float64(len(results)),
+		ProbB:  1 - float64(n)/float64(len(results)),
+	}
+}
+
 // GetGameTotals calculates the probabilities for the total markets based on the given results.
 func GetGameTotals(results []sim.SimulatedMatch, bestof int) []Probability {
+	return GetGameTotalLines(results, bestof, HalfLines)
+}
+
+// GetGameTotalLines calculates the probabilities for the total markets based on the given results,
+// on the given type of lines.
+func GetGameTotalLines(results []sim.SimulatedMatch, bestof int, lines LineType) []Probability {
 	var probs []Probability
-	for i := float64(bestof/2+1)*6 + 0.5; i <= float64(bestof*6*2)+0.5; i++ {
+	for i := float64(bestof/2+1)*6 + 0.5; i <= float64(bestof*6*2)+0.5; i += lineStep(lines) {
 		probs = append(probs, getGameTotal(results, i))
 	}
 	return probs
 }
 
+// GetGameTotalsAt calculates the total games probabilities on the given lines only.
+func GetGameTotalsAt(results []sim.SimulatedMatch, lines []float64) []Probability {
+	out := make([]Probability, 0, len(lines))
+	for _, line := range lines {
+		out = append(out, getGameTotal(results, line))
+	}
+	return out
+}
+
 func getGameTotal(results []sim.SimulatedMatch, total float64) Probability {
+	return getLineProbability(results, Total, total, func(m sim.SimulatedMatch, line float64) float64 {
+		aGames, bGames := getMatchGames(m)
+		return float64(aGames+bGames) - line
+	})
+}
+
+// GetPlayerGameTotals calculates the over/under probabilities for the games won by each player,
+// on lines around the player's expected number of games. The first slice is for A, the second for B.
+func GetPlayerGameTotals(results []sim.SimulatedMatch) ([]Probability, []Probability) {
+	return getPlayerGameTotals(results, true, nil), getPlayerGameTotals(results, false, nil)
+}
+
+// getPlayerGameTotals calculates the player game totals on the lines around the expected games of
+// the player and the extra lines.
+func getPlayerGameTotals(results []sim.SimulatedMatch, playerA bool, extra []float64) []Probability {
+	sum := 0
+	for _, m := range results {
+		aGames, bGames := getMatchGames(m)
+		if playerA {
+			sum += aGames
+		} else {
+			sum += bGames
+		}
+	}
+	expected := float64(sum) / float64(len(results))
+
+	var lines []float64
+	first := math.Max(0.5, math.Floor(expected)-PLAYER_GAME_SPREAD+0.5)
+	for i := first; i <= math.Floor(expected)+PLAYER_GAME_SPREAD+0.5; i++ {
+		lines = append(lines, i)
+	}
+
+	var out []Probability
+	for _, line := range withExtraLines(lines, extra) {
+		out = append(out, getPlayerGameTotal(results, line, playerA))
+	}
+	return out
+}
+
+func getPlayerGameTotal(results []sim.SimulatedMatch, total float64, playerA bool) Probability {
 	n := 0
 	for _, m := range results {
 		aGames, bGames := getMatchGames(m)
-		if float64(aGames+bGames) > total {
+		games := bGames
+		if playerA {
+			games = aGames
+		}
+		if float64(games) > total {
 			n++
 		}
 	}
@@ -130,20 +284,19 @@ func GetSetHandicaps(results []sim.SimulatedMatch, bestof int) []Probability {
 	return out
 }
 
-func getSetHandicap(results []sim.SimulatedMatch, handicap float64) Probability {
-	n := 0
-	for _, m := range results {
-		if float64(m.ASets)+handicap > float64(m.BSets) {
-			n++
-		}
+// GetSetHandicapsAt calculates the set handicap probabilities for A on the given lines only.
+func GetSetHandicapsAt(results []sim.SimulatedMatch, lines []float64) []Probability {
+	out := make([]Probability, 0, len(lines))
+	for _, line := range lines {
+		out = append(out, getSetHandicap(results, line))
 	}
+	return out
+}
 
-	return Probability{
-		Market: Handicap,
-		Line:   fmt.Sprintf("%.1f", handicap),
-		ProbA:  float64(n) / float64(len(results)),
-		ProbB:  1 - float64(n)/float64(len(results)),
-	}
+func getSetHandicap(results []sim.SimulatedMatch, handicap float64) Probability {
+	return getLineProbability(results, Handicap, handicap, func(m sim.SimulatedMatch, line float64) float64 {
+		return float64(m.ASets) + line - float64(m.BSets)
+	})
 }
 
 func GetSetTotals(results []sim.SimulatedMatch, bestof int) []Probability {
@@ -180,3 +333,89 @@ func getSetTotal(results []sim.SimulatedMatch, total float64) Probability {
 		ProbB:  1 - float64(n)/float64(len(results)),
 	}
 }
+
+// GetCorrectScores calculates the probability of each possible final score in sets, ordered from
+// the biggest win for A to the biggest win for B. Only completed matches have a final score, so
+// the probabilities are conditional on the match being completed and are zero without any.
+func GetCorrectScores(results []sim.SimulatedMatch, bestof int) []Probability {
+	setsToWin := bestof/2 + 1
+	var out []Probability
+	for b := 0; b < setsToWin; b++ {
+		out = append(out, getCorrectScore(results, setsToWin, b))
+	}
+	for a := setsToWin - 1; a >= 0; a-- {
+		out = append(out, getCorrectScore(results, a, setsToWin))
+	}
+	return out
+}
+
+func getCorrectScore(results []sim.SimulatedMatch, aSets, bSets int) Probability {
+	n, completed := 0, 0
+	for _, m := range results {
+		if m.Ending != sim.Completed {
+			continue
+		}
+		completed++
+		if m.ASets == aSets && m.BSets == bSets {
+			n++
+		}
+	}
+
+	p := Probability{Market: CorrectScore, Line: fmt.Sprintf("%d-%d", aSets, bSets)}
+	if completed > 0 {
+		p.ProbA = float64(n) / float64(completed)
+		p.ProbB = 1 - p.ProbA
+	}
+	return p
+}
+
+// matchFamilies returns the market families of the match winner, handicaps, totals and correct score.
+func matchFamilies() []MarketFamily {
+	return []MarketFamily{
+		probabilityFamily("Moneyline", func(results []sim.SimulatedMatch, _ MarketConfig) Probability {
+			return GetMoneyline(results)
+		}, string(Moneyline)),
+		probabilitiesFamily("SetHandicaps", true, func(results []sim.SimulatedMatch, cfg MarketConfig) []Probability {
+			if cfg.Lines.SetHandicaps != nil {
+				return GetSetHandicapsAt(results, cfg.Lines.SetHandicaps)
+			}
+			return GetSetHandicaps(results, cfg.BestOf)
+		}),
+		probabilitiesFamily("GameHandicaps", true, func(results []sim.SimulatedMatch, cfg MarketConfig) []Probability {
+			var probs []Probability
+			if cfg.Lines.GameHandicaps != nil {
+				probs = GetGameHandicapsAt(results, cfg.Lines.GameHandicaps)
+			} else {
+				probs = GetGameHandicapLines(results, cfg.BestOf, cfg.Lines.Type)
+			}
+			if cfg.Lines.Smooth {
+				return SmoothLines(probs)
+			}
+			return probs
+		}),
+		probabilitiesFamily("SetOU", true, func(results []sim.SimulatedMatch, cfg MarketConfig) []Probability {
+			return GetSetTotals(results, cfg.BestOf)
+		}),
+		probabilitiesFamily("GameOU", true, func(results []sim.SimulatedMatch, cfg MarketConfig) []Probability {
+			var probs []Probability
+			if cfg.Lines.GameTotals != nil {
+				probs = GetGameTotalsAt(results, cfg.Lines.GameTotals)
+			} else {
+				probs = GetGameTotalLines(results, cfg.BestOf, cfg.Lines.Type)
+			}
+			if cfg.Lines.Smooth {
+				return SmoothLines(probs)
+			}
+			return probs
+		}),
+		probabilitiesFamily("CorrectScore", false, func(results []sim.SimulatedMatch, cfg MarketConfig) []Probability {
+			return GetCorrectScores(results, cfg.BestOf)
+		}),
+		probabilitiesFamily("PlayerAGameOU", true, func(results []sim.SimulatedMatch, cfg MarketConfig) []Probability {
+			return getPlayerGameTotals(results, true, cfg.Lines.Extra["PlayerAGameOU"])
+		}),
+		probabilitiesFamily("PlayerBGameOU", true, func(results []sim.SimulatedMatch, cfg MarketConfig) []Probability {
+			return getPlayerGameTotals(results, false, cfg.Lines.Extra["PlayerBGameOU"])
+		}),
+	}
+}
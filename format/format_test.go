@@ -132,7 +132,7 @@ func TestGetGameHandicap(t *testing.T) {
 		expectedProbA float64
 		expectedProbB float64
 	}{
-		{"Handicap 0", 0.0, 0.5, 0.5},
+		{"Handicap 0", 0.0, 0.5, 0.25},
 		{"Handicap 5", 5.0, 0.75, 0.25},
 		{"Handicap -5", -5.0, 0.25, 0.75},
 	}
@@ -317,7 +317,7 @@ func TestProbabilityStructure(t *testing.T) {
 	assert.Equal(t, 1.0, ml.ProbA+ml.ProbB, "Moneyline probabilities should sum to 1.0")
 
 	gh := getGameHandicap(sim, 0.0)
-	assert.InDelta(t, 1.0, gh.ProbA+gh.ProbB, 0.001, "Game handicap probabilities should sum to 1.0")
+	assert.InDelta(t, 1.0, gh.ProbA+gh.ProbB+gh.Push, 0.001, "Game handicap probabilities should sum to 1.0")
 
 	sh := getSetHandicap(sim, 0.0)
 	assert.InDelta(t, 1.0, sh.ProbA+sh.ProbB, 0.001, "Set handicap probabilities should sum to 1.0")
@@ -439,3 +439,168 @@ func TestProbabilityBounds(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyRetirementRule(t *testing.T) {
+	results := append(createTestSimulatedMatches(),
+		sim.SimulatedMatch{
+			ASets: 1, BSets: 0,
+			SetResults: []sim.SimulatedSet{
+				{AGames: 6, BGames: 3},
+				{AGames: 2, BGames: 1},
+			},
+			Ending:   sim.Retired,
+			RetiredA: true,
+		},
+		sim.SimulatedMatch{Ending: sim.Walkover},
+	)
+
+	tests := []struct {
+		name          string
+		rule          RetirementRule
+		expectedLen   int
+		expectedProbA float64
+	}{
+		{"Void", RetirementVoid, 4, 0.75},
+		{"Settle", RetirementSettle, 5, 0.6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settled, err := ApplyRetirementRule(results, tt.rule)
+			require.NoError(t, err)
+			assert.Len(t, settled, tt.expectedLen, "Expected %d settled matches", tt.expectedLen)
+			ml := GetMoneyline(settled)
+			assert.InDelta(t, tt.expectedProbA, ml.ProbA, 0.001, "Expected ProbA %f", tt.expectedProbA)
+			for _, m := range settled {
+				assert.NotEqual(t, sim.Walkover, m.Ending, "Walkovers should always be void")
+			}
+		})
+	}
+
+	t.Run("Unknown rule", func(t *testing.T) {
+		_, err := ApplyRetirementRule(results, RetirementRule("refund"))
+		assert.Error(t, err, "Expected error for unknown rule")
+	})
+
+	t.Run("Completed matches are returned as is", func(t *testing.T) {
+		completed := createTestSimulatedMatches()
+		settled, err := ApplyRetirementRule(completed, RetirementVoid)
+		require.NoError(t, err)
+		assert.Equal(t, completed, settled)
+	})
+}
+
+func TestGetCorrectScores(t *testing.T) {
+	sim := createTestSimulatedMatches()
+
+	tests := []struct {
+		name          string
+		bestof        int
+		expectedLines []string
+		expectedProbA []float64
+	}{
+		{
+			"Best of 3",
+			3,
+			[]string{"2-0", "2-1", "1-2", "0-2"},
+			[]float64{0.25, 0.5, 0, 0.25},
+		},
+		{
+			"Best of 5",
+			5,
+			[]string{"3-0", "3-1", "3-2", "2-3", "1-3", "0-3"},
+			[]float64{0, 0, 0, 0, 0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := GetCorrectScores(sim, tt.bestof)
+			require.Len(t, result, len(tt.expectedLines), "Expected %d scores", len(tt.expectedLines))
+			for i, prob := range result {
+				assert.Equal(t, CorrectScore, prob.Market, "Expected market %s", CorrectScore)
+				assert.Equal(t, tt.expectedLines[i], prob.Line, "Expected line %s", tt.expectedLines[i])
+				assert.InDelta(t, tt.expectedProbA[i], prob.ProbA, 0.001, "Expected ProbA %f", tt.expectedProbA[i])
+				assert.InDelta(t, 1.0, prob.ProbA+prob.ProbB, 0.001, "Probabilities should sum to 1")
+			}
+		})
+	}
+}
+
+func TestGetCorrectScoresSumToOne(t *testing.T) {
+	results, err := sim.SimulateMatch(0.65, 0.6, 5, 2000)
+	require.NoError(t, err)
+
+	total := 0.0
+	for _, prob := range GetCorrectScores(results, 5) {
+		total += prob.ProbA
+	}
+	assert.InDelta(t, 1.0, total, 1e-9, "Correct score probabilities should sum to 1")
+
+	t.Run("Retirements", func(t *testing.T) {
+		results, err := sim.SimulateMatchWithOptions(0.65, 0.6, 5, sim.Options{Simulations: 2000, RetireA: 0.01})
+		require.NoError(t, err)
+		for _, rule := range []RetirementRule{RetirementVoid, RetirementSettle} {
+			standing, err := ApplyRetirementRule(results, rule)
+			require.NoError(t, err)
+			total := 0.0
+			for _, prob := range GetCorrectScores(standing, 5) {
+				total += prob.ProbA
+			}
+			assert.InDelta(t, 1.0, total, 1e-9, "Correct score probabilities should sum to 1 under %s", rule)
+		}
+	})
+
+	t.Run("No completed match", func(t *testing.T) {
+		retired := []sim.SimulatedMatch{{ASets: 1, Ending: sim.Retired, RetiredA: true}}
+		for _, prob := range GetCorrectScores(retired, 3) {
+			assert.Zero(t, prob.ProbA, "Expected no probability for %s", prob.Line)
+			assert.Zero(t, prob.ProbB, "Expected no probability for %s", prob.Line)
+		}
+	})
+}
+
+func TestGetPlayerGameTotals(t *testing.T) {
+	sim := createTestSimulatedMatches()
+	// A games: 16, 12, 5, 16 (mean 12.25); B games: 13, 3, 12, 16 (mean 11)
+	playerA, playerB := GetPlayerGameTotals(sim)
+
+	tests := []struct {
+		name          string
+		probs         []Probability
+		expectedFirst string
+		expectedLast  string
+		line          string
+		expectedProbA float64
+	}{
+		{"Player A", playerA, "8.5", "16.5", "12.5", 0.5},
+		{"Player B", playerB, "7.5", "15.5", "12.5", 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Len(t, tt.probs, int(2*PLAYER_GAME_SPREAD+1), "Expected lines around the expected games")
+			assert.Equal(t, tt.expectedFirst, tt.probs[0].Line, "Expected first line %s", tt.expectedFirst)
+			assert.Equal(t, tt.expectedLast, tt.probs[len(tt.probs)-1].Line, "Expected last line %s", tt.expectedLast)
+			for _, prob := range tt.probs {
+				assert.Equal(t, Total, prob.Market, "Expected market %s", Total)
+				assert.InDelta(t, 1.0, prob.ProbA+prob.ProbB, 0.001, "Probabilities should sum to 1")
+				if prob.Line == tt.line {
+					assert.InDelta(t, tt.expectedProbA, prob.ProbA, 0.001, "Expected ProbA for %s", tt.line)
+				}
+			}
+		})
+	}
+}
+
+func TestGetPlayerGameTotalsLowExpectation(t *testing.T) {
+	results := []sim.SimulatedMatch{
+		{
+			ASets: 0, BSets: 2,
+			SetResults: []sim.SimulatedSet{{AGames: 0, BGames: 6}, {AGames: 1, BGames: 6}},
+		},
+	}
+	playerA, _ := GetPlayerGameTotals(results)
+	require.NotEmpty(t, playerA, "Expected lines for player A")
+	assert.Equal(t, "0.5", playerA[0].Line, "Lines should not go below 0.5")
+}
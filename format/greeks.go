@@ -0,0 +1,76 @@
+package format
+
+// Delta is the partial derivative of the probabilities of a market with respect to a serve
+// probability.
+type Delta struct {
+	A    float64 `json:"A"`
+	B    float64 `json:"B"`
+	Push float64 `json:"push,omitempty"`
+}
+
+// Greek is the sensitivity of a Probability to the serve probabilities of both players. A delta
+// of 0.5 moves the probability by half a point when the serve probability moves by one point.
+type Greek struct {
+	Market Market `json:"Market"`
+	Line   string `json:"Line"`
+	P1     Delta  `json:"p1"`
+	P2     Delta  `json:"p2"`
+}
+
+// Bumped holds the markets derived below and above the serve probability of a player, keyed as by
+// Registry.Probabilities.
+type Bumped struct {
+	Down map[string][]Probability
+	Up   map[string][]Probability
+	// Width is the serve probability of Up less that of Down.
+	Width float64
+}
+
+// GetGreeks returns the partial derivatives of every market line with respect to p1 and p2 by
+// central differences of the bumped markets, keyed as the markets. Markets should be derived from
+// simulations with common random numbers, see sim.Options.Seed, so that the differences are not
+// swamped by noise. Lines missing from any of the bumped markets, such as lines placed around an
+// expected value that moved, are left out.
+func GetGreeks(p1, p2 Bumped) map[string][]Greek {
+	out := make(map[string][]Greek)
+	for market, probs := range p1.Down {
+		var greeks []Greek
+		for _, down1 := range probs {
+			up1, ok1 := findLine(p1.Up[market], down1.Line)
+			down2, ok2 := findLine(p2.Down[market], down1.Line)
+			up2, ok3 := findLine(p2.Up[market], down1.Line)
+			if !ok1 || !ok2 || !ok3 {
+				continue
+			}
+			greeks = append(greeks, Greek{
+				Market: down1.Market,
+				Line:   down1.Line,
+				P1:     difference(down1, up1, p1.Width),
+				P2:     difference(down2, up2, p2.Width),
+			})
+		}
+		if len(greeks) > 0 {
+			out[market] = greeks
+		}
+	}
+	return out
+}
+
+// difference returns the slope of the probabilities from down to up over width.
+func difference(down, up Probability, width float64) Delta {
+	return Delta{
+		A:    (up.ProbA - down.ProbA) / width,
+		B:    (up.ProbB - down.ProbB) / width,
+		Push: (up.Push - down.Push) / width,
+	}
+}
+
+// findLine returns the Probability quoted on exactly the line.
+func findLine(probs []Probability, line string) (Probability, bool) {
+	for _, p := range probs {
+		if p.Line == line {
+			return p, true
+		}
+	}
+	return Probability{}, false
+}
@@ -0,0 +1,52 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetGreeks(t *testing.T) {
+	ml := func(probA float64) []Probability {
+		return []Probability{{Market: Moneyline, Line: "ml", ProbA: probA, ProbB: 1 - probA}}
+	}
+	totals := func(probA float64, lines ...string) []Probability {
+		var out []Probability
+		for _, line := range lines {
+			out = append(out, Probability{Market: Total, Line: line, ProbA: probA, ProbB: 0.9 - probA, Push: 0.1})
+		}
+		return out
+	}
+	p1 := Bumped{
+		Down:  map[string][]Probability{"Moneyline": ml(0.6), "GameOU": totals(0.5, "22.0", "23.0")},
+		Up:    map[string][]Probability{"Moneyline": ml(0.7), "GameOU": totals(0.4, "22.0", "23.0")},
+		Width: 0.02,
+	}
+	p2 := Bumped{
+		Down:  map[string][]Probability{"Moneyline": ml(0.7), "GameOU": totals(0.45, "22.0")},
+		Up:    map[string][]Probability{"Moneyline": ml(0.66), "GameOU": totals(0.35, "22.0")},
+		Width: 0.01,
+	}
+
+	greeks := GetGreeks(p1, p2)
+	require.Len(t, greeks, 2)
+	require.Len(t, greeks["Moneyline"], 1)
+	g := greeks["Moneyline"][0]
+	assert.Equal(t, Moneyline, g.Market)
+	assert.Equal(t, "ml", g.Line)
+	assert.InDelta(t, 5, g.P1.A, 1e-9)
+	assert.InDelta(t, -5, g.P1.B, 1e-9)
+	assert.InDelta(t, -4, g.P2.A, 1e-9)
+	assert.InDelta(t, 4, g.P2.B, 1e-9)
+	assert.Zero(t, g.P1.Push)
+
+	// 23.0 is missing from the bumps of p2.
+	require.Len(t, greeks["GameOU"], 1)
+	g = greeks["GameOU"][0]
+	assert.Equal(t, "22.0", g.Line)
+	assert.InDelta(t, -5, g.P1.A, 1e-9)
+	assert.InDelta(t, 5, g.P1.B, 1e-9)
+	assert.InDelta(t, -10, g.P2.A, 1e-9)
+	assert.InDelta(t, 0, g.P2.Push, 1e-9)
+}
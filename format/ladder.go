@@ -0,0 +1,128 @@
+package format
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Rounding is the direction odds are rounded in onto a Ladder.
+type Rounding string
+
+const (
+	// RoundHouse rounds to the next shorter price of the ladder, so that rounding never lowers the
+	// margin of the house.
+	RoundHouse Rounding = "house"
+	// RoundNearest rounds to the closest price of the ladder, the shorter one on ties.
+	RoundNearest Rounding = "nearest"
+)
+
+// LadderBand is a range of a Ladder with a fixed increment, up to and including Upto.
+type LadderBand struct {
+	Upto float64 `json:"upto"`
+	Step float64 `json:"step"`
+}
+
+// Ladder holds the decimal odds that can be quoted, in increasing order.
+type Ladder []float64
+
+// betfairBands are the increments of the Betfair exchange price ladder.
+var betfairBands = []LadderBand{
+	{Upto: 2, Step: 0.01},
+	{Upto: 3, Step: 0.02},
+	{Upto: 4, Step: 0.05},
+	{Upto: 6, Step: 0.1},
+	{Upto: 10, Step: 0.2},
+	{Upto: 20, Step: 0.5},
+	{Upto: 30, Step: 1},
+	{Upto: 50, Step: 2},
+	{Upto: 100, Step: 5},
+	{Upto: 1000, Step: 10},
+}
+
+// maxLadderTicks bounds the size of a ladder, which is far above the 350 prices of Betfair.
+const maxLadderTicks = 10000
+
+// NewLadder returns the ladder of the bands, starting one increment above evens. Every band has
+// to end at least one increment above the previous one and the ladder may hold at most
+// maxLadderTicks prices.
+func NewLadder(bands []LadderBand) (Ladder, error) {
+	if len(bands) == 0 {
+		return nil, errors.New("a ladder needs at least one band")
+	}
+	var l Ladder
+	from := 1.0
+	for _, b := range bands {
+		if !(b.Step > 0) || !(b.Upto > from) || math.IsInf(b.Upto, 0) {
+			return nil, fmt.Errorf("invalid ladder band %v:%v: bands must rise with positive steps", b.Upto, b.Step)
+		}
+		ticks := math.Floor((b.Upto-from)/b.Step + 1e-9)
+		if ticks < 1 {
+			return nil, fmt.Errorf("invalid ladder band %v:%v: the step does not fit in the band", b.Upto, b.Step)
+		}
+		if float64(len(l))+ticks > maxLadderTicks {
+			return nil, fmt.Errorf("a ladder may hold at most %d prices", maxLadderTicks)
+		}
+		for i := 1; i <= int(ticks); i++ {
+			// Ticks are rounded to absorb the error of adding up steps.
+			l = append(l, math.Round((from+float64(i)*b.Step)*1e6)/1e6)
+		}
+		from = l[len(l)-1]
+	}
+	return l, nil
+}
+
+// BetfairLadder returns the price ladder of the Betfair exchange, from 1.01 to 1000.
+func BetfairLadder() Ladder {
+	l, err := NewLadder(betfairBands)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// ParseLadder parses a ladder, either "betfair" or bands written as "<upto>:<step>" separated by
+// commas, e.g. "2:0.01,3:0.02,1000:0.05".
+func ParseLadder(s string) (Ladder, error) {
+	if strings.TrimSpace(s) == "betfair" {
+		return BetfairLadder(), nil
+	}
+	var bands []LadderBand
+	for _, b := range strings.Split(s, ",") {
+		uptoStr, stepStr, ok := strings.Cut(strings.TrimSpace(b), ":")
+		upto, errUpto := strconv.ParseFloat(uptoStr, 64)
+		step, errStep := strconv.ParseFloat(stepStr, 64)
+		if !ok || errUpto != nil || errStep != nil {
+			return nil, fmt.Errorf("ladder band %q: expected <upto>:<step>", b)
+		}
+		bands = append(bands, LadderBand{Upto: upto, Step: step})
+	}
+	return NewLadder(bands)
+}
+
+// Round rounds decimal odds onto the ladder in the given direction. Odds outside the ladder are
+// moved to its nearest end.
+func (l Ladder) Round(odds float64, r Rounding) (float64, error) {
+	if len(l) == 0 {
+		return 0, errors.New("empty ladder")
+	}
+	if r != RoundHouse && r != RoundNearest {
+		return 0, fmt.Errorf("unknown rounding %q", r)
+	}
+	i := sort.SearchFloat64s(l, odds-1e-9)
+	switch {
+	case i == len(l):
+		return l[len(l)-1], nil
+	case i == 0 || math.Abs(l[i]-odds) < 1e-9:
+		return l[i], nil
+	}
+
+	lo, hi := l[i-1], l[i]
+	if r == RoundNearest && hi-odds < odds-lo-1e-9 {
+		return hi, nil
+	}
+	return lo, nil
+}
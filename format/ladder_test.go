@@ -0,0 +1,71 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBetfairLadder(t *testing.T) {
+	l := BetfairLadder()
+	assert.Len(t, l, 350)
+	assert.InDelta(t, 1.01, l[0], 1e-9)
+	assert.InDelta(t, 1000, l[len(l)-1], 1e-9)
+	for _, tick := range []float64{1.99, 2, 2.02, 3.05, 4.1, 6.2, 10.5, 21, 32, 55, 110} {
+		assert.Contains(t, l, tick)
+	}
+	for _, notTick := range []float64{2.01, 3.02, 4.05, 6.1, 10.2, 20.5, 31} {
+		assert.NotContains(t, l, notTick)
+	}
+}
+
+func TestParseLadder(t *testing.T) {
+	l, err := ParseLadder("2:0.25, 3:0.5")
+	require.NoError(t, err)
+	assert.Equal(t, Ladder{1.25, 1.5, 1.75, 2, 2.5, 3}, l)
+
+	l, err = ParseLadder("betfair")
+	require.NoError(t, err)
+	assert.Equal(t, BetfairLadder(), l)
+
+	invalid := []string{"", "2", "2:x", "2:0", "2:0.1,1.5:0.1", "Inf:1"}
+	// Bands the step does not fit in and ladders with too many prices.
+	invalid = append(invalid, "1.5:1", "2:0.01,2.5:1", "1000:0.000001")
+	for _, s := range invalid {
+		_, err := ParseLadder(s)
+		assert.Error(t, err, "Expected error for %q", s)
+	}
+}
+
+func TestLadderRound(t *testing.T) {
+	l := BetfairLadder()
+	tests := []struct {
+		odds     float64
+		rounding Rounding
+		expected float64
+	}{
+		{1.917, RoundHouse, 1.91},
+		{1.917, RoundNearest, 1.92},
+		{1.915, RoundNearest, 1.91},
+		{2.57, RoundHouse, 2.56},
+		{2.57, RoundNearest, 2.56},
+		{2.575, RoundNearest, 2.58},
+		{2.5, RoundHouse, 2.5},
+		{7.3, RoundHouse, 7.2},
+		{7.3, RoundNearest, 7.2},
+		{7.35, RoundNearest, 7.4},
+		{1.001, RoundHouse, 1.01},
+		{1001, RoundNearest, 1000},
+	}
+	for _, tt := range tests {
+		got, err := l.Round(tt.odds, tt.rounding)
+		require.NoError(t, err)
+		assert.InDelta(t, tt.expected, got, 1e-9, "%v rounded %s", tt.odds, tt.rounding)
+	}
+
+	_, err := l.Round(2, "up")
+	assert.Error(t, err, "Expected error for an unknown rounding")
+	_, err = Ladder{}.Round(2, RoundHouse)
+	assert.Error(t, err, "Expected error for an empty ladder")
+}
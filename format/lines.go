@@ -0,0 +1,197 @@
+package format
+
+import (
+	"fmt"
+	"gotennis/sim"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// LineType selects the lines handicap and total markets are quoted on.
+type LineType string
+
+const (
+	// HalfLines are x.5 lines only, which cannot push.
+	HalfLines LineType = "half"
+	// WholeLines adds the x.0 lines, on which the stake is returned when the result lands on the line.
+	WholeLines LineType = "whole"
+	// QuarterLines adds the Asian x.25 and x.75 lines, which split the stake over the two
+	// neighbouring lines, e.g. 22.75 is half on 22.5 and half on 23.0.
+	QuarterLines LineType = "quarter"
+)
+
+// LineOptions selects the lines of the handicap and total markets. Lines given explicitly replace
+// the generated range of their market.
+type LineOptions struct {
+	Type          LineType  `json:"type"`
+	GameHandicaps []float64 `json:"gameHandicaps,omitempty"`
+	GameTotals    []float64 `json:"gameTotals,omitempty"`
+	SetHandicaps  []float64 `json:"setHandicaps,omitempty"`
+	// Smooth makes the game handicap and total ladders coherent, see SmoothLines.
+	Smooth bool `json:"smooth,omitempty"`
+	// Extra holds lines of the markets whose lines are generated around the simulated results,
+	// keyed by their name as returned by Registry.Probabilities, see AddLine. They are quoted
+	// besides the generated lines.
+	Extra map[string][]float64 `json:"extra,omitempty"`
+}
+
+// AddLine quotes a line of the named market, written as in its Probabilities, e.g. "22.5" for
+// GameOU, "A/over 22.5" for Combos.WinAndTotal or "21-23" for GameTotalBands. Lines of
+// GameHandicaps, GameOU and SetHandicaps are added to their explicit lines, and those of the
+// markets with lines generated around the simulated results to Extra. It reports false for
+// markets whose lines cannot be chosen and for lines the market cannot have.
+func (o *LineOptions) AddLine(market, line string) bool {
+	switch market {
+	case "GameHandicaps", "GameOU", "SetHandicaps":
+		lines, err := ParseLines(line)
+		if err != nil || len(lines) != 1 {
+			return false
+		}
+		switch market {
+		case "GameHandicaps":
+			o.GameHandicaps = append(o.GameHandicaps, lines[0])
+		case "GameOU":
+			o.GameTotals = append(o.GameTotals, lines[0])
+		default:
+			o.SetHandicaps = append(o.SetHandicaps, lines[0])
+		}
+		return true
+	case "PlayerAGameOU", "PlayerBGameOU", "Breaks.TotalBreaks", "Breaks.BreaksByA", "Breaks.BreaksByB":
+		return o.addExtra(market, line, isCountLine)
+	case "Combos.WinAndTotal":
+		side, total, ok := strings.Cut(line, " ")
+		if !ok || !slices.Contains([]string{"A/over", "A/under", "B/over", "B/under"}, side) {
+			return false
+		}
+		return o.addExtra(market, total, isCountLine)
+	case "GameTotalPMF":
+		return o.addExtra(market, line, isCount)
+	case "GameTotalBands":
+		loStr, hiStr, ok := strings.Cut(line, "-")
+		hi, err := strconv.Atoi(hiStr)
+		if !ok || err != nil || strconv.Itoa(hi-TOTAL_BAND_WIDTH+1) != loStr {
+			return false
+		}
+		return o.addExtra(market, loStr, isCount)
+	default:
+		return false
+	}
+}
+
+// addExtra parses the line and adds it to the extra lines of the market when valid holds.
+func (o *LineOptions) addExtra(market, line string, valid func(float64) bool) bool {
+	v, err := strconv.ParseFloat(line, 64)
+	if err != nil || !valid(v) {
+		return false
+	}
+	if o.Extra == nil {
+		o.Extra = make(map[string][]float64)
+	}
+	o.Extra[market] = append(o.Extra[market], v)
+	return true
+}
+
+// isCountLine reports whether the line is an over/under line on a count, x.5 and positive.
+func isCountLine(line float64) bool {
+	return line > 0 && math.Mod(line, 1) == 0.5
+}
+
+// isCount reports whether the line is a count, a whole number that is not negative.
+func isCount(line float64) bool {
+	return line >= 0 && math.Mod(line, 1) == 0
+}
+
+// withExtraLines returns the generated lines together with the extra ones, in increasing order
+// and without duplicates.
+func withExtraLines(lines, extra []float64) []float64 {
+	out := append(slices.Clone(lines), extra...)
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+// ParseLines parses a comma separated list of lines such as "21.5,22,22.75". Every line has to be
+// a multiple of a quarter.
+func ParseLines(s string) ([]float64, error) {
+	var out []float64
+	for _, field := range strings.Split(s, ",") {
+		line, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil || math.IsInf(line, 0) || math.IsNaN(line) {
+			return nil, fmt.Errorf("invalid line %q", field)
+		}
+		if math.Mod(line*4, 1) != 0 {
+			return nil, fmt.Errorf("invalid line %q: must be a multiple of 0.25", field)
+		}
+		out = append(out, line)
+	}
+	return out, nil
+}
+
+// lineStep returns the distance between consecutive lines of the given type.
+func lineStep(lines LineType) float64 {
+	switch lines {
+	case HalfLines:
+		return 1
+	case WholeLines:
+		return 0.5
+	case QuarterLines:
+		return 0.25
+	default:
+		return 1
+	}
+}
+
+// FormatLine renders a line with one decimal, or two for quarter lines.
+func FormatLine(line float64) string {
+	if isQuarterLine(line) {
+		return strconv.FormatFloat(line, 'f', 2, 64)
+	}
+	return strconv.FormatFloat(line, 'f', 1, 64)
+}
+
+func isQuarterLine(line float64) bool {
+	return math.Mod(line*2, 1) != 0
+}
+
+// getLineProbability returns the Probability of a handicap or total line, where 'margin' is how far
+// A (or the over) finishes above the line in a match. A result on the line pushes, and quarter
+// lines are settled as half the stake on each neighbouring line.
+func getLineProbability(
+	results []sim.SimulatedMatch,
+	market Market,
+	line float64,
+	margin func(m sim.SimulatedMatch, line float64) float64,
+) Probability {
+	if isQuarterLine(line) {
+		lower := getLineProbability(results, market, line-0.25, margin)
+		upper := getLineProbability(results, market, line+0.25, margin)
+		return Probability{
+			Market: market,
+			Line:   FormatLine(line),
+			ProbA:  (lower.ProbA + upper.ProbA) / 2,
+			ProbB:  (lower.ProbB + upper.ProbB) / 2,
+			Push:   (lower.Push + upper.Push) / 2,
+		}
+	}
+
+	won, pushed := 0, 0
+	for _, m := range results {
+		switch d := margin(m, line); {
+		case d > 0:
+			won++
+		case d == 0:
+			pushed++
+		}
+	}
+
+	probA := float64(won) / float64(len(results))
+	push := float64(pushed) / float64(len(results))
+	return Probability{
+		Market: market,
+		Line:   FormatLine(line),
+		ProbA:  probA,
+		ProbB:  1 - probA - push,
+		Push:   push,
+	}
+}
@@ -0,0 +1,198 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinePush(t *testing.T) {
+	// game margins +3, +9, -7, 0 and totals 29, 15, 17, 32
+	sim := createTestSimulatedMatches()
+
+	tests := []struct {
+		name     string
+		prob     Probability
+		line     string
+		expected Probability
+	}{
+		{"Half line total", getGameTotal(sim, 29.5), "29.5", Probability{ProbA: 0.25, ProbB: 0.75}},
+		{"Whole line total", getGameTotal(sim, 29), "29.0", Probability{ProbA: 0.25, ProbB: 0.5, Push: 0.25}},
+		{"Quarter line total", getGameTotal(sim, 29.25), "29.25", Probability{ProbA: 0.25, ProbB: 0.625, Push: 0.125}},
+		{"Quarter line total below", getGameTotal(sim, 28.75), "28.75", Probability{ProbA: 0.375, ProbB: 0.5, Push: 0.125}},
+		{"Level handicap", getGameHandicap(sim, 0), "0.0", Probability{ProbA: 0.5, ProbB: 0.25, Push: 0.25}},
+		{"Quarter handicap", getGameHandicap(sim, -0.25), "-0.25", Probability{ProbA: 0.5, ProbB: 0.375, Push: 0.125}},
+		{"Whole handicap", getGameHandicap(sim, -3), "-3.0", Probability{ProbA: 0.25, ProbB: 0.5, Push: 0.25}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.line, tt.prob.Line, "Expected line %s", tt.line)
+			assert.InDelta(t, tt.expected.ProbA, tt.prob.ProbA, 0.001, "Expected ProbA %f", tt.expected.ProbA)
+			assert.InDelta(t, tt.expected.ProbB, tt.prob.ProbB, 0.001, "Expected ProbB %f", tt.expected.ProbB)
+			assert.InDelta(t, tt.expected.Push, tt.prob.Push, 0.001, "Expected Push %f", tt.expected.Push)
+		})
+	}
+}
+
+func TestLineTypes(t *testing.T) {
+	sim := createTestSimulatedMatches()
+
+	tests := []struct {
+		lines       LineType
+		expectedLen int
+		second      string
+	}{
+		{HalfLines, int(2*BO3_GAME_SPREAD + 1), "-7.5"},
+		{WholeLines, int(4*BO3_GAME_SPREAD + 1), "-8.0"},
+		{QuarterLines, int(8*BO3_GAME_SPREAD + 1), "-8.25"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.lines), func(t *testing.T) {
+			handicaps := GetGameHandicapLines(sim, 3, tt.lines)
+			require.Len(t, handicaps, tt.expectedLen, "Expected %d handicaps", tt.expectedLen)
+			assert.Equal(t, tt.second, handicaps[1].Line)
+			for _, prob := range append(handicaps, GetGameTotalLines(sim, 3, tt.lines)...) {
+				assert.InDelta(t, 1.0, prob.ProbA+prob.ProbB+prob.Push, 0.001, "Line %s should sum to 1", prob.Line)
+			}
+		})
+	}
+}
+
+func TestParseLines(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    []float64
+		expectError bool
+	}{
+		{"22.5", []float64{22.5}, false},
+		{"21.5, 22, 22.75", []float64{21.5, 22, 22.75}, false},
+		{"-3.5,-4.5", []float64{-3.5, -4.5}, false},
+		{"22.3", nil, true},
+		{"22.5,", nil, true},
+		{"over", nil, true},
+		{"Inf", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			lines, err := ParseLines(tt.input)
+			if tt.expectError {
+				assert.Error(t, err, "Expected error for %q", tt.input)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, lines)
+		})
+	}
+}
+
+func TestAddLine(t *testing.T) {
+	var o LineOptions
+	for _, l := range []struct{ market, line string }{
+		{"GameHandicaps", "-4.5"},
+		{"GameOU", "22.5"},
+		{"GameOU", "22.75"},
+		{"SetHandicaps", "1.5"},
+		{"PlayerAGameOU", "14.5"},
+		{"Breaks.TotalBreaks", "7.5"},
+		{"Combos.WinAndTotal", "B/under 30.5"},
+		{"Combos.WinAndTotal", "A/over 30.5"},
+		{"GameTotalPMF", "40"},
+		{"GameTotalBands", "36-38"},
+	} {
+		assert.True(t, o.AddLine(l.market, l.line), "Expected %s %s to be added", l.market, l.line)
+	}
+	for _, l := range []struct{ market, line string }{
+		{"Moneyline", "ml"},
+		{"GameOU", "22.3"},
+		{"GameOU", "x"},
+		{"PlayerBGameOU", "14"},
+		{"Breaks.BreaksByA", "-0.5"},
+		{"Combos.WinAndTotal", "C/over 30.5"},
+		{"Combos.WinAndTotal", "A/over 30"},
+		{"GameTotalPMF", "22.5"},
+		{"GameTotalBands", "36-39"},
+	} {
+		assert.False(t, o.AddLine(l.market, l.line), "Expected %s %s not to be added", l.market, l.line)
+	}
+	assert.Equal(t, LineOptions{
+		GameHandicaps: []float64{-4.5},
+		GameTotals:    []float64{22.5, 22.75},
+		SetHandicaps:  []float64{1.5},
+		Extra: map[string][]float64{
+			"PlayerAGameOU":      {14.5},
+			"Breaks.TotalBreaks": {7.5},
+			"Combos.WinAndTotal": {30.5, 30.5},
+			"GameTotalPMF":       {40},
+			"GameTotalBands":     {36},
+		},
+	}, o)
+}
+
+func TestExtraLines(t *testing.T) {
+	results := createTestSimulatedMatches()
+	var opts LineOptions
+	for _, l := range []struct{ market, line string }{
+		{"PlayerAGameOU", "30.5"},
+		{"PlayerAGameOU", "0.5"},
+		{"Combos.WinAndTotal", "A/over 50.5"},
+		{"GameTotalPMF", "60"},
+		{"GameTotalBands", "60-62"},
+	} {
+		require.True(t, opts.AddLine(l.market, l.line))
+	}
+	r := DefaultRegistry()
+	sel, err := r.Select(nil)
+	require.NoError(t, err)
+	probs := r.Probabilities(r.Derive(results, MarketConfig{BestOf: 3, Lines: opts}, sel))
+
+	find := func(market, line string) Probability {
+		t.Helper()
+		for _, p := range probs[market] {
+			if p.Line == line {
+				return p
+			}
+		}
+		require.Failf(t, "Line not quoted", "%s %s", market, line)
+		return Probability{}
+	}
+	// The lines far from the results are quoted besides the generated ones.
+	assert.Equal(t, getPlayerGameTotal(results, 30.5, true), find("PlayerAGameOU", "30.5"))
+	assert.InDelta(t, 1, find("PlayerAGameOU", "0.5").ProbA, 1e-9)
+	assert.Equal(t, getPlayerGameTotal(results, 15.5, true), find("PlayerAGameOU", "15.5"))
+	assert.InDelta(t, 0, find("Combos.WinAndTotal", "A/over 50.5").ProbA, 1e-9)
+	assert.InDelta(t, 0.25, find("Combos.WinAndTotal", "B/under 50.5").ProbA, 1e-9)
+	assert.InDelta(t, 0, find("GameTotalPMF", "60").ProbA, 1e-9)
+	assert.InDelta(t, 0, find("GameTotalBands", "60-62").ProbA, 1e-9)
+
+	totals := probs["PlayerAGameOU"]
+	assert.Equal(t, "0.5", totals[0].Line, "Expected the lines in order")
+	assert.Equal(t, "30.5", totals[len(totals)-1].Line, "Expected the lines in order")
+}
+
+func TestGetLinesAt(t *testing.T) {
+	sim := createTestSimulatedMatches()
+
+	totals := GetGameTotalsAt(sim, []float64{29.5, 22, 29.25})
+	require.Len(t, totals, 3, "Expected only the requested lines")
+	assert.Equal(t, getGameTotal(sim, 29.5), totals[0])
+	assert.Equal(t, getGameTotal(sim, 22), totals[1])
+	assert.Equal(t, getGameTotal(sim, 29.25), totals[2])
+
+	handicaps := GetGameHandicapsAt(sim, []float64{-3.5})
+	require.Len(t, handicaps, 1, "Expected only the requested line")
+	assert.Equal(t, getGameHandicap(sim, -3.5), handicaps[0])
+
+	// set margins +1, +2, -2, +1
+	sets := GetSetHandicapsAt(sim, []float64{-1, -1.5})
+	require.Len(t, sets, 2, "Expected only the requested lines")
+	assert.Equal(t, "-1.0", sets[0].Line)
+	assert.InDelta(t, 0.25, sets[0].ProbA, 0.001, "A won by two sets once")
+	assert.InDelta(t, 0.5, sets[0].Push, 0.001, "A won by one set twice")
+	assert.InDelta(t, 0.25, sets[1].ProbA, 0.001, "A covered -1.5 once")
+
+	assert.Empty(t, GetGameTotalsAt(sim, nil), "Expected no lines")
+}
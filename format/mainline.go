@@ -0,0 +1,87 @@
+package format
+
+import (
+	"math"
+	"slices"
+	"strconv"
+)
+
+// MainLine is the line of a market closest to a 50/50 price, together with the fair line at which
+// the market would be exactly 50/50.
+type MainLine struct {
+	Market Market  `json:"Market"`
+	Line   string  `json:"Line"`
+	ProbA  float64 `json:"probA"`
+	ProbB  float64 `json:"probB"`
+	Push   float64 `json:"push,omitempty"`
+	// FairLine is interpolated linearly between the two lines either side of 50/50. It is the main
+	// line itself when every line is on the same side.
+	FairLine float64 `json:"fairLine"`
+}
+
+// linePoint is a line together with the probability of A (or the over) ignoring pushes.
+type linePoint struct {
+	prob Probability
+	line float64
+	p    float64
+}
+
+// GetMainLine finds the main line and fair line of a handicap or total market. It returns false
+// when there is no numeric line to choose from.
+func GetMainLine(probs []Probability) (MainLine, bool) {
+	points := make([]linePoint, 0, len(probs))
+	for _, prob := range probs {
+		line, err := strconv.ParseFloat(prob.Line, 64)
+		if err != nil || prob.ProbA+prob.ProbB == 0 {
+			continue
+		}
+		points = append(points, linePoint{prob, line, prob.ProbA / (prob.ProbA + prob.ProbB)})
+	}
+	if len(points) == 0 {
+		return MainLine{}, false
+	}
+	slices.SortFunc(points, func(a, b linePoint) int {
+		switch {
+		case a.line < b.line:
+			return -1
+		case a.line > b.line:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	best := points[0]
+	for _, pt := range points[1:] {
+		if math.Abs(pt.p-0.5) < math.Abs(best.p-0.5) {
+			best = pt
+		}
+	}
+
+	return MainLine{
+		Market:   best.prob.Market,
+		Line:     best.prob.Line,
+		ProbA:    best.prob.ProbA,
+		ProbB:    best.prob.ProbB,
+		Push:     best.prob.Push,
+		FairLine: fairLine(points, best.line),
+	}, true
+}
+
+// fairLine interpolates the line at which the probability crosses 0.5, falling back to 'mainLine'
+// when it never does.
+func fairLine(points []linePoint, mainLine float64) float64 {
+	for i := range len(points) - 1 {
+		lo, hi := points[i], points[i+1]
+		if lo.p == 0.5 {
+			return lo.line
+		}
+		if (lo.p-0.5)*(hi.p-0.5) < 0 {
+			return lo.line + (0.5-lo.p)*(hi.line-lo.line)/(hi.p-lo.p)
+		}
+	}
+	if last := points[len(points)-1]; last.p == 0.5 {
+		return last.line
+	}
+	return mainLine
+}
@@ -0,0 +1,88 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMainLine(t *testing.T) {
+	tests := []struct {
+		name         string
+		probs        []Probability
+		expectedLine string
+		expectedFair float64
+	}{
+		{
+			name: "Totals crossing between lines",
+			probs: []Probability{
+				{Market: Total, Line: "21.5", ProbA: 0.6, ProbB: 0.4},
+				{Market: Total, Line: "22.5", ProbA: 0.45, ProbB: 0.55},
+				{Market: Total, Line: "23.5", ProbA: 0.3, ProbB: 0.7},
+			},
+			expectedLine: "22.5",
+			expectedFair: 22.1667,
+		},
+		{
+			name: "Unsorted handicaps",
+			probs: []Probability{
+				{Market: Handicap, Line: "1.5", ProbA: 0.7, ProbB: 0.3},
+				{Market: Handicap, Line: "-2.5", ProbA: 0.3, ProbB: 0.7},
+				{Market: Handicap, Line: "-0.5", ProbA: 0.52, ProbB: 0.48},
+			},
+			expectedLine: "-0.5",
+			expectedFair: -0.6818,
+		},
+		{
+			name: "Exactly 50/50",
+			probs: []Probability{
+				{Market: Total, Line: "2.5", ProbA: 0.5, ProbB: 0.5},
+			},
+			expectedLine: "2.5",
+			expectedFair: 2.5,
+		},
+		{
+			name: "Every line on one side",
+			probs: []Probability{
+				{Market: Total, Line: "12.5", ProbA: 0.9, ProbB: 0.1},
+				{Market: Total, Line: "13.5", ProbA: 0.8, ProbB: 0.2},
+			},
+			expectedLine: "13.5",
+			expectedFair: 13.5,
+		},
+		{
+			name: "Pushes are ignored",
+			probs: []Probability{
+				{Market: Total, Line: "22.0", ProbA: 0.4, ProbB: 0.4, Push: 0.2},
+				{Market: Total, Line: "22.5", ProbA: 0.4, ProbB: 0.6},
+			},
+			expectedLine: "22.0",
+			expectedFair: 22.0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, ok := GetMainLine(tt.probs)
+			require.True(t, ok)
+			assert.Equal(t, tt.expectedLine, line.Line, "Expected main line %s", tt.expectedLine)
+			assert.InDelta(t, tt.expectedFair, line.FairLine, 0.001, "Expected fair line %f", tt.expectedFair)
+		})
+	}
+
+	_, ok := GetMainLine(nil)
+	assert.False(t, ok, "Expected no main line without lines")
+	_, ok = GetMainLine([]Probability{{Market: OddEven, Line: "odd", ProbA: 0.5, ProbB: 0.5}})
+	assert.False(t, ok, "Expected no main line without numeric lines")
+}
+
+func TestGetMainLineSimulated(t *testing.T) {
+	sim := createTestSimulatedMatches()
+	// totals 15, 17, 29, 32 put the median between 17 and 29
+	line, ok := GetMainLine(GetGameTotals(sim, 3))
+	require.True(t, ok)
+	assert.InDelta(t, 0.5, line.ProbA, 0.001, "Expected a 50/50 main line")
+	assert.GreaterOrEqual(t, line.FairLine, 17.0)
+	assert.LessOrEqual(t, line.FairLine, 29.0)
+}
@@ -0,0 +1,231 @@
+package format
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// OddsFormat is the notation odds are quoted in.
+type OddsFormat string
+
+const (
+	Decimal    OddsFormat = "decimal"
+	American   OddsFormat = "american"
+	Fractional OddsFormat = "fractional"
+	HongKong   OddsFormat = "hongkong"
+)
+
+// MarginMethod is the way the bookmaker margin is spread over the outcomes of a market.
+type MarginMethod string
+
+const (
+	// Proportional scales every implied probability by the same factor.
+	Proportional MarginMethod = "proportional"
+	// Power raises every probability to the same power below one, loading more margin on longshots.
+	Power MarginMethod = "power"
+	// FavouriteLongshot spreads the margin in proportion to 1-p, the probability of the outcome
+	// losing, so that an outcome carries more margin the longer its odds.
+	FavouriteLongshot MarginMethod = "favourite-longshot"
+)
+
+const (
+	// MinDecimalOdds and MaxDecimalOdds bound the quoted odds of near certain and near impossible outcomes.
+	MinDecimalOdds float64 = 1.001
+	MaxDecimalOdds float64 = 1001
+	// maxFractionalDenominator is the largest denominator used when rendering fractional odds.
+	maxFractionalDenominator = 100
+)
+
+// OddsConfig describes how fair probabilities are turned into quoted odds.
+type OddsConfig struct {
+	Format OddsFormat `json:"format"`
+	// Margin is the overround added to the market, e.g. 0.05 for a 105% book.
+	Margin float64      `json:"margin"`
+	Method MarginMethod `json:"method"`
+	// Ladder, when set, rounds the decimal odds onto its prices in the Rounding direction before
+	// they are formatted.
+	Ladder   Ladder   `json:"ladder,omitempty"`
+	Rounding Rounding `json:"rounding,omitempty"`
+}
+
+// Price is a Probability quoted as odds.
+type Price struct {
+	Market Market     `json:"Market"`
+	Line   string     `json:"Line"`
+	Format OddsFormat `json:"format"`
+	OddsA  string     `json:"oddsA"`
+	OddsB  string     `json:"oddsB"`
+	// RoundingA and RoundingB are the rounded less the unrounded decimal odds of each side when
+	// the odds are rounded onto a ladder.
+	RoundingA float64 `json:"roundingA,omitempty"`
+	RoundingB float64 `json:"roundingB,omitempty"`
+}
+
+// ApplyMargin returns the implied probabilities of quoting the fair probabilities with the given
+// overround. The result sums to 1+margin.
+func ApplyMargin(probs []float64, margin float64, method MarginMethod) ([]float64, error) {
+	if margin < 0 || margin >= float64(len(probs)-1) {
+		return nil, fmt.Errorf("margin must be between 0 and %d", len(probs)-1)
+	}
+
+	out := make([]float64, len(probs))
+	switch method {
+	case Proportional:
+		for i, p := range probs {
+			out[i] = p * (1 + margin)
+		}
+	case Power:
+		k := powerExponent(probs, 1+margin)
+		for i, p := range probs {
+			out[i] = math.Pow(p, k)
+		}
+	case FavouriteLongshot:
+		weight := 0.0
+		for _, p := range probs {
+			weight += 1 - p
+		}
+		for i, p := range probs {
+			if weight == 0 {
+				out[i] = p + margin/float64(len(probs))
+				continue
+			}
+			out[i] = p + margin*(1-p)/weight
+		}
+	default:
+		return nil, fmt.Errorf("unknown margin method %q", method)
+	}
+	return out, nil
+}
+
+// powerExponent finds k such that the probabilities raised to the power k sum to 'total'.
+func powerExponent(probs []float64, total float64) float64 {
+	sum := func(k float64) float64 {
+		s := 0.0
+		for _, p := range probs {
+			s += math.Pow(p, k)
+		}
+		return s
+	}
+
+	lo, hi := 0.0, 1.0
+	for range 100 {
+		mid := (lo + hi) / 2
+		if sum(mid) > total {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// DecimalOdds converts an implied probability to decimal odds, bounded by MinDecimalOdds and MaxDecimalOdds.
+func DecimalOdds(p float64) float64 {
+	if p <= 1/MaxDecimalOdds {
+		return MaxDecimalOdds
+	}
+	return math.Max(MinDecimalOdds, math.Min(MaxDecimalOdds, 1/p))
+}
+
+// AmericanOdds converts decimal odds to American odds.
+func AmericanOdds(decimal float64) float64 {
+	if decimal >= 2 {
+		return (decimal - 1) * 100
+	}
+	return -100 / (decimal - 1)
+}
+
+// HongKongOdds converts decimal odds to Hong Kong odds.
+func HongKongOdds(decimal float64) float64 {
+	return decimal - 1
+}
+
+// FractionalOdds converts decimal odds to the closest fraction with a denominator of at most 100.
+func FractionalOdds(decimal float64) (int, int) {
+	x := decimal - 1
+	bestNum, bestDen := int(math.Round(x)), 1
+	bestErr := math.Abs(x - float64(bestNum))
+	for den := 2; den <= maxFractionalDenominator; den++ {
+		num := int(math.Round(x * float64(den)))
+		if err := math.Abs(x - float64(num)/float64(den)); err < bestErr-1e-12 {
+			bestNum, bestDen, bestErr = num, den, err
+		}
+	}
+	return bestNum, bestDen
+}
+
+// FormatOdds renders decimal odds in the given format.
+func FormatOdds(decimal float64, f OddsFormat) (string, error) {
+	switch f {
+	case Decimal:
+		return strconv.FormatFloat(decimal, 'f', 2, 64), nil
+	case American:
+		american := math.Round(AmericanOdds(decimal))
+		if american > 0 {
+			return "+" + strconv.FormatFloat(american, 'f', 0, 64), nil
+		}
+		return strconv.FormatFloat(american, 'f', 0, 64), nil
+	case Fractional:
+		num, den := FractionalOdds(decimal)
+		return strconv.Itoa(num) + "/" + strconv.Itoa(den), nil
+	case HongKong:
+		return strconv.FormatFloat(HongKongOdds(decimal), 'f', 2, 64), nil
+	default:
+		return "", fmt.Errorf("unknown odds format %q", f)
+	}
+}
+
+// GetPrice quotes a Probability as odds according to cfg. The probability of a push is taken out
+// first, as the stake is returned in that case.
+func GetPrice(p Probability, cfg OddsConfig) (Price, error) {
+	probA, probB := p.ProbA, p.ProbB
+	if p.Push > 0 && p.Push < 1 {
+		probA, probB = probA/(1-p.Push), probB/(1-p.Push)
+	}
+	implied, err := ApplyMargin([]float64{probA, probB}, cfg.Margin, cfg.Method)
+	if err != nil {
+		return Price{}, err
+	}
+
+	price := Price{Market: p.Market, Line: p.Line, Format: cfg.Format}
+	decimalA, decimalB := DecimalOdds(implied[0]), DecimalOdds(implied[1])
+	if cfg.Ladder != nil {
+		if decimalA, price.RoundingA, err = roundOdds(decimalA, cfg); err != nil {
+			return Price{}, err
+		}
+		if decimalB, price.RoundingB, err = roundOdds(decimalB, cfg); err != nil {
+			return Price{}, err
+		}
+	}
+
+	if price.OddsA, err = FormatOdds(decimalA, cfg.Format); err != nil {
+		return Price{}, err
+	}
+	if price.OddsB, err = FormatOdds(decimalB, cfg.Format); err != nil {
+		return Price{}, err
+	}
+	return price, nil
+}
+
+// GetPrices quotes every Probability as odds according to cfg.
+func GetPrices(probs []Probability, cfg OddsConfig) ([]Price, error) {
+	out := make([]Price, 0, len(probs))
+	for _, p := range probs {
+		price, err := GetPrice(p, cfg)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, price)
+	}
+	return out, nil
+}
+
+// roundOdds rounds decimal odds onto the ladder of cfg and returns them with the rounding error.
+func roundOdds(decimal float64, cfg OddsConfig) (float64, float64, error) {
+	rounded, err := cfg.Ladder.Round(decimal, cfg.Rounding)
+	if err != nil {
+		return 0, 0, err
+	}
+	return rounded, rounded - decimal, nil
+}
@@ -0,0 +1,205 @@
+package format
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyMargin(t *testing.T) {
+	tests := []struct {
+		name   string
+		probs  []float64
+		margin float64
+		method MarginMethod
+	}{
+		{"Proportional two-way", []float64{0.7, 0.3}, 0.05, Proportional},
+		{"Power two-way", []float64{0.7, 0.3}, 0.05, Power},
+		{"Favourite-longshot two-way", []float64{0.7, 0.3}, 0.05, FavouriteLongshot},
+		{"Power three-way", []float64{0.5, 0.3, 0.2}, 0.1, Power},
+		{"Favourite-longshot three-way", []float64{0.5, 0.3, 0.2}, 0.1, FavouriteLongshot},
+		{"No margin", []float64{0.6, 0.4}, 0, Power},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			implied, err := ApplyMargin(tt.probs, tt.margin, tt.method)
+			require.NoError(t, err)
+			require.Len(t, implied, len(tt.probs))
+			total := 0.0
+			for i, q := range implied {
+				assert.GreaterOrEqual(t, q, tt.probs[i], "implied probability should not be below the fair one")
+				total += q
+			}
+			assert.InDelta(t, 1+tt.margin, total, 1e-9, "implied probabilities should sum to 1+margin")
+		})
+	}
+}
+
+func TestApplyMarginLoadsLongshots(t *testing.T) {
+	probs := []float64{0.8, 0.2}
+	proportional, err := ApplyMargin(probs, 0.06, Proportional)
+	require.NoError(t, err)
+
+	for _, method := range []MarginMethod{Power, FavouriteLongshot} {
+		t.Run(string(method), func(t *testing.T) {
+			implied, err := ApplyMargin(probs, 0.06, method)
+			require.NoError(t, err)
+			assert.Greater(t, implied[1], proportional[1], "longshot should carry more margin than proportional")
+			assert.Less(t, implied[0], proportional[0], "favourite should carry less margin than proportional")
+		})
+	}
+
+	t.Run("Two-way favourite-longshot", func(t *testing.T) {
+		implied, err := ApplyMargin(probs, 0.06, FavouriteLongshot)
+		require.NoError(t, err)
+		favourite, longshot := implied[0]-probs[0], implied[1]-probs[1]
+		assert.Greater(t, longshot, favourite, "longshot should carry more of the margin than the favourite")
+		assert.Greater(t, longshot/probs[1], favourite/probs[0], "longshot should carry more relative margin")
+		assert.InDelta(t, 0.048, longshot, 1e-9, "longshot should carry the margin in proportion to 1-p")
+	})
+}
+
+func TestApplyMarginErrors(t *testing.T) {
+	_, err := ApplyMargin([]float64{0.5, 0.5}, -0.01, Proportional)
+	assert.Error(t, err, "Expected error for negative margin")
+
+	_, err = ApplyMargin([]float64{0.5, 0.5}, 1, Proportional)
+	assert.Error(t, err, "Expected error for margin of 1 on a two-way market")
+
+	_, err = ApplyMargin([]float64{0.5, 0.5}, 0.05, MarginMethod("flat"))
+	assert.Error(t, err, "Expected error for unknown method")
+}
+
+func TestOddsConversions(t *testing.T) {
+	tests := []struct {
+		name      string
+		decimal   float64
+		american  float64
+		hongKong  float64
+		numerator int
+		denom     int
+	}{
+		{"Evens", 2.0, 100, 1.0, 1, 1},
+		{"Odds on", 1.5, -200, 0.5, 1, 2},
+		{"Odds against", 3.5, 250, 2.5, 5, 2},
+		{"Short favourite", 1.25, -400, 0.25, 1, 4},
+		{"Ten to eleven", 1.0 + 10.0/11.0, -110, 10.0 / 11.0, 10, 11},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.american, AmericanOdds(tt.decimal), 1e-9, "American odds")
+			assert.InDelta(t, tt.hongKong, HongKongOdds(tt.decimal), 1e-9, "Hong Kong odds")
+			num, den := FractionalOdds(tt.decimal)
+			assert.Equal(t, tt.numerator, num, "Fractional numerator")
+			assert.Equal(t, tt.denom, den, "Fractional denominator")
+		})
+	}
+}
+
+func TestDecimalOdds(t *testing.T) {
+	assert.InDelta(t, 2.0, DecimalOdds(0.5), 1e-9, "Expected evens for 0.5")
+	assert.InDelta(t, 4.0, DecimalOdds(0.25), 1e-9, "Expected 4.0 for 0.25")
+	assert.Equal(t, MaxDecimalOdds, DecimalOdds(0), "Impossible outcome should be capped")
+	assert.Equal(t, MinDecimalOdds, DecimalOdds(1.05), "Certain outcome should be capped")
+}
+
+func TestFormatOdds(t *testing.T) {
+	tests := []struct {
+		name     string
+		decimal  float64
+		format   OddsFormat
+		expected string
+	}{
+		{"Decimal", 1.909, Decimal, "1.91"},
+		{"American positive", 2.5, American, "+150"},
+		{"American negative", 1.5, American, "-200"},
+		{"Fractional", 3.5, Fractional, "5/2"},
+		{"Hong Kong", 1.8, HongKong, "0.80"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := FormatOdds(tt.decimal, tt.format)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+
+	_, err := FormatOdds(2, OddsFormat("malay"))
+	assert.Error(t, err, "Expected error for unknown format")
+}
+
+func TestGetPrices(t *testing.T) {
+	sim := createTestSimulatedMatches()
+	probs := GetGameTotals(sim, 3)
+
+	prices, err := GetPrices(probs, OddsConfig{Format: Decimal, Margin: 0.05, Method: Proportional})
+	require.NoError(t, err)
+	require.Len(t, prices, len(probs))
+	for i, price := range prices {
+		assert.Equal(t, probs[i].Market, price.Market, "Market should be kept")
+		assert.Equal(t, probs[i].Line, price.Line, "Line should be kept")
+		assert.Equal(t, Decimal, price.Format, "Format should be set")
+		assert.NotEmpty(t, price.OddsA, "OddsA should be set")
+		assert.NotEmpty(t, price.OddsB, "OddsB should be set")
+	}
+
+	ml, err := GetPrice(Probability{Market: Moneyline, Line: "ml", ProbA: 0.5, ProbB: 0.5}, OddsConfig{
+		Format: Decimal,
+		Method: Proportional,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "2.00", ml.OddsA, "Fair evens should price at 2.00")
+	assert.Equal(t, "2.00", ml.OddsB, "Fair evens should price at 2.00")
+
+	// 0.25 over, 0.5 under and 0.25 push prices as if the push had not been possible
+	whole, err := GetPrice(getGameTotal(sim, 29), OddsConfig{Format: Decimal, Method: Proportional})
+	require.NoError(t, err)
+	assert.Equal(t, "3.00", whole.OddsA, "Push should be taken out of the over price")
+	assert.Equal(t, "1.50", whole.OddsB, "Push should be taken out of the under price")
+
+	_, err = GetPrices(probs, OddsConfig{Format: Decimal, Method: MarginMethod("flat")})
+	assert.Error(t, err, "Expected error for unknown method")
+}
+
+func TestGetPriceLadder(t *testing.T) {
+	p := Probability{Market: Moneyline, Line: "ml", ProbA: 0.53, ProbB: 0.47}
+	tests := []struct {
+		name         string
+		cfg          OddsConfig
+		oddsA, oddsB string
+	}{
+		{"House", OddsConfig{Format: Decimal, Rounding: RoundHouse}, "1.88", "2.12"},
+		{"Nearest", OddsConfig{Format: Decimal, Rounding: RoundNearest}, "1.89", "2.12"},
+		{"American", OddsConfig{Format: American, Rounding: RoundHouse}, "-114", "+112"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Method = Proportional
+			tt.cfg.Ladder = BetfairLadder()
+			price, err := GetPrice(p, tt.cfg)
+			require.NoError(t, err)
+			assert.Equal(t, tt.oddsA, price.OddsA)
+			assert.Equal(t, tt.oddsB, price.OddsB)
+
+			oddsA, err := strconv.ParseFloat(strings.TrimPrefix(tt.oddsA, "-"), 64)
+			require.NoError(t, err)
+			if tt.cfg.Format == Decimal {
+				assert.InDelta(t, oddsA-1/0.53, price.RoundingA, 1e-9, "Expected the rounding error of A")
+			}
+			assert.NotZero(t, price.RoundingB)
+		})
+	}
+
+	unrounded, err := GetPrice(p, OddsConfig{Format: Decimal, Method: Proportional})
+	require.NoError(t, err)
+	assert.Zero(t, unrounded.RoundingA, "Expected no rounding error without a ladder")
+
+	_, err = GetPrice(p, OddsConfig{Format: Decimal, Method: Proportional, Ladder: BetfairLadder(), Rounding: "up"})
+	assert.Error(t, err, "Expected error for an unknown rounding")
+}
@@ -0,0 +1,278 @@
+package format
+
+import (
+	"errors"
+	"fmt"
+	"gotennis/sim"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Leg is one selection of a same match parlay. Legs are written as
+// "<market>:<selection>[:<line>]":
+//
+//	ML:A          A wins the match
+//	AH:B:+3.5     B wins on a game handicap of +3.5
+//	OU:over:22.5  over 22.5 total games
+//	SW:1:A        A wins set 1
+//	CS:2-1        A wins 2-1 in sets
+//	TB:yes        a tiebreak is played in the match
+type Leg struct {
+	Market Market `json:"Market"`
+	// Selection is the player ("A" or "B"), side ("over" or "under"), score ("2-1") or "yes"/"no".
+	Selection string `json:"selection"`
+	// Line is the handicap or total line of AH and OU legs.
+	Line float64 `json:"line,omitempty"`
+	// Set is the set number of SW legs.
+	Set int `json:"set,omitempty"`
+}
+
+// LegProbability is the probability of a single leg on its own.
+type LegProbability struct {
+	Leg         string  `json:"leg"`
+	Probability float64 `json:"probability"`
+}
+
+// Parlay is the probability of every leg of a same match parlay winning together.
+type Parlay struct {
+	Legs []LegProbability `json:"legs"`
+	// Probability is the joint probability of the legs, counted on the same simulated matches.
+	Probability float64 `json:"probability"`
+	// Independent is the product of the leg probabilities, the price of the parlay if the legs
+	// were unrelated.
+	Independent float64 `json:"independent"`
+	// Correlation is Probability over Independent. It is above 1 for legs that tend to win together.
+	Correlation float64 `json:"correlation"`
+	// Odds quotes Probability as odds when requested, with OddsA the odds of the parlay.
+	Odds *Price `json:"odds,omitempty"`
+}
+
+// ParseLeg parses a leg written as described on Leg. Handicap and total lines have to be half
+// lines, so that no leg can push.
+func ParseLeg(s string) (Leg, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	leg := Leg{Market: Market(strings.ToUpper(parts[0]))}
+	args := parts[1:]
+
+	var err error
+	switch leg.Market {
+	case Moneyline:
+		if len(args) != 1 || !isPlayer(args[0]) {
+			return Leg{}, fmt.Errorf("leg %q: expected ML:<A|B>", s)
+		}
+		leg.Selection = strings.ToUpper(args[0])
+	case Handicap:
+		if len(args) != 2 || !isPlayer(args[0]) {
+			return Leg{}, fmt.Errorf("leg %q: expected AH:<A|B>:<line>", s)
+		}
+		leg.Selection = strings.ToUpper(args[0])
+		leg.Line, err = parseHalfLine(args[1])
+	case Total:
+		if len(args) != 2 || (strings.ToLower(args[0]) != "over" && strings.ToLower(args[0]) != "under") {
+			return Leg{}, fmt.Errorf("leg %q: expected OU:<over|under>:<line>", s)
+		}
+		leg.Selection = strings.ToLower(args[0])
+		leg.Line, err = parseHalfLine(args[1])
+	case SetWinner:
+		if len(args) != 2 || !isPlayer(args[1]) {
+			return Leg{}, fmt.Errorf("leg %q: expected SW:<set>:<A|B>", s)
+		}
+		leg.Selection = strings.ToUpper(args[1])
+		leg.Set, err = strconv.Atoi(args[0])
+		if err != nil || leg.Set < 1 || leg.Set > 5 {
+			err = errors.New("set must be between 1 and 5")
+		}
+	case CorrectScore:
+		var a, b int
+		if len(args) != 1 {
+			return Leg{}, fmt.Errorf("leg %q: expected CS:<a>-<b>", s)
+		}
+		if _, scanErr := fmt.Sscanf(args[0], "%d-%d", &a, &b); scanErr != nil || a < 0 || b < 0 || a == b {
+			err = errors.New("invalid score")
+		}
+		leg.Selection = fmt.Sprintf("%d-%d", a, b)
+	case Tiebreak:
+		if len(args) != 1 || (strings.ToLower(args[0]) != "yes" && strings.ToLower(args[0]) != "no") {
+			return Leg{}, fmt.Errorf("leg %q: expected TB:<yes|no>", s)
+		}
+		leg.Selection = strings.ToLower(args[0])
+	case DoubleResult, Combo, OddEven, TotalGames, Break:
+		return Leg{}, fmt.Errorf("leg %q: market %s cannot be combined", s, leg.Market)
+	default:
+		return Leg{}, fmt.Errorf("leg %q: unknown market", s)
+	}
+	if err != nil {
+		return Leg{}, fmt.Errorf("leg %q: %w", s, err)
+	}
+	return leg, nil
+}
+
+// ParseLegs parses a list of legs, see ParseLeg. At least one leg is required.
+func ParseLegs(legs []string) ([]Leg, error) {
+	if len(legs) == 0 {
+		return nil, errors.New("at least one leg is required")
+	}
+	out := make([]Leg, 0, len(legs))
+	for _, s := range legs {
+		leg, err := ParseLeg(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, leg)
+	}
+	return out, nil
+}
+
+// GetParlay calculates the probability of every leg winning in the same simulated match, which
+// takes the correlation between the legs into account, next to the probability of each leg. A
+// leg is void in a match that does not settle it, see Leg.Settles, and its probability is
+// conditional on it being settled. A parlay is only priced when every leg stands, so its
+// probability is conditional on every leg being settled.
+func GetParlay(results []sim.SimulatedMatch, legs []Leg) Parlay {
+	out := Parlay{Legs: make([]LegProbability, len(legs)), Independent: 1}
+	wins := make([]int, len(legs))
+	settled := make([]int, len(legs))
+	joint, standing := 0, 0
+	for _, m := range results {
+		all, stands := true, true
+		for i, leg := range legs {
+			if !leg.Settles(m) {
+				stands = false
+				continue
+			}
+			settled[i]++
+			if leg.Wins(m) {
+				wins[i]++
+			} else {
+				all = false
+			}
+		}
+		if stands {
+			standing++
+			if all {
+				joint++
+			}
+		}
+	}
+
+	for i, leg := range legs {
+		p := 0.0
+		if settled[i] > 0 {
+			p = float64(wins[i]) / float64(settled[i])
+		}
+		out.Legs[i] = LegProbability{Leg: leg.String(), Probability: p}
+		out.Independent *= p
+	}
+	if standing > 0 {
+		out.Probability = float64(joint) / float64(standing)
+	}
+	if out.Independent > 0 {
+		out.Correlation = out.Probability / out.Independent
+	}
+	return out
+}
+
+// Settles reports whether the simulated match settles the leg. A set winner leg on a set that was
+// not completed and a correct score leg on a match that was not completed are void, as in Sets
+// and GetCorrectScores.
+func (l Leg) Settles(m sim.SimulatedMatch) bool {
+	switch l.Market {
+	case SetWinner:
+		return l.Set <= m.ASets+m.BSets
+	case CorrectScore:
+		return m.Ending == sim.Completed
+	case Moneyline, Handicap, Total, Tiebreak, DoubleResult, Combo, OddEven, TotalGames, Break:
+		return true
+	default:
+		return true
+	}
+}
+
+// Wins reports whether the leg wins in the simulated match. A leg the match does not settle
+// loses, see Settles.
+func (l Leg) Wins(m sim.SimulatedMatch) bool {
+	switch l.Market {
+	case Moneyline:
+		return m.AWins() == (l.Selection == "A")
+	case Handicap:
+		aGames, bGames := getMatchGames(m)
+		if l.Selection == "A" {
+			return float64(aGames)+l.Line > float64(bGames)
+		}
+		return float64(bGames)+l.Line > float64(aGames)
+	case Total:
+		aGames, bGames := getMatchGames(m)
+		if l.Selection == "over" {
+			return float64(aGames+bGames) > l.Line
+		}
+		return float64(aGames+bGames) < l.Line
+	case SetWinner:
+		if !l.Settles(m) {
+			return false
+		}
+		set := m.SetResults[l.Set-1]
+		return (set.AGames > set.BGames) == (l.Selection == "A")
+	case CorrectScore:
+		return m.Ending == sim.Completed && fmt.Sprintf("%d-%d", m.ASets, m.BSets) == l.Selection
+	case Tiebreak:
+		tiebreak := false
+		for _, set := range m.SetResults {
+			tiebreak = tiebreak || set.Tiebreak
+		}
+		return tiebreak == (l.Selection == "yes")
+	case DoubleResult, Combo, OddEven, TotalGames, Break:
+		return false
+	default:
+		return false
+	}
+}
+
+// String writes the leg in the form read by ParseLeg.
+func (l Leg) String() string {
+	switch l.Market {
+	case Handicap:
+		return fmt.Sprintf("%s:%s:%+.1f", l.Market, l.Selection, l.Line)
+	case Total:
+		return fmt.Sprintf("%s:%s:%.1f", l.Market, l.Selection, l.Line)
+	case SetWinner:
+		return fmt.Sprintf("%s:%d:%s", l.Market, l.Set, l.Selection)
+	case Moneyline, CorrectScore, Tiebreak, DoubleResult, Combo, OddEven, TotalGames, Break:
+		return fmt.Sprintf("%s:%s", l.Market, l.Selection)
+	default:
+		return fmt.Sprintf("%s:%s", l.Market, l.Selection)
+	}
+}
+
+// Price quotes the joint probability of the parlay as odds according to cfg.
+func (p Parlay) Price(cfg OddsConfig) (Parlay, error) {
+	price, err := GetPrice(Probability{
+		Market: Combo,
+		Line:   "parlay",
+		ProbA:  p.Probability,
+		ProbB:  1 - p.Probability,
+	}, cfg)
+	if err != nil {
+		return p, err
+	}
+	p.Odds = &price
+	return p, nil
+}
+
+// parseHalfLine parses a handicap or total line, which has to be a half line.
+func parseHalfLine(s string) (float64, error) {
+	line, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(line, 0) || math.IsNaN(line) {
+		return 0, errors.New("invalid line")
+	}
+	if math.Mod(math.Abs(line)*2, 2) != 1 {
+		return 0, errors.New("line must be a half line such as 22.5")
+	}
+	return line, nil
+}
+
+// isPlayer reports whether s names player A or B.
+func isPlayer(s string) bool {
+	s = strings.ToUpper(s)
+	return s == "A" || s == "B"
+}
@@ -0,0 +1,127 @@
+package format
+
+import (
+	"fmt"
+	"gotennis/sim"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLeg(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Leg
+		str      string
+		wantErr  bool
+	}{
+		{input: "ML:A", expected: Leg{Market: Moneyline, Selection: "A"}, str: "ML:A"},
+		{input: " ml:b ", expected: Leg{Market: Moneyline, Selection: "B"}, str: "ML:B"},
+		{input: "AH:B:3.5", expected: Leg{Market: Handicap, Selection: "B", Line: 3.5}, str: "AH:B:+3.5"},
+		{input: "OU:Over:22.5", expected: Leg{Market: Total, Selection: "over", Line: 22.5}, str: "OU:over:22.5"},
+		{input: "SW:1:A", expected: Leg{Market: SetWinner, Selection: "A", Set: 1}, str: "SW:1:A"},
+		{input: "CS:2-1", expected: Leg{Market: CorrectScore, Selection: "2-1"}, str: "CS:2-1"},
+		{input: "TB:yes", expected: Leg{Market: Tiebreak, Selection: "yes"}, str: "TB:yes"},
+		{input: "ML", wantErr: true},
+		{input: "ML:C", wantErr: true},
+		{input: "OU:over:22", wantErr: true},
+		{input: "OU:over:22.25", wantErr: true},
+		{input: "OU:maybe:22.5", wantErr: true},
+		{input: "AH:A:x", wantErr: true},
+		{input: "SW:6:A", wantErr: true},
+		{input: "CS:1-1", wantErr: true},
+		{input: "TB:maybe", wantErr: true},
+		{input: "BK:A", wantErr: true},
+		{input: "XX:A", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			leg, err := ParseLeg(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, leg)
+			assert.Equal(t, tt.str, leg.String())
+		})
+	}
+
+	_, err := ParseLegs(nil)
+	assert.Error(t, err, "A parlay needs at least one leg")
+}
+
+func TestGetParlay(t *testing.T) {
+	results := []sim.SimulatedMatch{
+		{ASets: 2, SetResults: []sim.SimulatedSet{{AGames: 6, BGames: 3}, {AGames: 6, BGames: 4}}},
+		{ASets: 2, BSets: 1, SetResults: []sim.SimulatedSet{
+			{AGames: 6, BGames: 7, Tiebreak: true}, {AGames: 6, BGames: 4}, {AGames: 6, BGames: 4},
+		}},
+		{BSets: 2, SetResults: []sim.SimulatedSet{{AGames: 4, BGames: 6}, {AGames: 3, BGames: 6}}},
+		{BSets: 2, SetResults: []sim.SimulatedSet{{AGames: 6, BGames: 7, Tiebreak: true}, {AGames: 5, BGames: 7}}},
+	}
+
+	t.Run("Correlated legs", func(t *testing.T) {
+		legs, err := ParseLegs([]string{"ML:A", "SW:1:A"})
+		require.NoError(t, err)
+		parlay := GetParlay(results, legs)
+		require.Len(t, parlay.Legs, 2)
+		assert.Equal(t, "ML:A", parlay.Legs[0].Leg)
+		assert.InDelta(t, 0.5, parlay.Legs[0].Probability, 0.001)
+		assert.InDelta(t, 0.25, parlay.Legs[1].Probability, 0.001)
+		assert.InDelta(t, 0.25, parlay.Probability, 0.001, "A winning set 1 always won the match")
+		assert.InDelta(t, 0.125, parlay.Independent, 0.001)
+		assert.InDelta(t, 2, parlay.Correlation, 0.001)
+	})
+
+	t.Run("Exclusive legs", func(t *testing.T) {
+		legs, err := ParseLegs([]string{"ML:B", "OU:over:24.5"})
+		require.NoError(t, err)
+		parlay := GetParlay(results, legs)
+		assert.InDelta(t, 0.5, parlay.Legs[1].Probability, 0.001)
+		assert.InDelta(t, 0.25, parlay.Probability, 0.001)
+	})
+
+	t.Run("Unplayed set", func(t *testing.T) {
+		legs, err := ParseLegs([]string{"SW:3:A", "CS:2-1", "TB:yes", "AH:B:+3.5"})
+		require.NoError(t, err)
+		parlay := GetParlay(results, legs)
+		assert.InDelta(t, 1, parlay.Legs[0].Probability, 0.001, "A won set 3 the only time it was played")
+		assert.InDelta(t, 1, parlay.Probability, 0.001, "Expected the parlay to stand in one match only")
+	})
+
+	t.Run("Retirements", func(t *testing.T) {
+		retired := sim.SimulatedMatch{ASets: 1, Ending: sim.Retired, SetResults: []sim.SimulatedSet{
+			{AGames: 6, BGames: 3}, {AGames: 2, BGames: 1},
+		}}
+		legs, err := ParseLegs([]string{"SW:1:A", "SW:2:A"})
+		require.NoError(t, err)
+		parlay := GetParlay(append([]sim.SimulatedMatch{retired}, results...), legs)
+		assert.InDelta(t, 0.4, parlay.Legs[0].Probability, 0.001, "Set 1 was completed before the retirement")
+		assert.InDelta(t, 0.5, parlay.Legs[1].Probability, 0.001, "Set 2 was not completed")
+		assert.InDelta(t, 0.25, parlay.Probability, 0.001)
+
+		simulated, err := sim.SimulateMatchWithOptions(0.65, 0.6, 3, sim.Options{Simulations: 5000, RetireA: 0.01})
+		require.NoError(t, err)
+		simulated, err = ApplyRetirementRule(simulated, RetirementSettle)
+		require.NoError(t, err)
+		sets := GetSetMarkets(simulated, 3)
+		for set := 1; set <= 3; set++ {
+			legs, err := ParseLegs([]string{fmt.Sprintf("SW:%d:A", set)})
+			require.NoError(t, err)
+			parlay := GetParlay(simulated, legs)
+			assert.InDelta(t, sets[set-1].Winner.ProbA, parlay.Probability, 1e-9, "Set %d", set)
+		}
+	})
+
+	t.Run("Price", func(t *testing.T) {
+		legs, err := ParseLegs([]string{"ML:A", "SW:1:A"})
+		require.NoError(t, err)
+		parlay, err := GetParlay(results, legs).Price(OddsConfig{Format: Decimal, Method: Proportional})
+		require.NoError(t, err)
+		require.NotNil(t, parlay.Odds)
+		assert.Equal(t, "4.00", parlay.Odds.OddsA)
+	})
+}
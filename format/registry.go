@@ -0,0 +1,329 @@
+package format
+
+import (
+	"fmt"
+	"gotennis/sim"
+	"strings"
+)
+
+// MainLinesKey is the key of the main lines of the derived line markets, see GetMainLine.
+const MainLinesKey = "MainLines"
+
+// MarketConfig holds the request parameters market families may depend on.
+type MarketConfig struct {
+	BestOf int         `json:"bestof"`
+	Lines  LineOptions `json:"lines"`
+	// P1 and P2 are the probabilities of each player winning a point on serve.
+	P1 float64 `json:"p1"`
+	P2 float64 `json:"p2"`
+}
+
+// MarketFamily is a group of markets derived together and returned under one key.
+type MarketFamily interface {
+	// Name is the key of the family in requests and responses.
+	Name() string
+	// Aliases are other names the family can be requested by.
+	Aliases() []string
+	// Derive calculates the probabilities of the markets.
+	Derive(results []sim.SimulatedMatch, cfg MarketConfig) any
+	// Price quotes the result of Derive as odds.
+	Price(derived any, odds OddsConfig) (any, error)
+	// Lines returns the handicap and total markets of the result of Derive, keyed by their name
+	// under MainLinesKey. It is nil for families without lines.
+	Lines(derived any) map[string][]Probability
+	// Probabilities returns every market of the result of Derive quoted as a Probability, keyed
+	// by the same names as Lines. It is nil for families without such markets.
+	Probabilities(derived any) map[string][]Probability
+	// NeedsGames reports whether Derive reads the games of every set, which the simulation only
+	// records with sim.Options.RecordGames.
+	NeedsGames() bool
+}
+
+// Markets are derived market families keyed by their name.
+type Markets map[string]any
+
+// Registry holds the market families that can be derived from a simulation.
+type Registry struct {
+	families []MarketFamily
+	byName   map[string]MarketFamily
+}
+
+// Selection is a set of market families of a Registry to derive.
+type Selection struct {
+	families  []MarketFamily
+	mainLines bool
+}
+
+// marketFamily implements MarketFamily for markets of type T.
+type marketFamily[T any] struct {
+	name    string
+	aliases []string
+	derive  func(results []sim.SimulatedMatch, cfg MarketConfig) T
+	price   func(markets T, odds OddsConfig) (any, error)
+	lines   func(markets T) map[string][]Probability
+	probs   func(markets T) map[string][]Probability
+	games   bool
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{byName: make(map[string]MarketFamily)}
+}
+
+// DefaultRegistry returns a Registry holding every market family of this package.
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+	for _, families := range [][]MarketFamily{
+		matchFamilies(),
+		setFamilies(),
+		tiebreakFamilies(),
+		comboFamilies(),
+		totalFamilies(),
+		breakFamilies(),
+		distributionFamilies(),
+	} {
+		for _, f := range families {
+			if err := r.Register(f); err != nil {
+				panic(err)
+			}
+		}
+	}
+	return r
+}
+
+// Register adds a market family to the registry. Names and aliases have to be unique.
+func (r *Registry) Register(f MarketFamily) error {
+	names := append([]string{f.Name()}, f.Aliases()...)
+	for _, name := range names {
+		if name == MainLinesKey {
+			return fmt.Errorf("market name %q is reserved", name)
+		}
+		if _, ok := r.byName[name]; ok {
+			return fmt.Errorf("market %q is already registered", name)
+		}
+	}
+	for _, name := range names {
+		r.byName[name] = f
+	}
+	r.families = append(r.families, f)
+	return nil
+}
+
+// Names returns the names of the registered families in registration order, followed by MainLinesKey.
+func (r *Registry) Names() []string {
+	out := make([]string, 0, len(r.families)+1)
+	for _, f := range r.families {
+		out = append(out, f.Name())
+	}
+	return append(out, MainLinesKey)
+}
+
+// Select returns the families with the given names or aliases, or every family when no name is
+// given. MainLinesKey selects the main lines of the other selected families.
+func (r *Registry) Select(names []string) (Selection, error) {
+	if len(names) == 0 {
+		return Selection{families: r.families, mainLines: true}, nil
+	}
+
+	var sel Selection
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == MainLinesKey {
+			sel.mainLines = true
+			continue
+		}
+		f, ok := r.byName[name]
+		if !ok {
+			return Selection{}, fmt.Errorf("unknown market %q", name)
+		}
+		if !seen[f.Name()] {
+			seen[f.Name()] = true
+			sel.families = append(sel.families, f)
+		}
+	}
+	return sel, nil
+}
+
+// SelectMarkets returns the families of the markets, named as by MarketFamily.Probabilities, see
+// NeedsGames, or every family when no market is given.
+func (r *Registry) SelectMarkets(markets []string) (Selection, error) {
+	names := make([]string, 0, len(markets))
+	for _, market := range markets {
+		name, _, _ := strings.Cut(market, ".")
+		names = append(names, name)
+	}
+	return r.Select(names)
+}
+
+// Derive calculates the selected markets from the simulated matches.
+func (r *Registry) Derive(results []sim.SimulatedMatch, cfg MarketConfig, sel Selection) Markets {
+	out := make(Markets, len(sel.families)+1)
+	lines := make(map[string][]Probability)
+	for _, f := range sel.families {
+		derived := f.Derive(results, cfg)
+		out[f.Name()] = derived
+		for name, probs := range f.Lines(derived) {
+			lines[name] = probs
+		}
+	}
+
+	if sel.mainLines {
+		mainLines := make(map[string]MainLine, len(lines))
+		for name, probs := range lines {
+			if line, ok := GetMainLine(probs); ok {
+				mainLines[name] = line
+			}
+		}
+		out[MainLinesKey] = mainLines
+	}
+	return out
+}
+
+// Price quotes every derived market as odds. Main lines are kept as fair probabilities, so that
+// they can be compared with the quoted odds.
+func (r *Registry) Price(markets Markets, odds OddsConfig) (Markets, error) {
+	out := make(Markets, len(markets))
+	for name, derived := range markets {
+		if name == MainLinesKey {
+			out[name] = derived
+			continue
+		}
+		f, ok := r.byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown market %q", name)
+		}
+		priced, err := f.Price(derived, odds)
+		if err != nil {
+			return nil, err
+		}
+		out[name] = priced
+	}
+	return out, nil
+}
+
+// Probabilities returns every market of the derived families quoted as a Probability, keyed by
+// their name as returned by MarketFamily.Probabilities.
+func (r *Registry) Probabilities(markets Markets) map[string][]Probability {
+	out := make(map[string][]Probability)
+	for name, derived := range markets {
+		f, ok := r.byName[name]
+		if !ok {
+			continue
+		}
+		for key, probs := range f.Probabilities(derived) {
+			out[key] = probs
+		}
+	}
+	return out
+}
+
+// NeedsGames reports whether any of the markets, named as by MarketFamily.Probabilities, belongs
+// to a family that needs the games of every set. A market belongs to the family named by its key
+// up to the first dot, e.g. "Breaks.TotalBreaks" to "Breaks".
+func (r *Registry) NeedsGames(markets []string) bool {
+	for _, market := range markets {
+		name, _, _ := strings.Cut(market, ".")
+		if f, ok := r.byName[name]; ok && f.NeedsGames() {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsGames reports whether any selected family needs the games of every set.
+func (s Selection) NeedsGames() bool {
+	for _, f := range s.families {
+		if f.NeedsGames() {
+			return true
+		}
+	}
+	return false
+}
+
+func (f marketFamily[T]) Name() string {
+	return f.name
+}
+
+func (f marketFamily[T]) Aliases() []string {
+	return f.aliases
+}
+
+func (f marketFamily[T]) Derive(results []sim.SimulatedMatch, cfg MarketConfig) any {
+	return f.derive(results, cfg)
+}
+
+func (f marketFamily[T]) Price(derived any, odds OddsConfig) (any, error) {
+	markets, ok := derived.(T)
+	if !ok {
+		return nil, fmt.Errorf("market %q cannot price %T", f.name, derived)
+	}
+	if f.price == nil {
+		return markets, nil
+	}
+	return f.price(markets, odds)
+}
+
+func (f marketFamily[T]) Lines(derived any) map[string][]Probability {
+	markets, ok := derived.(T)
+	if !ok || f.lines == nil {
+		return nil
+	}
+	return f.lines(markets)
+}
+
+func (f marketFamily[T]) Probabilities(derived any) map[string][]Probability {
+	markets, ok := derived.(T)
+	if !ok || f.probs == nil {
+		return nil
+	}
+	return f.probs(markets)
+}
+
+func (f marketFamily[T]) NeedsGames() bool {
+	return f.games
+}
+
+// probabilityFamily is a family of a single Probability.
+func probabilityFamily(
+	name string,
+	derive func(results []sim.SimulatedMatch, cfg MarketConfig) Probability,
+	aliases ...string,
+) MarketFamily {
+	return marketFamily[Probability]{
+		name:    name,
+		aliases: aliases,
+		derive:  derive,
+		price: func(p Probability, odds OddsConfig) (any, error) {
+			return GetPrice(p, odds)
+		},
+		probs: func(p Probability) map[string][]Probability {
+			return map[string][]Probability{name: {p}}
+		},
+	}
+}
+
+// probabilitiesFamily is a family of a list of Probabilities, which are lines for MainLines when
+// 'withLines' is set.
+func probabilitiesFamily(
+	name string,
+	withLines bool,
+	derive func(results []sim.SimulatedMatch, cfg MarketConfig) []Probability,
+) MarketFamily {
+	f := marketFamily[[]Probability]{
+		name:   name,
+		derive: derive,
+		price: func(probs []Probability, odds OddsConfig) (any, error) {
+			return GetPrices(probs, odds)
+		},
+		probs: func(probs []Probability) map[string][]Probability {
+			return map[string][]Probability{name: probs}
+		},
+	}
+	if withLines {
+		f.lines = func(probs []Probability) map[string][]Probability {
+			return map[string][]Probability{name: probs}
+		}
+	}
+	return f
+}
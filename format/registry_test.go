@@ -0,0 +1,196 @@
+package format
+
+import (
+	"gotennis/sim"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultRegistry(t *testing.T) {
+	r := DefaultRegistry()
+	names := r.Names()
+	for _, name := range []string{
+		"Moneyline",
+		"SetHandicaps",
+		"GameHandicaps",
+		"SetOU",
+		"GameOU",
+		"CorrectScore",
+		"PlayerAGameOU",
+		"PlayerBGameOU",
+		"Sets",
+		"Tiebreaks",
+		"Combos",
+		"GameOddEven",
+		"GameTotalPMF",
+		"GameTotalBands",
+		"Breaks",
+		"HoldA",
+		"HoldB",
+		"Distributions",
+		MainLinesKey,
+	} {
+		assert.Contains(t, names, name, "Expected market family %s", name)
+	}
+	assert.Equal(t, MainLinesKey, names[len(names)-1], "Expected main lines last")
+}
+
+func TestRegistrySelect(t *testing.T) {
+	r := DefaultRegistry()
+
+	tests := []struct {
+		name        string
+		names       []string
+		expected    []string
+		expectError bool
+	}{
+		{"Everything by default", nil, r.Names(), false},
+		{"By name", []string{"Moneyline", "SetOU"}, []string{"Moneyline", "SetOU"}, false},
+		{"By alias", []string{"ML", " CorrectScore"}, []string{"Moneyline", "CorrectScore"}, false},
+		{"Duplicates", []string{"ML", "Moneyline"}, []string{"Moneyline"}, false},
+		{"Main lines", []string{"GameOU", MainLinesKey}, []string{"GameOU", MainLinesKey}, false},
+		{"Unknown", []string{"ML", "Corners"}, nil, true},
+	}
+
+	results := createTestSimulatedMatches()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel, err := r.Select(tt.names)
+			if tt.expectError {
+				assert.Error(t, err, "Expected error for %v", tt.names)
+				return
+			}
+			require.NoError(t, err)
+
+			markets := r.Derive(results, MarketConfig{BestOf: 3, Lines: LineOptions{Type: HalfLines}}, sel)
+			keys := make([]string, 0, len(markets))
+			for key := range markets {
+				keys = append(keys, key)
+			}
+			assert.ElementsMatch(t, tt.expected, keys)
+		})
+	}
+}
+
+func TestRegistrySelectMarkets(t *testing.T) {
+	r := DefaultRegistry()
+
+	sel, err := r.SelectMarkets([]string{"Moneyline", "Set2.Winner", "Set1.GameOU", "Tiebreaks.InSet", "ML"})
+	require.NoError(t, err)
+	markets := r.Derive(createTestSimulatedMatches(), MarketConfig{BestOf: 3, Lines: LineOptions{Type: HalfLines}}, sel)
+	keys := make([]string, 0, len(markets))
+	for key := range markets {
+		keys = append(keys, key)
+	}
+	assert.ElementsMatch(t, []string{"Moneyline", "Sets", "Tiebreaks"}, keys)
+
+	_, err = r.SelectMarkets([]string{"Corners.Total"})
+	assert.Error(t, err, "Expected error for an unknown market")
+}
+
+func TestRegistryNeedsGames(t *testing.T) {
+	r := DefaultRegistry()
+
+	tests := []struct {
+		name     string
+		names    []string
+		markets  []string
+		expected bool
+	}{
+		{"Everything", nil, nil, true},
+		{"Breaks", []string{"ML", "Breaks"}, []string{"Moneyline", "Breaks.TotalBreaks"}, true},
+		{"Without breaks", []string{"ML", "GameOU", "Sets"}, []string{"Moneyline", "GameOU", "Set1.Winner"}, false},
+		{"Hold", []string{"HoldA", "HoldB"}, []string{"HoldA"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel, err := r.Select(tt.names)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, sel.NeedsGames())
+			if tt.markets != nil {
+				assert.Equal(t, tt.expected, r.NeedsGames(tt.markets))
+			}
+		})
+	}
+}
+
+func TestRegistryDerive(t *testing.T) {
+	r := DefaultRegistry()
+	results := createTestSimulatedMatches()
+	sel, err := r.Select([]string{"ML", "GameOU", "Sets", "Combos", MainLinesKey})
+	require.NoError(t, err)
+
+	markets := r.Derive(results, MarketConfig{BestOf: 3, Lines: LineOptions{Type: HalfLines}}, sel)
+	assert.Equal(t, GetMoneyline(results), markets["Moneyline"])
+	assert.Equal(t, GetGameTotals(results, 3), markets["GameOU"])
+	assert.Equal(t, GetComboMarkets(results), markets["Combos"])
+
+	mainLines, ok := markets[MainLinesKey].(map[string]MainLine)
+	require.True(t, ok, "Expected main lines")
+	assert.Contains(t, mainLines, "GameOU")
+	assert.Contains(t, mainLines, "Set1.GameOU")
+	assert.NotContains(t, mainLines, "SetOU", "Expected main lines of the selected markets only")
+
+	probs := r.Probabilities(markets)
+	assert.Equal(t, []Probability{GetMoneyline(results)}, probs["Moneyline"])
+	assert.Equal(t, GetComboMarkets(results).WinToNil, probs["Combos.WinToNil"])
+	assert.Contains(t, probs, "Set1.Winner")
+	assert.NotContains(t, probs, MainLinesKey)
+
+	t.Run("Caller lines", func(t *testing.T) {
+		cfg := MarketConfig{BestOf: 3, Lines: LineOptions{Type: HalfLines, GameTotals: []float64{22.5}}}
+		markets := r.Derive(results, cfg, sel)
+		assert.Equal(t, GetGameTotalsAt(results, []float64{22.5}), markets["GameOU"])
+	})
+}
+
+func TestRegistryPrice(t *testing.T) {
+	r := DefaultRegistry()
+	results, err := sim.SimulateMatchWithOptions(0.65, 0.6, 3, sim.Options{Simulations: 1000, RecordGames: true})
+	require.NoError(t, err)
+	all, err := r.Select(nil)
+	require.NoError(t, err)
+
+	markets := r.Derive(results, MarketConfig{BestOf: 3, Lines: LineOptions{Type: HalfLines}, P1: 0.65, P2: 0.6}, all)
+	priced, err := r.Price(markets, OddsConfig{Format: Decimal, Margin: 0.05, Method: Proportional})
+	require.NoError(t, err)
+	require.Len(t, priced, len(markets), "Expected every market to be priced")
+
+	assert.IsType(t, Price{}, priced["Moneyline"])
+	assert.IsType(t, []Price{}, priced["GameOU"])
+	assert.IsType(t, []PricedSetMarkets{}, priced["Sets"])
+	assert.IsType(t, PricedTiebreakMarkets{}, priced["Tiebreaks"])
+	assert.IsType(t, PricedComboMarkets{}, priced["Combos"])
+	assert.IsType(t, PricedBreakMarkets{}, priced["Breaks"])
+	assert.Equal(t, markets["HoldA"], priced["HoldA"], "Expected hold probabilities as they are")
+	assert.Equal(t, markets["Distributions"], priced["Distributions"], "Expected distributions as they are")
+	assert.Equal(t, markets[MainLinesKey], priced[MainLinesKey], "Expected fair main lines")
+	assert.InDelta(t, sim.HoldProbability(0.65), markets["HoldA"], 1e-9)
+
+	_, err = r.Price(Markets{"Corners": 1}, OddsConfig{Format: Decimal, Method: Proportional})
+	assert.Error(t, err, "Expected error for an unknown market")
+	_, err = r.Price(Markets{"Moneyline": 1}, OddsConfig{Format: Decimal, Method: Proportional})
+	assert.Error(t, err, "Expected error for a value of the wrong type")
+}
+
+func TestRegistryRegister(t *testing.T) {
+	r := NewRegistry()
+	custom := probabilityFamily("FirstSetA", func(results []sim.SimulatedMatch, _ MarketConfig) Probability {
+		return getMatchProbability(results, Combo, "A", func(m sim.SimulatedMatch) bool {
+			return len(m.SetResults) > 0 && m.SetResults[0].AGames > m.SetResults[0].BGames
+		})
+	}, "FS")
+	require.NoError(t, r.Register(custom))
+	assert.Error(t, r.Register(custom), "Expected error for a duplicate name")
+	assert.Error(t, r.Register(probabilityFamily(MainLinesKey, nil)), "Expected error for a reserved name")
+
+	sel, err := r.Select([]string{"FS"})
+	require.NoError(t, err)
+	markets := r.Derive(createTestSimulatedMatches(), MarketConfig{BestOf: 3}, sel)
+	prob, ok := markets["FirstSetA"].(Probability)
+	require.True(t, ok)
+	assert.InDelta(t, 0.75, prob.ProbA, 0.001, "A won the first set in 3 of 4 matches")
+}
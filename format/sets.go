@@ -0,0 +1,200 @@
+package format
+
+import (
+	"fmt"
+	"gotennis/sim"
+)
+
+// SetMarkets holds the markets of a single set. Bets on a set that is not completed are void,
+// so the probabilities are conditional on the set being played to the end.
+type SetMarkets struct {
+	Set           int           `json:"Set"`
+	Winner        Probability   `json:"Winner"`
+	CorrectScore  []Probability `json:"CorrectScore"`
+	GameHandicaps []Probability `json:"GameHandicaps"`
+	GameOU        []Probability `json:"GameOU"`
+}
+
+// PricedSetMarkets is a SetMarkets with every probability quoted as odds.
+type PricedSetMarkets struct {
+	Set           int     `json:"Set"`
+	Winner        Price   `json:"Winner"`
+	CorrectScore  []Price `json:"CorrectScore"`
+	GameHandicaps []Price `json:"GameHandicaps"`
+	GameOU        []Price `json:"GameOU"`
+}
+
+// setScores lists the final scores of a set from A's point of view, from the biggest win for A
+// to the biggest win for B.
+var setScores = []sim.SimulatedSet{
+	{AGames: 6, BGames: 0},
+	{AGames: 6, BGames: 1},
+	{AGames: 6, BGames: 2},
+	{AGames: 6, BGames: 3},
+	{AGames: 6, BGames: 4},
+	{AGames: 7, BGames: 5},
+	{AGames: 7, BGames: 6},
+	{AGames: 6, BGames: 7},
+	{AGames: 5, BGames: 7},
+	{AGames: 4, BGames: 6},
+	{AGames: 3, BGames: 6},
+	{AGames: 2, BGames: 6},
+	{AGames: 1, BGames: 6},
+	{AGames: 0, BGames: 6},
+}
+
+const (
+	// SET_GAME_SPREAD is the widest game handicap line quoted for a single set.
+	SET_GAME_SPREAD float64 = 5.5
+	// minSetGames and maxSetGames bound the number of games in a completed set.
+	minSetGames = 6
+	maxSetGames = 13
+)
+
+// GetSetMarkets calculates the winner, correct score, game handicap and total games markets of
+// every set that was completed in at least one simulated match.
+func GetSetMarkets(results []sim.SimulatedMatch, bestof int) []SetMarkets {
+	var out []SetMarkets
+	for set := range bestof {
+		played := setsPlayed(results, set)
+		if len(played) == 0 {
+			break
+		}
+		out = append(out, getSetMarkets(played, set))
+	}
+	return out
+}
+
+// setsPlayed returns the scores of the given set (zero based) in the matches where it was completed.
+func setsPlayed(results []sim.SimulatedMatch, set int) []sim.SimulatedSet {
+	var out []sim.SimulatedSet
+	for _, m := range results {
+		if set < m.ASets+m.BSets {
+			out = append(out, m.SetResults[set])
+		}
+	}
+	return out
+}
+
+func getSetMarkets(sets []sim.SimulatedSet, set int) SetMarkets {
+	res := SetMarkets{
+		Set: set + 1,
+		Winner: getSetProbability(sets, Moneyline, "ml", func(s sim.SimulatedSet) bool {
+			return s.AGames > s.BGames
+		}),
+	}
+
+	for _, score := range setScores {
+		res.CorrectScore = append(res.CorrectScore, getSetProbability(
+			sets,
+			CorrectScore,
+			fmt.Sprintf("%d-%d", score.AGames, score.BGames),
+			func(s sim.SimulatedSet) bool { return s.AGames == score.AGames && s.BGames == score.BGames },
+		))
+	}
+
+	for i := -SET_GAME_SPREAD; i <= SET_GAME_SPREAD; i++ {
+		res.GameHandicaps = append(res.GameHandicaps, getSetProbability(
+			sets,
+			Handicap,
+			fmt.Sprintf("%.1f", i),
+			func(s sim.SimulatedSet) bool { return float64(s.AGames)+i > float64(s.BGames) },
+		))
+	}
+
+	for i := float64(minSetGames) + 0.5; i < maxSetGames; i++ {
+		res.GameOU = append(res.GameOU, getSetProbability(
+			sets,
+			Total,
+			fmt.Sprintf("%.1f", i),
+			func(s sim.SimulatedSet) bool { return float64(s.AGames+s.BGames) > i },
+		))
+	}
+	return res
+}
+
+// getSetProbability returns the share of sets for which 'aWins' holds as a Probability.
+func getSetProbability(
+	sets []sim.SimulatedSet,
+	market Market,
+	line string,
+	aWins func(sim.SimulatedSet) bool,
+) Probability {
+	n := 0
+	for _, s := range sets {
+		if aWins(s) {
+			n++
+		}
+	}
+
+	return Probability{
+		Market: market,
+		Line:   line,
+		ProbA:  float64(n) / float64(len(sets)),
+		ProbB:  1 - float64(n)/float64(len(sets)),
+	}
+}
+
+// Price quotes every market of the set as odds.
+func (m SetMarkets) Price(cfg OddsConfig) (PricedSetMarkets, error) {
+	priced := PricedSetMarkets{Set: m.Set}
+	var err error
+
+	if priced.Winner, err = GetPrice(m.Winner, cfg); err != nil {
+		return priced, err
+	}
+	if priced.CorrectScore, err = GetPrices(m.CorrectScore, cfg); err != nil {
+		return priced, err
+	}
+	if priced.GameHandicaps, err = GetPrices(m.GameHandicaps, cfg); err != nil {
+		return priced, err
+	}
+	if priced.GameOU, err = GetPrices(m.GameOU, cfg); err != nil {
+		return priced, err
+	}
+	return priced, nil
+}
+
+// setFamilies returns the market family of the markets of every set.
+func setFamilies() []MarketFamily {
+	return []MarketFamily{
+		marketFamily[[]SetMarkets]{
+			name: "Sets",
+			// The markets of each set are keyed by the set, e.g. "Set1.Winner", so that the sets
+			// resolve to the family, see Registry.SelectMarkets.
+			aliases: []string{"Set1", "Set2", "Set3", "Set4", "Set5"},
+			derive: func(results []sim.SimulatedMatch, cfg MarketConfig) []SetMarkets {
+				return GetSetMarkets(results, cfg.BestOf)
+			},
+			price: func(sets []SetMarkets, odds OddsConfig) (any, error) {
+				out := make([]PricedSetMarkets, 0, len(sets))
+				for _, set := range sets {
+					priced, err := set.Price(odds)
+					if err != nil {
+						return nil, err
+					}
+					out = append(out, priced)
+				}
+				return out, nil
+			},
+			lines: func(sets []SetMarkets) map[string][]Probability {
+				out := make(map[string][]Probability, 2*len(sets))
+				for _, set := range sets {
+					out[fmt.Sprintf("Set%d.GameHandicaps", set.Set)] = set.GameHandicaps
+					out[fmt.Sprintf("Set%d.GameOU", set.Set)] = set.GameOU
+				}
+				return out
+			},
+			probs: func(sets []SetMarkets) map[string][]Probability {
+				out := make(map[string][]Probability, 4*len(sets))
+				for _, set := range sets {
+					out[fmt.Sprintf("Set%d.Winner", set.Set)] = []Probability{set.Winner}
+					out[fmt.Sprintf("Set%d.CorrectScore", set.Set)] = set.CorrectScore
+					out[fmt.Sprintf("Set%d.GameHandicaps", set.Set)] = set.GameHandicaps
+					out[fmt.Sprintf("Set%d.GameOU", set.Set)] = set.GameOU
+				}
+				return out
+			},
+		},
+	}
+}
@@ -0,0 +1,92 @@
+package format
+
+import (
+	"gotennis/sim"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSetMarkets(t *testing.T) {
+	results := createTestSimulatedMatches()
+
+	markets := GetSetMarkets(results, 3)
+	require.Len(t, markets, 3, "Expected markets for 3 sets")
+
+	tests := []struct {
+		name           string
+		set            int
+		expectedWinner float64
+		score          string
+		expectedScore  float64
+	}{
+		{"First set", 0, 0.75, "6-4", 0.25},
+		{"Second set", 1, 0.25, "4-6", 0.25},
+		{"Third set", 2, 1.0, "6-3", 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := markets[tt.set]
+			assert.Equal(t, tt.set+1, set.Set, "Expected set number %d", tt.set+1)
+			assert.Equal(t, Moneyline, set.Winner.Market, "Expected winner market %s", Moneyline)
+			assert.InDelta(t, tt.expectedWinner, set.Winner.ProbA, 0.001, "Expected winner ProbA")
+
+			require.Len(t, set.CorrectScore, len(setScores), "Expected every set score")
+			total := 0.0
+			for _, cs := range set.CorrectScore {
+				total += cs.ProbA
+				if cs.Line == tt.score {
+					assert.InDelta(t, tt.expectedScore, cs.ProbA, 0.001, "Expected ProbA for %s", tt.score)
+				}
+			}
+			assert.InDelta(t, 1.0, total, 0.001, "Set scores should sum to 1")
+		})
+	}
+}
+
+func TestGetSetMarketsLines(t *testing.T) {
+	results := createTestSimulatedMatches()
+	set := GetSetMarkets(results, 3)[0]
+
+	require.Len(t, set.GameHandicaps, int(2*SET_GAME_SPREAD+1), "Expected set handicap lines")
+	assert.Equal(t, "-5.5", set.GameHandicaps[0].Line, "Expected first handicap line")
+	assert.Equal(t, "5.5", set.GameHandicaps[len(set.GameHandicaps)-1].Line, "Expected last handicap line")
+	for _, h := range set.GameHandicaps {
+		assert.Equal(t, Handicap, h.Market, "Expected market %s", Handicap)
+		if h.Line == "-1.5" {
+			// first sets 6-4, 6-2, 3-6, 7-6
+			assert.InDelta(t, 0.5, h.ProbA, 0.001, "Expected ProbA for -1.5")
+		}
+	}
+
+	require.Len(t, set.GameOU, maxSetGames-minSetGames, "Expected set total lines")
+	assert.Equal(t, "6.5", set.GameOU[0].Line, "Expected first total line")
+	assert.Equal(t, "12.5", set.GameOU[len(set.GameOU)-1].Line, "Expected last total line")
+	for _, ou := range set.GameOU {
+		assert.Equal(t, Total, ou.Market, "Expected market %s", Total)
+		if ou.Line == "9.5" {
+			assert.InDelta(t, 0.5, ou.ProbA, 0.001, "Expected ProbA for 9.5")
+		}
+	}
+}
+
+func TestGetSetMarketsConditionalOnCompletion(t *testing.T) {
+	results := []sim.SimulatedMatch{
+		{
+			ASets: 2, BSets: 0,
+			SetResults: []sim.SimulatedSet{{AGames: 6, BGames: 1}, {AGames: 6, BGames: 2}},
+		},
+		{
+			ASets: 1, BSets: 0,
+			SetResults: []sim.SimulatedSet{{AGames: 6, BGames: 3}, {AGames: 1, BGames: 4}},
+			Ending:     sim.Retired,
+			RetiredA:   true,
+		},
+	}
+
+	markets := GetSetMarkets(results, 5)
+	require.Len(t, markets, 2, "Sets that were never completed should be left out")
+	assert.InDelta(t, 1.0, markets[1].Winner.ProbA, 0.001, "Unfinished second set should be void")
+}
@@ -0,0 +1,116 @@
+package format
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+)
+
+// SmoothLines makes a ladder of handicap or total lines coherent: the probability of A (or the
+// over) ignoring pushes never falls as a handicap rises nor rises as a total rises, and quarter
+// lines are the average of their neighbouring lines. Half and whole lines are fitted by isotonic
+// regression, which leaves a coherent ladder as it is, and quarter lines are rebuilt from them
+// when both neighbours are quoted. Lines keep their order and push probabilities.
+func SmoothLines(probs []Probability) []Probability {
+	type point struct {
+		index int
+		line  float64
+	}
+	var base, quarters []point
+	for i, p := range probs {
+		line, err := strconv.ParseFloat(p.Line, 64)
+		if err != nil || p.Market != Handicap && p.Market != Total {
+			continue
+		}
+		if isQuarterLine(line) {
+			quarters = append(quarters, point{i, line})
+		} else {
+			base = append(base, point{i, line})
+		}
+	}
+
+	out := slices.Clone(probs)
+	fit := func(points []point) {
+		slices.SortStableFunc(points, func(x, y point) int { return cmp.Compare(x.line, y.line) })
+		values := make([]float64, len(points))
+		for i, pt := range points {
+			values[i] = noPushProbability(out[pt.index])
+			if out[pt.index].Market == Total {
+				values[i] = -values[i]
+			}
+		}
+		for i, v := range isotonic(values) {
+			// Lines already in order are kept exactly.
+			if v == values[i] {
+				continue
+			}
+			if out[points[i].index].Market == Total {
+				v = -v
+			}
+			setNoPushProbability(&out[points[i].index], v)
+		}
+	}
+	fit(base)
+
+	byLine := make(map[float64]Probability, len(base))
+	for _, pt := range base {
+		byLine[pt.line] = out[pt.index]
+	}
+	var unmatched []point
+	for _, pt := range quarters {
+		lower, okLower := byLine[pt.line-0.25]
+		upper, okUpper := byLine[pt.line+0.25]
+		if !okLower || !okUpper {
+			unmatched = append(unmatched, pt)
+			continue
+		}
+		out[pt.index].ProbA = (lower.ProbA + upper.ProbA) / 2
+		out[pt.index].ProbB = (lower.ProbB + upper.ProbB) / 2
+		out[pt.index].Push = (lower.Push + upper.Push) / 2
+	}
+	fit(unmatched)
+	return out
+}
+
+// isotonic returns the non-decreasing sequence closest to values in least squares, by pooling
+// adjacent violators.
+func isotonic(values []float64) []float64 {
+	type block struct {
+		sum float64
+		n   int
+	}
+	var blocks []block
+	for _, v := range values {
+		blocks = append(blocks, block{v, 1})
+		for len(blocks) > 1 {
+			last, prev := blocks[len(blocks)-1], blocks[len(blocks)-2]
+			if prev.sum/float64(prev.n) <= last.sum/float64(last.n) {
+				break
+			}
+			blocks = append(blocks[:len(blocks)-2], block{prev.sum + last.sum, prev.n + last.n})
+		}
+	}
+
+	out := make([]float64, 0, len(values))
+	for _, b := range blocks {
+		for range b.n {
+			out = append(out, b.sum/float64(b.n))
+		}
+	}
+	return out
+}
+
+// noPushProbability returns the probability of A given that the line does not push.
+func noPushProbability(p Probability) float64 {
+	if p.ProbA+p.ProbB == 0 {
+		return 0
+	}
+	return p.ProbA / (p.ProbA + p.ProbB)
+}
+
+// setNoPushProbability sets the probabilities of A and B from the probability of A given that
+// the line does not push, keeping the push probability.
+func setNoPushProbability(p *Probability, v float64) {
+	p.ProbA = v * (1 - p.Push)
+	p.ProbB = (1 - v) * (1 - p.Push)
+}
@@ -0,0 +1,96 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsotonic(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []float64
+		expected []float64
+	}{
+		{"Empty", nil, []float64{}},
+		{"Sorted", []float64{0.1, 0.2, 0.2, 0.5}, []float64{0.1, 0.2, 0.2, 0.5}},
+		{"One violation", []float64{0.1, 0.4, 0.2, 0.5}, []float64{0.1, 0.3, 0.3, 0.5}},
+		{"Pooled backwards", []float64{0.3, 0.5, 0.2, 0.1}, []float64{0.275, 0.275, 0.275, 0.275}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := isotonic(tt.values)
+			require.Len(t, got, len(tt.expected))
+			for i := range got {
+				assert.InDelta(t, tt.expected[i], got[i], 1e-9)
+			}
+		})
+	}
+}
+
+func TestSmoothLines(t *testing.T) {
+	// The over of 22.0 is priced above the over of 21.5 and 22.25 is not between its neighbours.
+	totals := []Probability{
+		{Market: Total, Line: "21.5", ProbA: 0.55, ProbB: 0.45},
+		{Market: Total, Line: "22.0", ProbA: 0.6, ProbB: 0.3, Push: 0.1},
+		{Market: Total, Line: "22.25", ProbA: 0.1, ProbB: 0.9},
+		{Market: Total, Line: "22.5", ProbA: 0.59, ProbB: 0.41},
+		{Market: Total, Line: "23.5", ProbA: 0.4, ProbB: 0.6},
+		{Market: Total, Line: "24.75", ProbA: 0.3, ProbB: 0.6, Push: 0.1},
+	}
+	smoothed := SmoothLines(totals)
+	require.Len(t, smoothed, len(totals))
+
+	prev := 1.0
+	for _, p := range smoothed {
+		assert.InDelta(t, 1, p.ProbA+p.ProbB+p.Push, 1e-9, "Line %s", p.Line)
+		v := noPushProbability(p)
+		assert.LessOrEqual(t, v, prev+1e-9, "Expected the over not to rise at %s", p.Line)
+		prev = v
+	}
+	// 21.5 and 22.0 are pooled and 22.0 keeps its push.
+	pooled := (0.55 + 0.6/0.9) / 2
+	assert.InDelta(t, pooled, smoothed[0].ProbA, 1e-9)
+	assert.InDelta(t, pooled*0.9, smoothed[1].ProbA, 1e-9)
+	assert.InDelta(t, 0.1, smoothed[1].Push, 1e-9)
+	// 22.25 is rebuilt from 22.0 and 22.5.
+	assert.InDelta(t, (smoothed[1].ProbA+smoothed[3].ProbA)/2, smoothed[2].ProbA, 1e-9)
+	assert.InDelta(t, 0.05, smoothed[2].Push, 1e-9)
+	// 24.75 has no neighbours and is left as it is.
+	assert.Equal(t, totals[5], smoothed[5])
+	assert.InDelta(t, 0.55, totals[0].ProbA, 1e-9, "Expected the input to be left unchanged")
+
+	// Handicaps rise with the line, whatever the order they are given in.
+	handicaps := []Probability{
+		{Market: Handicap, Line: "1.5", ProbA: 0.6, ProbB: 0.4},
+		{Market: Handicap, Line: "-1.5", ProbA: 0.7, ProbB: 0.3},
+	}
+	smoothed = SmoothLines(handicaps)
+	assert.Equal(t, "1.5", smoothed[0].Line)
+	assert.InDelta(t, 0.65, smoothed[0].ProbA, 1e-9)
+	assert.InDelta(t, 0.65, smoothed[1].ProbA, 1e-9)
+}
+
+func TestSmoothLinesKeepsSimulatedLadders(t *testing.T) {
+	results := createTestSimulatedMatches()
+	for _, lines := range []LineType{HalfLines, WholeLines, QuarterLines} {
+		totals := GetGameTotalLines(results, 3, lines)
+		handicaps := GetGameHandicapLines(results, 3, lines)
+		for i, p := range SmoothLines(totals) {
+			assert.InDelta(t, totals[i].ProbA, p.ProbA, 1e-9, "%s total %s", lines, p.Line)
+		}
+		for i, p := range SmoothLines(handicaps) {
+			assert.InDelta(t, handicaps[i].ProbA, p.ProbA, 1e-9, "%s handicap %s", lines, p.Line)
+		}
+	}
+
+	cfg := MarketConfig{
+		BestOf: 3,
+		Lines:  LineOptions{Type: QuarterLines, GameTotals: []float64{23, 22.5}, Smooth: true},
+	}
+	sel, err := DefaultRegistry().Select([]string{"GameOU"})
+	require.NoError(t, err)
+	markets := DefaultRegistry().Derive(results, cfg, sel)
+	assert.Equal(t, SmoothLines(GetGameTotalsAt(results, []float64{23, 22.5})), markets["GameOU"])
+}
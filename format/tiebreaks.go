@@ -0,0 +1,139 @@
+package format
+
+import (
+	"fmt"
+	"gotennis/sim"
+	"strconv"
+)
+
+// TiebreakMarkets holds the tiebreak markets of a match.
+type TiebreakMarkets struct {
+	// InMatch is the probability of at least one tiebreak in the match.
+	InMatch Probability `json:"InMatch"`
+	// Total are over/under lines on the number of tiebreaks in the match.
+	Total []Probability `json:"Total"`
+	// InSet is the probability of a tiebreak in each set, conditional on the set being completed.
+	InSet []Probability `json:"InSet"`
+	// Winner is the probability of A winning the tiebreak of each set, conditional on it being played.
+	Winner []Probability `json:"Winner"`
+}
+
+// PricedTiebreakMarkets is a TiebreakMarkets with every probability quoted as odds.
+type PricedTiebreakMarkets struct {
+	InMatch Price   `json:"InMatch"`
+	Total   []Price `json:"Total"`
+	InSet   []Price `json:"InSet"`
+	Winner  []Price `json:"Winner"`
+}
+
+// GetTiebreakMarkets calculates the tiebreak markets of a match.
+func GetTiebreakMarkets(results []sim.SimulatedMatch, bestof int) TiebreakMarkets {
+	counts := make([]int, len(results))
+	for i, m := range results {
+		for _, set := range m.SetResults {
+			if set.Tiebreak {
+				counts[i]++
+			}
+		}
+	}
+
+	res := TiebreakMarkets{
+		InMatch: getTiebreakCount(counts, Tiebreak, "match", 0.5),
+	}
+	for i := 0.5; i < float64(bestof); i++ {
+		res.Total = append(res.Total, getTiebreakCount(counts, Total, fmt.Sprintf("%.1f", i), i))
+	}
+
+	for set := range bestof {
+		played := setsPlayed(results, set)
+		if len(played) == 0 {
+			break
+		}
+		line := strconv.Itoa(set + 1)
+		res.InSet = append(res.InSet, getSetProbability(
+			played,
+			Tiebreak,
+			line,
+			func(s sim.SimulatedSet) bool { return s.Tiebreak },
+		))
+
+		var tiebreaks []sim.SimulatedSet
+		for _, s := range played {
+			if s.Tiebreak {
+				tiebreaks = append(tiebreaks, s)
+			}
+		}
+		if len(tiebreaks) > 0 {
+			res.Winner = append(res.Winner, getSetProbability(
+				tiebreaks,
+				Moneyline,
+				line,
+				func(s sim.SimulatedSet) bool { return s.AGames > s.BGames },
+			))
+		}
+	}
+	return res
+}
+
+// getTiebreakCount returns the probability of more than 'over' tiebreaks being played.
+func getTiebreakCount(counts []int, market Market, line string, over float64) Probability {
+	n := 0
+	for _, c := range counts {
+		if float64(c) > over {
+			n++
+		}
+	}
+
+	return Probability{
+		Market: market,
+		Line:   line,
+		ProbA:  float64(n) / float64(len(counts)),
+		ProbB:  1 - float64(n)/float64(len(counts)),
+	}
+}
+
+// Price quotes every tiebreak market as odds.
+func (m TiebreakMarkets) Price(cfg OddsConfig) (PricedTiebreakMarkets, error) {
+	var priced PricedTiebreakMarkets
+	var err error
+
+	if priced.InMatch, err = GetPrice(m.InMatch, cfg); err != nil {
+		return priced, err
+	}
+	if priced.Total, err = GetPrices(m.Total, cfg); err != nil {
+		return priced, err
+	}
+	if priced.InSet, err = GetPrices(m.InSet, cfg); err != nil {
+		return priced, err
+	}
+	if priced.Winner, err = GetPrices(m.Winner, cfg); err != nil {
+		return priced, err
+	}
+	return priced, nil
+}
+
+// tiebreakFamilies returns the market family of the tiebreak markets.
+func tiebreakFamilies() []MarketFamily {
+	return []MarketFamily{
+		marketFamily[TiebreakMarkets]{
+			name: "Tiebreaks",
+			derive: func(results []sim.SimulatedMatch, cfg MarketConfig) TiebreakMarkets {
+				return GetTiebreakMarkets(results, cfg.BestOf)
+			},
+			price: func(m TiebreakMarkets, odds OddsConfig) (any, error) {
+				return m.Price(odds)
+			},
+			lines: func(m TiebreakMarkets) map[string][]Probability {
+				return map[string][]Probability{"Tiebreaks.Total": m.Total}
+			},
+			probs: func(m TiebreakMarkets) map[string][]Probability {
+				return map[string][]Probability{
+					"Tiebreaks.InMatch": {m.InMatch},
+					"Tiebreaks.Total":   m.Total,
+					"Tiebreaks.InSet":   m.InSet,
+					"Tiebreaks.Winner":  m.Winner,
+				}
+			},
+		},
+	}
+}
@@ -0,0 +1,82 @@
+package format
+
+import (
+	"gotennis/sim"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createTiebreakMatches() []sim.SimulatedMatch {
+	return []sim.SimulatedMatch{
+		{
+			ASets: 2, BSets: 1,
+			SetResults: []sim.SimulatedSet{
+				{AGames: 7, BGames: 6, Tiebreak: true},
+				{AGames: 6, BGames: 7, Tiebreak: true},
+				{AGames: 6, BGames: 3},
+			},
+		},
+		{
+			ASets: 2, BSets: 0,
+			SetResults: []sim.SimulatedSet{
+				{AGames: 6, BGames: 2},
+				{AGames: 6, BGames: 7, Tiebreak: true},
+			},
+		},
+		{
+			ASets: 0, BSets: 2,
+			SetResults: []sim.SimulatedSet{
+				{AGames: 3, BGames: 6},
+				{AGames: 2, BGames: 6},
+			},
+		},
+		{
+			ASets: 2, BSets: 1,
+			SetResults: []sim.SimulatedSet{
+				{AGames: 7, BGames: 6, Tiebreak: true},
+				{AGames: 3, BGames: 6},
+				{AGames: 6, BGames: 4},
+			},
+		},
+	}
+}
+
+func TestGetTiebreakMarkets(t *testing.T) {
+	tb := GetTiebreakMarkets(createTiebreakMatches(), 3)
+
+	assert.Equal(t, Tiebreak, tb.InMatch.Market, "Expected market %s", Tiebreak)
+	assert.InDelta(t, 0.75, tb.InMatch.ProbA, 0.001, "Expected tiebreak in 3 of 4 matches")
+
+	require.Len(t, tb.Total, 3, "Expected lines 0.5 to 2.5")
+	expectedTotals := []float64{0.75, 0.25, 0}
+	for i, prob := range tb.Total {
+		assert.Equal(t, Total, prob.Market, "Expected market %s", Total)
+		assert.InDelta(t, expectedTotals[i], prob.ProbA, 0.001, "Expected ProbA for %s tiebreaks", prob.Line)
+	}
+
+	require.Len(t, tb.InSet, 3, "Expected a line for every set")
+	expectedInSet := []float64{0.5, 0.5, 0}
+	for i, prob := range tb.InSet {
+		assert.Equal(t, Tiebreak, prob.Market, "Expected market %s", Tiebreak)
+		assert.InDelta(t, expectedInSet[i], prob.ProbA, 0.001, "Expected ProbA for a tiebreak in set %s", prob.Line)
+	}
+
+	require.Len(t, tb.Winner, 2, "Expected winners for the sets with tiebreaks")
+	assert.Equal(t, "1", tb.Winner[0].Line, "Expected first set tiebreak winner")
+	assert.InDelta(t, 1.0, tb.Winner[0].ProbA, 0.001, "A won both first set tiebreaks")
+	assert.Equal(t, "2", tb.Winner[1].Line, "Expected second set tiebreak winner")
+	assert.InDelta(t, 0.0, tb.Winner[1].ProbA, 0.001, "B won both second set tiebreaks")
+}
+
+func TestGetTiebreakMarketsSimulated(t *testing.T) {
+	results, err := sim.SimulateMatch(0.75, 0.75, 5, 2000)
+	require.NoError(t, err)
+
+	tb := GetTiebreakMarkets(results, 5)
+	assert.Greater(t, tb.InMatch.ProbA, 0.5, "Big servers should usually play a tiebreak")
+	for i := 1; i < len(tb.Total); i++ {
+		assert.LessOrEqual(t, tb.Total[i].ProbA, tb.Total[i-1].ProbA, "Over probabilities should not increase")
+	}
+}
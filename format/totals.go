@@ -0,0 +1,132 @@
+package format
+
+import (
+	"fmt"
+	"gotennis/sim"
+	"strconv"
+)
+
+// TOTAL_BAND_WIDTH is the number of total games covered by each band of GetGameTotalBands.
+const TOTAL_BAND_WIDTH = 3
+
+// GetGameOddEven calculates the probability of the total games in the match being odd.
+func GetGameOddEven(results []sim.SimulatedMatch) Probability {
+	return getMatchProbability(results, OddEven, "odd", func(m sim.SimulatedMatch) bool {
+		aGames, bGames := getMatchGames(m)
+		return (aGames+bGames)%2 == 1
+	})
+}
+
+// GetGameTotalDistribution calculates the probability of every exact total games count between the
+// fewest and most games simulated.
+func GetGameTotalDistribution(results []sim.SimulatedMatch) []Probability {
+	return getGameTotalDistribution(results, nil)
+}
+
+// getGameTotalDistribution calculates the exact total games probabilities between the fewest and
+// most games simulated and of the extra totals.
+func getGameTotalDistribution(results []sim.SimulatedMatch, extra []float64) []Probability {
+	counts := countTotalGames(results)
+
+	var totals []float64
+	first, last := totalGamesRange(counts)
+	for total := first; total <= last; total++ {
+		totals = append(totals, float64(total))
+	}
+
+	var out []Probability
+	for _, t := range withExtraLines(totals, extra) {
+		total := int(t)
+		n := 0
+		if total < len(counts) {
+			n = counts[total]
+		}
+		out = append(out, Probability{
+			Market: TotalGames,
+			Line:   strconv.Itoa(total),
+			ProbA:  float64(n) / float64(len(results)),
+			ProbB:  1 - float64(n)/float64(len(results)),
+		})
+	}
+	return out
+}
+
+// GetGameTotalBands calculates the probability of the total games falling in each band of
+// TOTAL_BAND_WIDTH games, starting from the fewest games a completed match can have.
+func GetGameTotalBands(results []sim.SimulatedMatch, bestof int) []Probability {
+	return getGameTotalBands(results, bestof, nil)
+}
+
+// getGameTotalBands calculates the banded total games probabilities of the bands up to the most
+// games simulated and of the bands starting at the extra totals.
+func getGameTotalBands(results []sim.SimulatedMatch, bestof int, extra []float64) []Probability {
+	counts := countTotalGames(results)
+	first, last := totalGamesRange(counts)
+
+	start := (bestof/2 + 1) * 6
+	for start > first {
+		start -= TOTAL_BAND_WIDTH
+	}
+	var bands []float64
+	for lo := start; lo <= last; lo += TOTAL_BAND_WIDTH {
+		bands = append(bands, float64(lo))
+	}
+
+	var out []Probability
+	for _, b := range withExtraLines(bands, extra) {
+		lo := int(b)
+		n := 0
+		for total := lo; total < lo+TOTAL_BAND_WIDTH && total < len(counts); total++ {
+			n += counts[total]
+		}
+		out = append(out, Probability{
+			Market: TotalGames,
+			Line:   fmt.Sprintf("%d-%d", lo, lo+TOTAL_BAND_WIDTH-1),
+			ProbA:  float64(n) / float64(len(results)),
+			ProbB:  1 - float64(n)/float64(len(results)),
+		})
+	}
+	return out
+}
+
+// countTotalGames returns the number of matches for every total games count, indexed by the count.
+func countTotalGames(results []sim.SimulatedMatch) []int {
+	var counts []int
+	for _, m := range results {
+		aGames, bGames := getMatchGames(m)
+		total := aGames + bGames
+		for len(counts) <= total {
+			counts = append(counts, 0)
+		}
+		counts[total]++
+	}
+	return counts
+}
+
+// totalGamesRange returns the fewest and most total games with a non-zero count.
+func totalGamesRange(counts []int) (int, int) {
+	first := 0
+	for first < len(counts) && counts[first] == 0 {
+		first++
+	}
+	return first, len(counts) - 1
+}
+
+// totalFamilies returns the market families of the odd/even, exact and banded total games.
+func totalFamilies() []MarketFamily {
+	return []MarketFamily{
+		probabilityFamily("GameOddEven", func(results []sim.SimulatedMatch, _ MarketConfig) Probability {
+			return GetGameOddEven(results)
+		}),
+		probabilitiesFamily("GameTotalPMF", false, func(results []sim.SimulatedMatch, cfg MarketConfig) []Probability {
+			return getGameTotalDistribution(results, cfg.Lines.Extra["GameTotalPMF"])
+		}),
+		probabilitiesFamily(
+			"GameTotalBands",
+			false,
+			func(results []sim.SimulatedMatch, cfg MarketConfig) []Probability {
+				return getGameTotalBands(results, cfg.BestOf, cfg.Lines.Extra["GameTotalBands"])
+			},
+		),
+	}
+}
@@ -0,0 +1,90 @@
+package format
+
+import (
+	"gotennis/sim"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetGameOddEven(t *testing.T) {
+	// totals 29, 15, 17, 32
+	result := GetGameOddEven(createTestSimulatedMatches())
+	assert.Equal(t, OddEven, result.Market, "Expected market %s", OddEven)
+	assert.Equal(t, "odd", result.Line, "Expected line 'odd'")
+	assert.InDelta(t, 0.75, result.ProbA, 0.001, "Expected 3 of 4 totals to be odd")
+	assert.InDelta(t, 0.25, result.ProbB, 0.001, "Expected 1 of 4 totals to be even")
+}
+
+func TestGetGameTotalDistribution(t *testing.T) {
+	result := GetGameTotalDistribution(createTestSimulatedMatches())
+
+	require.Len(t, result, 32-15+1, "Expected every total between the fewest and most games")
+	assert.Equal(t, "15", result[0].Line, "Expected first total 15")
+	assert.Equal(t, "32", result[len(result)-1].Line, "Expected last total 32")
+
+	expected := map[string]float64{"15": 0.25, "17": 0.25, "29": 0.25, "32": 0.25, "20": 0}
+	total := 0.0
+	for _, prob := range result {
+		assert.Equal(t, TotalGames, prob.Market, "Expected market %s", TotalGames)
+		if p, ok := expected[prob.Line]; ok {
+			assert.InDelta(t, p, prob.ProbA, 0.001, "Expected ProbA for %s games", prob.Line)
+		}
+		total += prob.ProbA
+	}
+	assert.InDelta(t, 1.0, total, 0.001, "Distribution should sum to 1")
+}
+
+func TestGetGameTotalBands(t *testing.T) {
+	result := GetGameTotalBands(createTestSimulatedMatches(), 3)
+
+	require.NotEmpty(t, result)
+	assert.Equal(t, "12-14", result[0].Line, "Expected first band to start at the fewest games")
+	assert.Equal(t, "30-32", result[len(result)-1].Line, "Expected last band to cover the most games")
+
+	expected := map[string]float64{"12-14": 0, "15-17": 0.5, "27-29": 0.25, "30-32": 0.25}
+	total := 0.0
+	for _, prob := range result {
+		assert.Equal(t, TotalGames, prob.Market, "Expected market %s", TotalGames)
+		if p, ok := expected[prob.Line]; ok {
+			assert.InDelta(t, p, prob.ProbA, 0.001, "Expected ProbA for %s games", prob.Line)
+		}
+		total += prob.ProbA
+	}
+	assert.InDelta(t, 1.0, total, 0.001, "Bands should sum to 1")
+}
+
+func TestGetGameTotalBandsCoverRetirements(t *testing.T) {
+	results := append(createTestSimulatedMatches(), sim.SimulatedMatch{
+		SetResults: []sim.SimulatedSet{{AGames: 3, BGames: 2}},
+		Ending:     sim.Retired,
+	})
+
+	result := GetGameTotalBands(results, 3)
+	require.NotEmpty(t, result)
+	assert.Equal(t, "3-5", result[0].Line, "Expected bands to reach down to the retired match")
+	total := 0.0
+	for _, prob := range result {
+		total += prob.ProbA
+	}
+	assert.InDelta(t, 1.0, total, 0.001, "Bands should sum to 1")
+}
+
+func TestGetGameTotalDistributionMatchesTotals(t *testing.T) {
+	results, err := sim.SimulateMatch(0.65, 0.62, 3, 3000)
+	require.NoError(t, err)
+
+	pmf := GetGameTotalDistribution(results)
+	over := getGameTotal(results, 22.5)
+	sum := 0.0
+	for _, prob := range pmf {
+		total, err := strconv.Atoi(prob.Line)
+		require.NoError(t, err)
+		if total > 22 {
+			sum += prob.ProbA
+		}
+	}
+	assert.InDelta(t, over.ProbA, sum, 1e-9, "Distribution above 22 should match over 22.5")
+}
@@ -0,0 +1,167 @@
+package format
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Side is the outcome of a two-way Probability an offer is on.
+type Side string
+
+const (
+	// SideA is the ProbA outcome: player A, over, yes or the quoted line.
+	SideA Side = "A"
+	// SideB is the ProbB outcome: player B, under, no or anything but the quoted line.
+	SideB Side = "B"
+)
+
+// Offer is a bookmaker price for one side of a market.
+type Offer struct {
+	// Market is the name of the market as returned by MarketFamily.Probabilities, e.g. "GameOU"
+	// or "Set1.Winner".
+	Market string `json:"market"`
+	Line   string `json:"line"`
+	Side   Side   `json:"side"`
+	// Odds are the offered decimal odds.
+	Odds float64 `json:"odds"`
+}
+
+// StakeConfig describes how stakes are sized for offers with an edge.
+type StakeConfig struct {
+	Bankroll float64 `json:"bankroll"`
+	// KellyFraction scales the full Kelly stake, e.g. 0.5 for half Kelly.
+	KellyFraction float64 `json:"kellyFraction"`
+	// Caps limit the total stake on the offers of a market, keyed by the market name.
+	Caps map[string]float64 `json:"caps,omitempty"`
+}
+
+// Value is the model view of an Offer.
+type Value struct {
+	Offer
+	// Type is the kind of market the offer is on, e.g. AH for every handicap market.
+	Type Market `json:"type"`
+	// Probability and Push are the model probabilities of the side winning and of a push.
+	Probability float64 `json:"probability"`
+	Push        float64 `json:"push,omitempty"`
+	// FairOdds are the decimal odds without margin, ignoring pushes, or zero if the side cannot win.
+	FairOdds float64 `json:"fairOdds"`
+	// Edge is the model probability less the probability implied by the odds, both ignoring pushes.
+	Edge float64 `json:"edge"`
+	// EV is the expected profit per unit staked.
+	EV float64 `json:"ev"`
+	// Kelly is the full Kelly fraction of the bankroll, zero for offers without an edge.
+	Kelly float64 `json:"kelly"`
+	// Stake is the recommended stake after the Kelly fraction and the market caps.
+	Stake float64 `json:"stake"`
+	// Capped reports whether the stake was reduced by a market cap.
+	Capped bool `json:"capped,omitempty"`
+}
+
+// ParseOffer parses an offer written as "<market>:<line>:<side>:<odds>", e.g. "GameOU:22.5:A:1.90"
+// for over 22.5 games at 1.90. The line of moneyline markets is "ml".
+func ParseOffer(s string) (Offer, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 4 {
+		return Offer{}, fmt.Errorf("offer %q: expected <market>:<line>:<side>:<odds>", s)
+	}
+	o := Offer{Market: parts[0], Line: parts[1], Side: Side(strings.ToUpper(parts[2]))}
+	if o.Side != SideA && o.Side != SideB {
+		return Offer{}, fmt.Errorf("offer %q: side must be A or B", s)
+	}
+	odds, err := strconv.ParseFloat(parts[3], 64)
+	if err != nil || !(odds > 1) || math.IsInf(odds, 0) {
+		return Offer{}, fmt.Errorf("offer %q: odds must be decimal odds greater than 1", s)
+	}
+	o.Odds = odds
+	return o, nil
+}
+
+// ParseCaps parses stake caps written as "<market>:<cap>" separated by commas, e.g.
+// "Moneyline:100,GameOU:50".
+func ParseCaps(s string) (map[string]float64, error) {
+	caps := make(map[string]float64)
+	for _, c := range strings.Split(s, ",") {
+		market, capStr, ok := strings.Cut(strings.TrimSpace(c), ":")
+		if !ok {
+			return nil, fmt.Errorf("cap %q: expected <market>:<cap>", c)
+		}
+		v, err := strconv.ParseFloat(capStr, 64)
+		if err != nil || v < 0 || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("cap %q: must be a non-negative number", c)
+		}
+		caps[market] = v
+	}
+	return caps, nil
+}
+
+// GetValues finds the model probability of every offer in probs, keyed as by
+// Registry.Probabilities, and sizes a stake for the offers with a positive expected value.
+// Stakes of offers on the same market are scaled down together to stay within its cap.
+func GetValues(probs map[string][]Probability, offers []Offer, cfg StakeConfig) ([]Value, error) {
+	if cfg.Bankroll < 0 || cfg.KellyFraction < 0 || cfg.KellyFraction > 1 {
+		return nil, errors.New("bankroll must not be negative and the Kelly fraction between 0 and 1")
+	}
+
+	out := make([]Value, 0, len(offers))
+	staked := make(map[string]float64)
+	for _, o := range offers {
+		p, ok := FindProbability(probs[o.Market], o.Line)
+		if !ok {
+			return nil, fmt.Errorf("no market %s with line %s", o.Market, o.Line)
+		}
+		v := getValue(p, o)
+		v.Stake = cfg.Bankroll * cfg.KellyFraction * v.Kelly
+		staked[o.Market] += v.Stake
+		out = append(out, v)
+	}
+
+	for i := range out {
+		limit, ok := cfg.Caps[out[i].Market]
+		if ok && staked[out[i].Market] > limit && out[i].Stake > 0 {
+			out[i].Stake *= limit / staked[out[i].Market]
+			out[i].Capped = true
+		}
+	}
+	return out, nil
+}
+
+// getValue calculates the edge, expected value and full Kelly fraction of an offer on p.
+func getValue(p Probability, o Offer) Value {
+	win, lose := p.ProbA, p.ProbB
+	if o.Side == SideB {
+		win, lose = lose, win
+	}
+	v := Value{Offer: o, Type: p.Market, Probability: win, Push: p.Push}
+
+	if win > 0 {
+		v.FairOdds = (win + lose) / win
+	}
+	if win+lose > 0 {
+		v.Edge = win/(win+lose) - 1/o.Odds
+	}
+	v.EV = win*(o.Odds-1) - lose
+
+	// Kelly with pushes maximises win*log(1+b*f) + lose*log(1-f), as a push leaves the bankroll as is.
+	b := o.Odds - 1
+	if v.EV > 0 {
+		v.Kelly = (b*win - lose) / (b * (win + lose))
+	}
+	return v
+}
+
+// FindProbability returns the Probability quoted on the line, comparing numeric lines by value.
+func FindProbability(probs []Probability, line string) (Probability, bool) {
+	want, wantErr := strconv.ParseFloat(line, 64)
+	for _, p := range probs {
+		if p.Line == line {
+			return p, true
+		}
+		if got, err := strconv.ParseFloat(p.Line, 64); err == nil && wantErr == nil && got == want {
+			return p, true
+		}
+	}
+	return Probability{}, false
+}
@@ -0,0 +1,99 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOffer(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Offer
+		wantErr  bool
+	}{
+		{input: "Moneyline:ml:A:1.55", expected: Offer{Market: "Moneyline", Line: "ml", Side: SideA, Odds: 1.55}},
+		{input: " GameOU:22.5:b:1.95", expected: Offer{Market: "GameOU", Line: "22.5", Side: SideB, Odds: 1.95}},
+		{input: "GameOU:22.5:1.95", wantErr: true},
+		{input: "GameOU:22.5:C:1.95", wantErr: true},
+		{input: "GameOU:22.5:A:1.0", wantErr: true},
+		{input: "GameOU:22.5:A:NaN", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			o, err := ParseOffer(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, o)
+		})
+	}
+}
+
+func TestParseCaps(t *testing.T) {
+	caps, err := ParseCaps("Moneyline:100, GameOU:50")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"Moneyline": 100, "GameOU": 50}, caps)
+
+	_, err = ParseCaps("Moneyline")
+	assert.Error(t, err)
+	_, err = ParseCaps("Moneyline:-1")
+	assert.Error(t, err)
+}
+
+func TestGetValues(t *testing.T) {
+	probs := map[string][]Probability{
+		"Moneyline": {{Market: Moneyline, Line: "ml", ProbA: 0.6, ProbB: 0.4}},
+		"GameOU":    {{Market: Total, Line: "22.0", ProbA: 0.4, ProbB: 0.4, Push: 0.2}},
+	}
+	offers := []Offer{
+		{Market: "Moneyline", Line: "ml", Side: SideA, Odds: 2},
+		{Market: "Moneyline", Line: "ml", Side: SideB, Odds: 2},
+		{Market: "GameOU", Line: "22", Side: SideA, Odds: 2.5},
+	}
+
+	t.Run("Kelly stakes", func(t *testing.T) {
+		values, err := GetValues(probs, offers, StakeConfig{Bankroll: 1000, KellyFraction: 0.5})
+		require.NoError(t, err)
+		require.Len(t, values, 3)
+
+		assert.InDelta(t, 0.6, values[0].Probability, 0.001)
+		assert.InDelta(t, 1.6667, values[0].FairOdds, 0.001)
+		assert.InDelta(t, 0.1, values[0].Edge, 0.001)
+		assert.InDelta(t, 0.2, values[0].EV, 0.001)
+		assert.InDelta(t, 0.2, values[0].Kelly, 0.001)
+		assert.InDelta(t, 100, values[0].Stake, 0.001)
+
+		assert.InDelta(t, -0.2, values[1].EV, 0.001)
+		assert.Zero(t, values[1].Kelly, "Expected no stake without an edge")
+		assert.Zero(t, values[1].Stake)
+
+		assert.InDelta(t, 0.2, values[2].Push, 0.001)
+		assert.InDelta(t, 2, values[2].FairOdds, 0.001, "Expected fair odds without the push")
+		assert.InDelta(t, 0.2, values[2].EV, 0.001)
+		assert.InDelta(t, 1.0/6, values[2].Kelly, 0.001)
+		assert.InDelta(t, 83.333, values[2].Stake, 0.001)
+	})
+
+	t.Run("Capped market", func(t *testing.T) {
+		cfg := StakeConfig{Bankroll: 1000, KellyFraction: 0.5, Caps: map[string]float64{"Moneyline": 50, "GameOU": 100}}
+		values, err := GetValues(probs, offers, cfg)
+		require.NoError(t, err)
+		assert.InDelta(t, 50, values[0].Stake, 0.001)
+		assert.True(t, values[0].Capped)
+		assert.False(t, values[1].Capped, "Expected offers without a stake not to be capped")
+		assert.InDelta(t, 83.333, values[2].Stake, 0.001)
+		assert.False(t, values[2].Capped)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := GetValues(probs, []Offer{{Market: "GameOU", Line: "23.5", Side: SideA, Odds: 2}}, StakeConfig{})
+		assert.Error(t, err, "Expected error for an unknown line")
+		_, err = GetValues(probs, offers, StakeConfig{KellyFraction: 2})
+		assert.Error(t, err, "Expected error for a Kelly fraction above 1")
+	})
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"gotennis/format"
+	"gotennis/sim"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+)
+
+// greeksHandler returns the partial derivatives of every market probability with respect to the
+// serve probabilities p1 and p2. Each player is bumped h (default 0.01) down and up, clipped to
+// [0, 1], and every bump is simulated with the same seed, so that the central differences measure
+// the move of the markets rather than the noise of the simulations. seed fixes the simulations,
+// a random one is used without it.
+func greeksHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	p1, err1 := strconv.ParseFloat(q.Get("p1"), 64)
+	p2, err2 := strconv.ParseFloat(q.Get("p2"), 64)
+	bestof, err3 := strconv.Atoi(q.Get("bestof"))
+	if err := validateInputs(p1, p2, bestof, err1, err2, err3); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// A zero seed would not couple the simulations.
+	opts := sim.Options{Simulations: 1000000, Seed: rand.Uint64() | 1}
+	if tmp, err := strconv.Atoi(q.Get("simulations")); err == nil && tmp > 0 {
+		opts.Simulations = tmp
+	}
+	if seedStr := q.Get("seed"); seedStr != "" {
+		seed, err := strconv.ParseUint(seedStr, 10, 64)
+		if err != nil || seed == 0 {
+			http.Error(w, "invalid seed value: must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		opts.Seed = seed
+	}
+	h := 0.01
+	if hStr := q.Get("h"); hStr != "" {
+		v, err := strconv.ParseFloat(hStr, 64)
+		if err != nil || !(v > 0) || v > 0.5 {
+			http.Error(w, "invalid h value: must be above 0 and at most 0.5", http.StatusBadRequest)
+			return
+		}
+		h = v
+	}
+
+	lines, err := parseLineOptions(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sel, err := parseMarkets(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts.RecordGames = sel.NeedsGames()
+
+	bump := func(p1Down, p1Up, p2Down, p2Up float64) (format.Bumped, error) {
+		down, err := simulateProbabilities(p1Down, p2Down, bestof, opts, lines, sel)
+		if err != nil {
+			return format.Bumped{}, err
+		}
+		up, err := simulateProbabilities(p1Up, p2Up, bestof, opts, lines, sel)
+		if err != nil {
+			return format.Bumped{}, err
+		}
+		return format.Bumped{Down: down, Up: up, Width: max(p1Up-p1Down, p2Up-p2Down)}, nil
+	}
+	p1Bump, err := bump(max(p1-h, 0), min(p1+h, 1), p2, p2)
+	if err != nil {
+		http.Error(w, "Internal Server Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	p2Bump, err := bump(p1, p1, max(p2-h, 0), min(p2+h, 1))
+	if err != nil {
+		http.Error(w, "Internal Server Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(format.GetGreeks(p1Bump, p2Bump))
+}
+
+// simulateProbabilities simulates the match and derives the probabilities of the selected markets.
+func simulateProbabilities(
+	p1, p2 float64,
+	bestof int,
+	opts sim.Options,
+	lines format.LineOptions,
+	sel format.Selection,
+) (map[string][]format.Probability, error) {
+	matches, err := sim.SimulateMatchWithOptions(p1, p2, bestof, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, errors.New("no simulated matches")
+	}
+	cfg := format.MarketConfig{BestOf: bestof, Lines: lines, P1: p1, P2: p2}
+	return registry.Probabilities(deriveProbabilities(matches, cfg, sel)), nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"gotennis/format"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGreeksHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+	}{
+		{"Greeks", "p1=0.65&p2=0.62&bestof=3&simulations=20000&seed=7&markets=ML,GameOU", http.StatusOK},
+		{"Clipped", "p1=0.995&p2=0.99&bestof=3&simulations=2000&seed=7&markets=ML", http.StatusOK},
+		{"Missing p1", "p2=0.62&bestof=3", http.StatusBadRequest},
+		{"Invalid bestof", "p1=0.65&p2=0.62&bestof=4", http.StatusBadRequest},
+		{"Invalid h", "p1=0.65&p2=0.62&bestof=3&h=0", http.StatusBadRequest},
+		{"Invalid seed", "p1=0.65&p2=0.62&bestof=3&seed=0", http.StatusBadRequest},
+		{"Invalid markets", "p1=0.65&p2=0.62&bestof=3&markets=Nope", http.StatusBadRequest},
+		{"Invalid lines", "p1=0.65&p2=0.62&bestof=3&lines=third", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			greeksHandler(rr, httptest.NewRequest(http.MethodGet, "/greeks?"+tt.query, nil))
+			require.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var greeks map[string][]format.Greek
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &greeks), "Failed to parse JSON response")
+			require.Len(t, greeks["Moneyline"], 1)
+			ml := greeks["Moneyline"][0]
+			assert.Positive(t, ml.P1.A, "A should gain from a better serve")
+			assert.Negative(t, ml.P2.A, "A should lose from a better serve of B")
+			assert.InDelta(t, 0, ml.P1.A+ml.P1.B, 1e-9)
+
+			// The same seed returns the same greeks.
+			again := httptest.NewRecorder()
+			greeksHandler(again, httptest.NewRequest(http.MethodGet, "/greeks?"+tt.query, nil))
+			assert.JSONEq(t, rr.Body.String(), again.Body.String())
+		})
+	}
+}
@@ -0,0 +1,224 @@
+// Package ledger records placed bets together with the model price at the time, settles them
+// from final scores and reports their profit and loss.
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"gotennis/format"
+	"gotennis/settlement"
+	"math"
+	"sync"
+	"time"
+)
+
+// Bet is a placed bet on one side of a market.
+type Bet struct {
+	ID int `json:"id"`
+	// Match identifies the match, bets on the same match are settled together.
+	Match    string    `json:"match"`
+	BestOf   int       `json:"bestof"`
+	PlacedAt time.Time `json:"placedAt"`
+	// Market is the name of the market as returned by format.MarketFamily.Probabilities and Type
+	// the kind of market, e.g. AH for every handicap market.
+	Market string        `json:"market"`
+	Type   format.Market `json:"type"`
+	Line   string        `json:"line"`
+	Side   format.Side   `json:"side"`
+	// Odds are the decimal odds taken.
+	Odds  float64 `json:"odds"`
+	Stake float64 `json:"stake"`
+	// ModelProbability and ModelOdds are the model probability and fair odds when the bet was placed.
+	ModelProbability float64 `json:"modelProbability"`
+	ModelOdds        float64 `json:"modelOdds"`
+	// ClosingOdds are the decimal odds of the side when the market closed, zero when unknown.
+	ClosingOdds float64 `json:"closingOdds,omitempty"`
+	// Outcome is empty until the bet is settled.
+	Outcome   settlement.Outcome `json:"outcome,omitempty"`
+	SettledAt *time.Time         `json:"settledAt,omitempty"`
+	Profit    float64            `json:"profit"`
+}
+
+// Ledger is a persistent list of bets. It is safe for concurrent use.
+type Ledger struct {
+	mu    sync.Mutex
+	store *FileStore
+}
+
+// Open returns the ledger kept in the file at path.
+func Open(path string) (*Ledger, error) {
+	l := &Ledger{store: NewFileStore(path)}
+	if _, err := l.store.load(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Place records a new bet and returns it with its ID and placement time.
+func (l *Ledger) Place(b Bet) (Bet, error) {
+	if err := b.Validate(); err != nil {
+		return Bet{}, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	st, err := l.store.load()
+	if err != nil {
+		return Bet{}, err
+	}
+	b.ID = st.NextID
+	b.PlacedAt = time.Now().UTC()
+	b.Outcome, b.SettledAt, b.Profit = "", nil, 0
+	st.NextID++
+	st.Bets = append(st.Bets, b)
+	return b, l.store.save(st)
+}
+
+// Validate checks that the bet names a match and a market line and has a valid side, odds and
+// stake.
+func (b Bet) Validate() error {
+	if b.Match == "" || b.Market == "" || b.Line == "" {
+		return errors.New("match, market and line are required")
+	}
+	if b.BestOf != 3 && b.BestOf != 5 {
+		return errors.New("bestof must be 3 or 5")
+	}
+	if b.Side != format.SideA && b.Side != format.SideB {
+		return errors.New("side must be A or B")
+	}
+	if !(b.Odds > 1) || math.IsInf(b.Odds, 0) {
+		return errors.New("odds must be decimal odds greater than 1")
+	}
+	if !(b.Stake > 0) || math.IsInf(b.Stake, 0) {
+		return errors.New("stake must be positive")
+	}
+	return nil
+}
+
+// Bets returns every bet in the order they were placed.
+func (l *Ledger) Bets() ([]Bet, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	st, err := l.store.load()
+	return st.Bets, err
+}
+
+// SetClosingOdds records the closing odds of a bet for its closing line value.
+func (l *Ledger) SetClosingOdds(id int, odds float64) (Bet, error) {
+	if !(odds > 1) || math.IsInf(odds, 0) {
+		return Bet{}, errors.New("closing odds must be decimal odds greater than 1")
+	}
+	return l.update(id, func(b *Bet) error {
+		b.ClosingOdds = odds
+		return nil
+	})
+}
+
+// Settle settles the open bets of a match from its final score with the same code that prices
+// the markets, see settlement.Settle, and returns them. Bets on sets that were not played are
+// void, while bets the score does not settle, such as those on breaks of serve, are left open.
+func (l *Ledger) Settle(
+	r *format.Registry,
+	match string,
+	score settlement.Score,
+	rules settlement.Rules,
+) ([]Bet, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	st, err := l.store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var open []int
+	for i, b := range st.Bets {
+		if b.Match == match && b.Outcome == "" {
+			open = append(open, i)
+		}
+	}
+	if len(open) == 0 {
+		return nil, fmt.Errorf("no open bets on match %q", match)
+	}
+
+	cfg, err := settlementConfig(st.Bets, open)
+	if err != nil {
+		return nil, err
+	}
+	settled, err := settlement.Settle(r, score, cfg, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	out := make([]Bet, 0, len(open))
+	for _, i := range open {
+		b := &st.Bets[i]
+		outcome, ok := settled.Outcome(b.Market, b.Line, b.Side)
+		if !ok && !settlement.IsSetMarket(b.Market) {
+			continue
+		}
+		b.Outcome = outcome
+		b.SettledAt = &now
+		b.Profit = Profit(b.Outcome, b.Stake, b.Odds)
+		out = append(out, *b)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("the score does not settle any open bet on match %q", match)
+	}
+	return out, l.store.save(st)
+}
+
+// update applies f to the bet with the given ID and saves the ledger.
+func (l *Ledger) update(id int, f func(b *Bet) error) (Bet, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	st, err := l.store.load()
+	if err != nil {
+		return Bet{}, err
+	}
+	for i := range st.Bets {
+		if st.Bets[i].ID != id {
+			continue
+		}
+		if err := f(&st.Bets[i]); err != nil {
+			return Bet{}, err
+		}
+		return st.Bets[i], l.store.save(st)
+	}
+	return Bet{}, fmt.Errorf("no bet with id %d", id)
+}
+
+// Profit returns the profit of a stake at the odds for the outcome.
+func Profit(o settlement.Outcome, stake, odds float64) float64 {
+	switch o {
+	case settlement.Win:
+		return stake * (odds - 1)
+	case settlement.HalfWin:
+		return stake * (odds - 1) / 2
+	case settlement.Lose:
+		return -stake
+	case settlement.HalfLose:
+		return -stake / 2
+	case settlement.Push, settlement.Void:
+		return 0
+	default:
+		return 0
+	}
+}
+
+// settlementConfig returns the market configuration that derives the lines of the given bets.
+// Handicap and total lines that are not generated by default are quoted explicitly.
+func settlementConfig(bets []Bet, open []int) (format.MarketConfig, error) {
+	cfg := format.MarketConfig{
+		BestOf: bets[open[0]].BestOf,
+		Lines:  format.LineOptions{Type: format.QuarterLines},
+	}
+	for _, i := range open {
+		b := bets[i]
+		if b.BestOf != cfg.BestOf {
+			return cfg, fmt.Errorf("bets on match %q disagree on the number of sets", b.Match)
+		}
+		cfg.Lines.AddLine(b.Market, b.Line)
+	}
+	return cfg, nil
+}
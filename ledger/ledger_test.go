@@ -0,0 +1,138 @@
+package ledger
+
+import (
+	"gotennis/format"
+	"gotennis/settlement"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testBet(market, line string, side format.Side) Bet {
+	return Bet{Match: "m1", BestOf: 3, Market: market, Line: line, Side: side, Odds: 2, Stake: 10}
+}
+
+func TestPlace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.json")
+	l, err := Open(path)
+	require.NoError(t, err)
+
+	first, err := l.Place(testBet("Moneyline", "ml", format.SideA))
+	require.NoError(t, err)
+	second, err := l.Place(testBet("GameOU", "22.5", format.SideB))
+	require.NoError(t, err)
+	assert.Equal(t, 1, first.ID)
+	assert.Equal(t, 2, second.ID)
+	assert.False(t, first.PlacedAt.IsZero(), "Expected the placement time to be set")
+
+	// The bets are kept in the file, not in the ledger.
+	reopened, err := Open(path)
+	require.NoError(t, err)
+	bets, err := reopened.Bets()
+	require.NoError(t, err)
+	assert.Equal(t, []Bet{first, second}, bets)
+
+	for name, mutate := range map[string]func(b *Bet){
+		"Missing match":  func(b *Bet) { b.Match = "" },
+		"Invalid bestof": func(b *Bet) { b.BestOf = 4 },
+		"Invalid side":   func(b *Bet) { b.Side = "C" },
+		"Invalid odds":   func(b *Bet) { b.Odds = 1 },
+		"Invalid stake":  func(b *Bet) { b.Stake = 0 },
+	} {
+		b := testBet("Moneyline", "ml", format.SideA)
+		mutate(&b)
+		_, err := l.Place(b)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestSetClosingOdds(t *testing.T) {
+	l, err := Open(filepath.Join(t.TempDir(), "ledger.json"))
+	require.NoError(t, err)
+	b, err := l.Place(testBet("Moneyline", "ml", format.SideA))
+	require.NoError(t, err)
+
+	b, err = l.SetClosingOdds(b.ID, 1.8)
+	require.NoError(t, err)
+	assert.InDelta(t, 1.8, b.ClosingOdds, 1e-9)
+
+	_, err = l.SetClosingOdds(b.ID, 0.5)
+	assert.Error(t, err, "Expected an error for invalid closing odds")
+	_, err = l.SetClosingOdds(99, 1.8)
+	assert.Error(t, err, "Expected an error for an unknown bet")
+}
+
+func TestSettle(t *testing.T) {
+	l, err := Open(filepath.Join(t.TempDir(), "ledger.json"))
+	require.NoError(t, err)
+	for _, b := range []Bet{
+		testBet("Moneyline", "ml", format.SideA),
+		testBet("GameOU", "22.5", format.SideB),
+		// 24.75 is not generated by default and is quoted for the bet.
+		testBet("GameOU", "24.75", format.SideA),
+		testBet("GameHandicaps", "-5", format.SideA),
+		{Match: "m2", BestOf: 3, Market: "Moneyline", Line: "ml", Side: format.SideB, Odds: 2, Stake: 10},
+		// Far from the 15 games of A and quoted for the bet.
+		testBet("PlayerAGameOU", "20.5", format.SideA),
+		// The score does not settle breaks of serve.
+		testBet("Breaks.TotalBreaks", "4.5", format.SideA),
+		// There was no tiebreak in the first set.
+		testBet("Tiebreaks.Winner", "1", format.SideA),
+	} {
+		_, err := l.Place(b)
+		require.NoError(t, err)
+	}
+
+	// 25 games and A wins by 5.
+	score, err := settlement.ParseScore("6-4 3-6 6-0", "")
+	require.NoError(t, err)
+	rules := settlement.Rules{Retirement: format.RetirementVoid}
+	settled, err := l.Settle(format.DefaultRegistry(), "m1", score, rules)
+	require.NoError(t, err)
+	require.Len(t, settled, 6)
+
+	expected := []struct {
+		outcome settlement.Outcome
+		profit  float64
+	}{
+		{settlement.Win, 10},
+		{settlement.Lose, -10},
+		{settlement.HalfWin, 5},
+		{settlement.Push, 0},
+		{settlement.Lose, -10},
+		{settlement.Void, 0},
+	}
+	for i, e := range expected {
+		assert.Equal(t, e.outcome, settled[i].Outcome, "Bet %d", settled[i].ID)
+		assert.InDelta(t, e.profit, settled[i].Profit, 1e-9, "Bet %d", settled[i].ID)
+		assert.NotNil(t, settled[i].SettledAt)
+	}
+
+	bets, err := l.Bets()
+	require.NoError(t, err)
+	assert.Empty(t, bets[4].Outcome, "Expected bets on other matches to stay open")
+	assert.Empty(t, bets[6].Outcome, "Expected bets the score does not settle to stay open")
+	assert.Nil(t, bets[6].SettledAt)
+
+	_, err = l.Settle(format.DefaultRegistry(), "m1", score, rules)
+	assert.Error(t, err, "Expected an error when no open bet is settled")
+}
+
+func TestProfit(t *testing.T) {
+	tests := []struct {
+		outcome  settlement.Outcome
+		expected float64
+	}{
+		{settlement.Win, 15},
+		{settlement.HalfWin, 7.5},
+		{settlement.Lose, -10},
+		{settlement.HalfLose, -5},
+		{settlement.Push, 0},
+		{settlement.Void, 0},
+	}
+	for _, tt := range tests {
+		assert.InDelta(t, tt.expected, Profit(tt.outcome, 10, 2.5), 1e-9, string(tt.outcome))
+	}
+}
@@ -0,0 +1,110 @@
+package ledger
+
+import (
+	"gotennis/format"
+	"gotennis/settlement"
+)
+
+// Summary is the performance of a group of bets.
+type Summary struct {
+	Bets    int `json:"bets"`
+	Settled int `json:"settled"`
+	// Staked is the total stake of the settled bets and Profit their total profit.
+	Staked float64 `json:"staked"`
+	Profit float64 `json:"profit"`
+	// ROI is Profit over Staked.
+	ROI float64 `json:"roi"`
+	// HitRate is the share of settled bets that won, counting half wins and losses as half,
+	// ignoring pushes and voids.
+	HitRate float64 `json:"hitRate"`
+	// CLV is the average closing line value, the odds taken over the closing odds less one, of
+	// the bets with closing odds.
+	CLV float64 `json:"clv"`
+	// ModelEdge is the average model edge, the odds taken over the model odds less one, of the
+	// bets with model odds.
+	ModelEdge float64 `json:"modelEdge"`
+
+	wins, decided      float64
+	clvBets, modelBets int
+}
+
+// Report is the performance of every bet and of the bets on each type of market.
+type Report struct {
+	Total  Summary                   `json:"total"`
+	ByType map[format.Market]Summary `json:"byType"`
+}
+
+// GetReport summarises the bets overall and by market type, e.g. ML, AH and OU.
+func GetReport(bets []Bet) Report {
+	byType := make(map[format.Market]*Summary)
+	var total Summary
+	for _, b := range bets {
+		s, ok := byType[b.Type]
+		if !ok {
+			s = &Summary{}
+			byType[b.Type] = s
+		}
+		s.add(b)
+		total.add(b)
+	}
+
+	out := Report{Total: total.finish(), ByType: make(map[format.Market]Summary, len(byType))}
+	for t, s := range byType {
+		out.ByType[t] = s.finish()
+	}
+	return out
+}
+
+// add counts the bet in the summary.
+func (s *Summary) add(b Bet) {
+	s.Bets++
+	if b.ModelOdds > 0 {
+		s.ModelEdge += b.Odds/b.ModelOdds - 1
+		s.modelBets++
+	}
+	if b.ClosingOdds > 0 {
+		s.CLV += b.Odds/b.ClosingOdds - 1
+		s.clvBets++
+	}
+	if b.Outcome == "" {
+		return
+	}
+
+	s.Settled++
+	s.Profit += b.Profit
+	switch b.Outcome {
+	case settlement.Win:
+		s.Staked += b.Stake
+		s.wins++
+		s.decided++
+	case settlement.HalfWin:
+		s.Staked += b.Stake
+		s.wins += 0.5
+		s.decided += 0.5
+	case settlement.Lose:
+		s.Staked += b.Stake
+		s.decided++
+	case settlement.HalfLose:
+		s.Staked += b.Stake
+		s.decided += 0.5
+	case settlement.Push, settlement.Void:
+	}
+}
+
+// finish turns the sums of the summary into ratios.
+func (s *Summary) finish() Summary {
+	out := *s
+	if out.Staked > 0 {
+		out.ROI = out.Profit / out.Staked
+	}
+	if out.decided > 0 {
+		out.HitRate = out.wins / out.decided
+	}
+	if out.clvBets > 0 {
+		out.CLV /= float64(out.clvBets)
+	}
+	if out.modelBets > 0 {
+		out.ModelEdge /= float64(out.modelBets)
+	}
+	return out
+}
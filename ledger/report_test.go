@@ -0,0 +1,48 @@
+package ledger
+
+import (
+	"gotennis/format"
+	"gotennis/settlement"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetReport(t *testing.T) {
+	bets := []Bet{
+		{
+			Type: format.Moneyline, Odds: 2, Stake: 10, ModelOdds: 1.6, ClosingOdds: 1.6,
+			Outcome: settlement.Win, Profit: 10,
+		},
+		{
+			Type: format.Moneyline, Odds: 2, Stake: 10, ModelOdds: 2.5, ClosingOdds: 2.5,
+			Outcome: settlement.Lose, Profit: -10,
+		},
+		{Type: format.Total, Odds: 1.9, Stake: 20, ModelOdds: 1.9, Outcome: settlement.HalfWin, Profit: 9},
+		{Type: format.Total, Odds: 1.9, Stake: 20, Outcome: settlement.Push},
+		{Type: format.Handicap, Odds: 2, Stake: 10, ModelOdds: 1.9},
+	}
+	report := GetReport(bets)
+
+	total := report.Total
+	assert.Equal(t, 5, total.Bets)
+	assert.Equal(t, 4, total.Settled)
+	assert.InDelta(t, 40, total.Staked, 1e-9, "Expected pushes not to count as staked")
+	assert.InDelta(t, 9, total.Profit, 1e-9)
+	assert.InDelta(t, 9.0/40, total.ROI, 1e-9)
+	assert.InDelta(t, 1.5/2.5, total.HitRate, 1e-9, "Expected half wins to count as half")
+	assert.InDelta(t, (2/1.6-1+2/2.5-1)/2, total.CLV, 1e-9)
+	assert.InDelta(t, (2/1.6-1+2/2.5-1+0+2/1.9-1)/4, total.ModelEdge, 1e-9)
+
+	assert.Len(t, report.ByType, 3)
+	ml := report.ByType[format.Moneyline]
+	assert.Equal(t, 2, ml.Settled)
+	assert.InDelta(t, 0, ml.Profit, 1e-9)
+	assert.InDelta(t, 0.5, ml.HitRate, 1e-9)
+	ou := report.ByType[format.Total]
+	assert.InDelta(t, 20, ou.Staked, 1e-9)
+	assert.InDelta(t, 1, ou.HitRate, 1e-9)
+	ah := report.ByType[format.Handicap]
+	assert.Equal(t, 0, ah.Settled)
+	assert.InDelta(t, 0, ah.ROI, 1e-9)
+}
@@ -0,0 +1,65 @@
+package ledger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// state is the content of the ledger file.
+type state struct {
+	NextID int   `json:"nextId"`
+	Bets   []Bet `json:"bets"`
+}
+
+// FileStore keeps the ledger in a local JSON file. Every save replaces the file atomically, so
+// the ledger is never left half written.
+type FileStore struct {
+	path string
+}
+
+// NewFileStore returns a store writing to the file at path, which is created on the first save.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// load reads the ledger, or an empty ledger when the file does not exist yet.
+func (s *FileStore) load() (state, error) {
+	st := state{NextID: 1}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return st, fmt.Errorf("invalid ledger file %s: %w", s.path, err)
+	}
+	return st, nil
+}
+
+// save writes the ledger to a temporary file next to the ledger and renames it over the ledger.
+func (s *FileStore) save(st state) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
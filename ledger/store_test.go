@@ -0,0 +1,33 @@
+package ledger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	s := NewFileStore(filepath.Join(dir, "ledger.json"))
+
+	st, err := s.load()
+	require.NoError(t, err)
+	assert.Equal(t, state{NextID: 1}, st, "Expected an empty ledger before the first save")
+
+	want := state{NextID: 3, Bets: []Bet{{ID: 1, Match: "m1"}, {ID: 2, Match: "m2"}}}
+	require.NoError(t, s.save(want))
+	st, err = s.load()
+	require.NoError(t, err)
+	assert.Equal(t, want, st)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "Expected no temporary files to be left behind")
+
+	require.NoError(t, os.WriteFile(s.path, []byte("{"), 0o600))
+	_, err = s.load()
+	assert.Error(t, err, "Expected an error for a corrupt ledger file")
+}
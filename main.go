@@ -3,13 +3,18 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"gotennis/format"
 	"gotennis/sim"
+	"gotennis/solver"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -18,9 +23,9 @@ import (
 const maxStats = 1000 // Only keep the last 1000 stats
 
 type Simulation struct {
-	P1               float64          `json:"p1"`
-	P2               float64          `json:"p2"`
-	SimulationResult SimulationResult `json:"simulationResult"`
+	P1               float64        `json:"p1"`
+	P2               float64        `json:"p2"`
+	SimulationResult format.Markets `json:"simulationResult"`
 }
 
 type RequestStat struct {
@@ -74,6 +79,32 @@ func handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	opts, rule, err := parseRetirementOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts.Simulations = simulations
+
+	oddsCfg, withOdds, err := parseOddsConfig(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	lines, err := parseLineOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	markets, err := parseMarkets(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts.RecordGames = markets.NeedsGames()
+
 	startTotal := time.Now()
 	log.Printf(
 		"Received request from %s: p1=%f, p2=%f, bestof=%d, simulations=%d",
@@ -84,7 +115,7 @@ func handler(w http.ResponseWriter, r *http.Request) {
 		simulations,
 	)
 	start := time.Now()
-	sim, err := sim.SimulateMatch(p1, p2, bestof, simulations)
+	sim, err := sim.SimulateMatchWithOptions(p1, p2, bestof, opts)
 	simTime := time.Since(start)
 	stat := RequestStat{
 		Timestamp:      time.Now().Unix(),
@@ -100,22 +131,307 @@ func handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res := deriveProbabilities(sim, bestof)
-	log.Printf(
-		"With p1=%f, p2=%f, bestof=%d - ML probs: %f, %f",
-		p1,
-		p2,
-		bestof,
-		res.Moneyline.ProbA,
-		res.Moneyline.ProbB,
-	)
+	sim, err = format.ApplyRetirementRule(sim, rule)
+	if err == nil && len(sim) == 0 {
+		err = errors.New("no simulated match stands under the retirement rule")
+	}
+	if err != nil {
+		stat.Success = 0
+		stat.Error = 1
+		addRequestStat(stat)
+		http.Error(w, "Internal Server Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res := deriveProbabilities(sim, format.MarketConfig{BestOf: bestof, Lines: lines, P1: p1, P2: p2}, markets)
+	if ml, ok := res["Moneyline"].(format.Probability); ok {
+		log.Printf("With p1=%f, p2=%f, bestof=%d - ML probs: %f, %f", p1, p2, bestof, ml.ProbA, ml.ProbB)
+	}
+	out := res
+	if withOdds {
+		priced, err := registry.Price(res, oddsCfg)
+		if err != nil {
+			stat.Success = 0
+			stat.Error = 1
+			addRequestStat(stat)
+			http.Error(w, "Internal Server Error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		out = priced
+	}
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(res)
+	_ = json.NewEncoder(w).Encode(out)
 	stat.Success = 1
 	stat.Error = 0
 	addRequestStat(stat)
 }
 
+// solveHandler infers the serve probabilities implied by market prices and reprices every market
+// with them. odds1/odds2 are decimal moneyline prices, total an optional total games line with
+// optional decimal over/under prices; without prices the total is taken as the main line.
+func solveHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	odds1, err1 := strconv.ParseFloat(q.Get("odds1"), 64)
+	odds2, err2 := strconv.ParseFloat(q.Get("odds2"), 64)
+	bestof, err3 := strconv.Atoi(q.Get("bestof"))
+	if err1 != nil || err2 != nil || err3 != nil {
+		http.Error(w, "invalid query parameters: parse error", http.StatusBadRequest)
+		return
+	}
+	fair, err := format.Devig([]float64{odds1, odds2}, format.DevigMultiplicative)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	target := solver.Target{BestOf: bestof, MoneylineA: fair[0]}
+	if totalStr := q.Get("total"); totalStr != "" {
+		total, err := strconv.ParseFloat(totalStr, 64)
+		if err != nil || total <= 0 {
+			http.Error(w, "invalid total value: must be a positive number", http.StatusBadRequest)
+			return
+		}
+		target.TotalLine = total
+		if q.Get("over") != "" || q.Get("under") != "" {
+			over, errOver := strconv.ParseFloat(q.Get("over"), 64)
+			under, errUnder := strconv.ParseFloat(q.Get("under"), 64)
+			if errOver != nil || errUnder != nil {
+				http.Error(w, "over and under must both be odds greater than 1", http.StatusBadRequest)
+				return
+			}
+			fair, err := format.Devig([]float64{over, under}, format.DevigMultiplicative)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			target.TotalOver = fair[0]
+		}
+	}
+
+	simulations := 1000000
+	if tmp, err := strconv.Atoi(q.Get("simulations")); err == nil && tmp > 0 {
+		simulations = tmp
+	}
+
+	markets, err := parseMarkets(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	solved, err := solver.Solve(target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf(
+		"Solved bestof=%d, ML=%f, total=%.1f: p1=%f, p2=%f",
+		bestof,
+		target.MoneylineA,
+		target.TotalLine,
+		solved.P1,
+		solved.P2,
+	)
+
+	matches, err := sim.SimulateMatchWithOptions(
+		solved.P1,
+		solved.P2,
+		bestof,
+		sim.Options{Simulations: simulations, RecordGames: markets.NeedsGames()},
+	)
+	if err != nil {
+		http.Error(w, "Internal Server Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res := deriveProbabilities(matches, format.MarketConfig{
+		BestOf: bestof,
+		Lines:  format.LineOptions{Type: format.HalfLines},
+		P1:     solved.P1,
+		P2:     solved.P2,
+	}, markets)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(Simulation{
+		P1:               solved.P1,
+		P2:               solved.P2,
+		SimulationResult: res,
+	})
+}
+
+// parlayHandler prices a same match parlay. legs is a comma separated list of legs such as
+// legs=ML:A,OU:over:22.5,SW:1:A, see format.Leg. Every leg is settled on the same simulated
+// matches, so the joint probability reflects how the legs depend on each other.
+func parlayHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	p1, err1 := strconv.ParseFloat(q.Get("p1"), 64)
+	p2, err2 := strconv.ParseFloat(q.Get("p2"), 64)
+	bestof, err3 := strconv.Atoi(q.Get("bestof"))
+	if err := validateInputs(p1, p2, bestof, err1, err2, err3); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var legStrs []string
+	if v := q.Get("legs"); v != "" {
+		legStrs = strings.Split(v, ",")
+	}
+	legs, err := format.ParseLegs(legStrs)
+	if err != nil {
+		http.Error(w, "invalid legs value: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opts, rule, err := parseRetirementOptions(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts.Simulations = 1000000
+	if tmp, err := strconv.Atoi(q.Get("simulations")); err == nil && tmp > 0 {
+		opts.Simulations = tmp
+	}
+
+	oddsCfg, withOdds, err := parseOddsConfig(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	matches, err := sim.SimulateMatchWithOptions(p1, p2, bestof, opts)
+	if err == nil {
+		matches, err = format.ApplyRetirementRule(matches, rule)
+	}
+	if err == nil && len(matches) == 0 {
+		err = errors.New("no simulated match stands under the retirement rule")
+	}
+	if err != nil {
+		http.Error(w, "Internal Server Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	parlay := format.GetParlay(matches, legs)
+	if withOdds {
+		parlay, err = parlay.Price(oddsCfg)
+		if err != nil {
+			http.Error(w, "Internal Server Error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(parlay)
+}
+
+// valueHandler compares offered prices with the model. offers is a comma separated list of
+// <market>:<line>:<side>:<odds>, e.g. offers=Moneyline:ml:A:1.55,GameOU:22.5:B:1.95, bankroll
+// the bankroll stakes are sized for, kelly the Kelly fraction (default 1) and caps optional stake
+// caps per market such as caps=Moneyline:100.
+func valueHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	p1, err1 := strconv.ParseFloat(q.Get("p1"), 64)
+	p2, err2 := strconv.ParseFloat(q.Get("p2"), 64)
+	bestof, err3 := strconv.Atoi(q.Get("bestof"))
+	if err := validateInputs(p1, p2, bestof, err1, err2, err3); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	offers, stakeCfg, err := parseValueOptions(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opts, rule, err := parseRetirementOptions(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts.Simulations = 1000000
+	if tmp, err := strconv.Atoi(q.Get("simulations")); err == nil && tmp > 0 {
+		opts.Simulations = tmp
+	}
+	offered := make([]string, 0, len(offers))
+	for _, o := range offers {
+		offered = append(offered, o.Market)
+	}
+	sel, err := registry.SelectMarkets(offered)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts.RecordGames = sel.NeedsGames()
+
+	lines, err := parseLineOptions(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	matches, err := sim.SimulateMatchWithOptions(p1, p2, bestof, opts)
+	if err == nil {
+		matches, err = format.ApplyRetirementRule(matches, rule)
+	}
+	if err == nil && len(matches) == 0 {
+		err = errors.New("no simulated match stands under the retirement rule")
+	}
+	if err != nil {
+		http.Error(w, "Internal Server Error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	markets := deriveProbabilities(matches, format.MarketConfig{BestOf: bestof, Lines: lines, P1: p1, P2: p2}, sel)
+	values, err := format.GetValues(registry.Probabilities(markets), offers, stakeCfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(values)
+}
+
+// devigHandler removes the bookmaker margin from the odds of a two-way market. market is one of
+// ML, AH or OU, line the handicap or total line, oddsA/oddsB the decimal odds of player A (or
+// over) and player B (or under), and method the devig method (default multiplicative).
+func devigHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	oddsA, err1 := strconv.ParseFloat(q.Get("oddsA"), 64)
+	oddsB, err2 := strconv.ParseFloat(q.Get("oddsB"), 64)
+	if err1 != nil || err2 != nil {
+		http.Error(w, "invalid query parameters: parse error", http.StatusBadRequest)
+		return
+	}
+
+	market := format.Market(q.Get("market"))
+	line := "ml"
+	switch market {
+	case format.Moneyline:
+	case format.Handicap, format.Total:
+		v, err := strconv.ParseFloat(q.Get("line"), 64)
+		if err != nil {
+			http.Error(w, "invalid line value: must be a number", http.StatusBadRequest)
+			return
+		}
+		line = format.FormatLine(v)
+	default:
+		http.Error(w, "invalid market value: must be ML, AH or OU", http.StatusBadRequest)
+		return
+	}
+
+	method := format.DevigMultiplicative
+	if methodStr := q.Get("method"); methodStr != "" {
+		method = format.DevigMethod(methodStr)
+	}
+
+	prob, err := format.DevigProbability(market, line, oddsA, oddsB, method)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(prob)
+}
+
 type StatsSummary struct {
 	TotalRequests     int     `json:"total_requests"`
 	SuccessCount      int     `json:"success_count"`
@@ -164,6 +480,13 @@ func statsHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
+	if ok, err := runCommand(os.Args[1:], os.Stdout); ok {
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	port := os.Getenv("GOTENNIS_PORT")
 	if port == "" {
 		port = "8000"
@@ -172,6 +495,18 @@ func main() {
 
 	http.HandleFunc("/", handler)
 	http.HandleFunc("/stats", statsHandler)
+	http.HandleFunc("/solve", solveHandler)
+	http.HandleFunc("/devig", devigHandler)
+	http.HandleFunc("/parlay", parlayHandler)
+	http.HandleFunc("/value", valueHandler)
+	http.HandleFunc("/risk", riskHandler)
+	http.HandleFunc("/greeks", greeksHandler)
+
+	bets := &lazyLedger{path: ledgerPath()}
+	http.HandleFunc("/bets", bets.handle(betsHandler))
+	http.HandleFunc("/bets/settle", bets.handle(settleBetsHandler))
+	http.HandleFunc("/bets/closing", bets.handle(closingOddsHandler))
+	http.HandleFunc("/bets/report", bets.handle(reportHandler))
 
 	srv := &http.Server{
 		Addr:        addr,
@@ -194,24 +529,213 @@ func main() {
 	}
 }
 
-type SimulationResult struct {
-	Moneyline     format.Probability   `json:"Moneyline"`
-	SetHandicaps  []format.Probability `json:"SetHandicaps"`
-	GameHandicaps []format.Probability `json:"GameHandicaps"`
-	SetOU         []format.Probability `json:"SetOU"`
-	GameOU        []format.Probability `json:"GameOU"`
+// registry holds the market families the simulation endpoints can return.
+var registry = format.DefaultRegistry()
+
+// deriveProbabilities derives the selected market families from the simulated matches.
+func deriveProbabilities(match []sim.SimulatedMatch, cfg format.MarketConfig, sel format.Selection) format.Markets {
+	return registry.Derive(match, cfg, sel)
+}
+
+// parseMarkets reads the optional markets parameter, a comma separated list of the market
+// families to return such as markets=ML,SetOU,CorrectScore. Every family is returned without it.
+func parseMarkets(q url.Values) (format.Selection, error) {
+	var names []string
+	if v := q.Get("markets"); v != "" {
+		names = strings.Split(v, ",")
+	}
+	sel, err := registry.Select(names)
+	if err != nil {
+		return sel, fmt.Errorf("invalid markets value: %w", err)
+	}
+	return sel, nil
+}
+
+// parseRetirementOptions reads the optional retirement and walkover parameters of a request.
+// retire1/retire2 are per-game retirement hazards, walkover1/walkover2 pre-match withdrawal
+// probabilities and retirement the settlement rule for unfinished matches (default void).
+func parseRetirementOptions(q url.Values) (sim.Options, format.RetirementRule, error) {
+	var opts sim.Options
+	params := []struct {
+		name string
+		dst  *float64
+	}{
+		{"retire1", &opts.RetireA},
+		{"retire2", &opts.RetireB},
+		{"walkover1", &opts.WalkoverA},
+		{"walkover2", &opts.WalkoverB},
+	}
+	for _, p := range params {
+		str := q.Get(p.name)
+		if str == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return opts, "", errors.New("invalid query parameters: parse error")
+		}
+		if v < 0 || v > 1 {
+			return opts, "", errors.New("retirement and walkover probabilities must be between 0 and 1")
+		}
+		*p.dst = v
+	}
+
+	rule := format.RetirementVoid
+	if str := q.Get("retirement"); str != "" {
+		rule = format.RetirementRule(str)
+		if rule != format.RetirementVoid && rule != format.RetirementSettle {
+			return opts, "", errors.New("invalid retirement value: must be void or settle")
+		}
+	}
+	return opts, rule, nil
+}
+
+// parseLineOptions reads the optional line parameters of a request: lines selects the generated
+// game handicap and total lines, half (default), whole or quarter, while gameHandicaps, gameTotals
+// and setHandicaps list exact lines to quote instead, e.g. gameTotals=21.5,22.5,23.5.
+func parseLineOptions(q url.Values) (format.LineOptions, error) {
+	opts := format.LineOptions{Type: format.HalfLines}
+	if linesStr := q.Get("lines"); linesStr != "" {
+		opts.Type = format.LineType(linesStr)
+		switch opts.Type {
+		case format.HalfLines, format.WholeLines, format.QuarterLines:
+		default:
+			return opts, errors.New("invalid lines value: must be half, whole or quarter")
+		}
+	}
+
+	for _, p := range []struct {
+		name  string
+		lines *[]float64
+	}{
+		{"gameHandicaps", &opts.GameHandicaps},
+		{"gameTotals", &opts.GameTotals},
+		{"setHandicaps", &opts.SetHandicaps},
+	} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		lines, err := format.ParseLines(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid %s value: %w", p.name, err)
+		}
+		*p.lines = lines
+	}
+
+	if smoothStr := q.Get("smooth"); smoothStr != "" {
+		smooth, err := strconv.ParseBool(smoothStr)
+		if err != nil {
+			return opts, errors.New("invalid smooth value: must be true or false")
+		}
+		opts.Smooth = smooth
+	}
+	return opts, nil
+}
+
+// parseValueOptions reads the offers and staking parameters of a value request. offers is
+// required, bankroll defaults to 0, which only reports the Kelly fractions, and kelly to 1.
+func parseValueOptions(q url.Values) ([]format.Offer, format.StakeConfig, error) {
+	cfg := format.StakeConfig{KellyFraction: 1}
+	offersStr := q.Get("offers")
+	if offersStr == "" {
+		return nil, cfg, errors.New("invalid offers value: at least one offer is required")
+	}
+	var offers []format.Offer
+	for _, s := range strings.Split(offersStr, ",") {
+		o, err := format.ParseOffer(s)
+		if err != nil {
+			return nil, cfg, fmt.Errorf("invalid offers value: %w", err)
+		}
+		offers = append(offers, o)
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  *float64
+	}{
+		{"bankroll", &cfg.Bankroll},
+		{"kelly", &cfg.KellyFraction},
+	} {
+		str := q.Get(p.name)
+		if str == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(str, 64)
+		if err != nil || math.IsNaN(v) {
+			return nil, cfg, fmt.Errorf("invalid %s value: must be a number", p.name)
+		}
+		*p.dst = v
+	}
+	if cfg.Bankroll < 0 || math.IsInf(cfg.Bankroll, 0) {
+		return nil, cfg, errors.New("invalid bankroll value: must not be negative")
+	}
+	if cfg.KellyFraction < 0 || cfg.KellyFraction > 1 {
+		return nil, cfg, errors.New("invalid kelly value: must be between 0 and 1")
+	}
+
+	if capsStr := q.Get("caps"); capsStr != "" {
+		caps, err := format.ParseCaps(capsStr)
+		if err != nil {
+			return nil, cfg, fmt.Errorf("invalid caps value: %w", err)
+		}
+		cfg.Caps = caps
+	}
+	return offers, cfg, nil
 }
 
-func deriveProbabilities(match []sim.SimulatedMatch, bestof int) SimulationResult {
-	var result SimulationResult
+// parseOddsConfig reads the optional odds parameters of a request. The returned bool is false
+// when no odds format is requested, in which case fair probabilities are returned.
+func parseOddsConfig(q url.Values) (format.OddsConfig, bool, error) {
+	oddsStr := q.Get("odds")
+	if oddsStr == "" {
+		return format.OddsConfig{}, false, nil
+	}
+
+	cfg := format.OddsConfig{
+		Format: format.OddsFormat(oddsStr),
+		Method: format.Proportional,
+	}
+	switch cfg.Format {
+	case format.Decimal, format.American, format.Fractional, format.HongKong:
+	default:
+		return cfg, false, errors.New("invalid odds value: must be decimal, american, fractional or hongkong")
+	}
+
+	if marginStr := q.Get("margin"); marginStr != "" {
+		margin, err := strconv.ParseFloat(marginStr, 64)
+		if err != nil || margin < 0 || margin >= 1 {
+			return cfg, false, errors.New("invalid margin value: must be between 0 and 1")
+		}
+		cfg.Margin = margin
+	}
 
-	result.Moneyline = format.GetMoneyline(match)
-	result.SetHandicaps = format.GetSetHandicaps(match, bestof)
-	result.GameHandicaps = format.GetGameHandicaps(match, bestof)
-	result.SetOU = format.GetSetTotals(match, bestof)
-	result.GameOU = format.GetGameTotals(match, bestof)
+	if methodStr := q.Get("marginMethod"); methodStr != "" {
+		cfg.Method = format.MarginMethod(methodStr)
+		switch cfg.Method {
+		case format.Proportional, format.Power, format.FavouriteLongshot:
+		default:
+			return cfg, false, errors.New(
+				"invalid marginMethod value: must be proportional, power or favourite-longshot",
+			)
+		}
+	}
 
-	return result
+	if ladderStr := q.Get("ladder"); ladderStr != "" {
+		ladder, err := format.ParseLadder(ladderStr)
+		if err != nil {
+			return cfg, false, fmt.Errorf("invalid ladder value: %w", err)
+		}
+		cfg.Ladder = ladder
+		cfg.Rounding = format.RoundHouse
+		if roundingStr := q.Get("rounding"); roundingStr != "" {
+			cfg.Rounding = format.Rounding(roundingStr)
+		}
+		if cfg.Rounding != format.RoundHouse && cfg.Rounding != format.RoundNearest {
+			return cfg, false, errors.New("invalid rounding value: must be house or nearest")
+		}
+	}
+	return cfg, true, nil
 }
 
 func validateInputs(p1, p2 float64, bestof int, err1, err2, err3 error) error {
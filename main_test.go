@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"gotennis/format"
 	"gotennis/sim"
+	"maps"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strconv"
 	"testing"
 
@@ -14,6 +16,49 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// simulationResult is the typed view of a response with every market family.
+type simulationResult struct {
+	Moneyline      format.Probability         `json:"Moneyline"`
+	SetHandicaps   []format.Probability       `json:"SetHandicaps"`
+	GameHandicaps  []format.Probability       `json:"GameHandicaps"`
+	SetOU          []format.Probability       `json:"SetOU"`
+	GameOU         []format.Probability       `json:"GameOU"`
+	GameOddEven    format.Probability         `json:"GameOddEven"`
+	GameTotalPMF   []format.Probability       `json:"GameTotalPMF"`
+	GameTotalBands []format.Probability       `json:"GameTotalBands"`
+	CorrectScore   []format.Probability       `json:"CorrectScore"`
+	Sets           []format.SetMarkets        `json:"Sets"`
+	PlayerAGameOU  []format.Probability       `json:"PlayerAGameOU"`
+	PlayerBGameOU  []format.Probability       `json:"PlayerBGameOU"`
+	Tiebreaks      format.TiebreakMarkets     `json:"Tiebreaks"`
+	Combos         format.ComboMarkets        `json:"Combos"`
+	Breaks         format.BreakMarkets        `json:"Breaks"`
+	HoldA          float64                    `json:"HoldA"`
+	HoldB          float64                    `json:"HoldB"`
+	MainLines      map[string]format.MainLine `json:"MainLines"`
+	Distributions  format.Distributions       `json:"Distributions"`
+}
+
+// pricedResult is the typed view of a response with odds.
+type pricedResult struct {
+	Moneyline     format.Price              `json:"Moneyline"`
+	SetHandicaps  []format.Price            `json:"SetHandicaps"`
+	GameHandicaps []format.Price            `json:"GameHandicaps"`
+	SetOU         []format.Price            `json:"SetOU"`
+	GameOU        []format.Price            `json:"GameOU"`
+	Sets          []format.PricedSetMarkets `json:"Sets"`
+}
+
+// decodeMarkets converts derived markets to a typed view through their JSON encoding.
+func decodeMarkets[T any](t *testing.T, markets any) T {
+	t.Helper()
+	var out T
+	data, err := json.Marshal(markets)
+	require.NoError(t, err, "Failed to marshal markets")
+	require.NoError(t, json.Unmarshal(data, &out), "Failed to unmarshal markets")
+	return out
+}
+
 func TestHandler(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -159,7 +204,7 @@ func validateSimulationResponse(t *testing.T, result Simulation) {
 		panic(fmt.Sprintf("P2 should be <= 1, got %f", result.P2))
 	}
 
-	sr := result.SimulationResult
+	sr := decodeMarkets[simulationResult](t, result.SimulationResult)
 	if sr.Moneyline.Market != format.Moneyline {
 		panic(fmt.Sprintf("Expected moneyline market to be %s, got %s", format.Moneyline, sr.Moneyline.Market))
 	}
@@ -199,6 +244,12 @@ func validateSimulationResponse(t *testing.T, result Simulation) {
 		}
 		validateProbability(t, fmt.Sprintf("GameOU[%d]", i), gt)
 	}
+	for i, cs := range sr.CorrectScore {
+		if cs.Market != format.CorrectScore {
+			panic(fmt.Sprintf("CorrectScore[%d] should have market %s, got %s", i, format.CorrectScore, cs.Market))
+		}
+		validateProbability(t, fmt.Sprintf("CorrectScore[%d]", i), cs)
+	}
 }
 
 func validateProbability(t *testing.T, name string, prob format.Probability) {
@@ -217,6 +268,634 @@ func validateProbability(t *testing.T, name string, prob format.Probability) {
 	)
 }
 
+func TestParseRetirementOptions(t *testing.T) {
+	tests := []struct {
+		name         string
+		queryParams  string
+		expectedOpts sim.Options
+		expectedRule format.RetirementRule
+		expectError  bool
+	}{
+		{
+			name:         "Defaults",
+			queryParams:  "",
+			expectedRule: format.RetirementVoid,
+		},
+		{
+			name:         "All parameters",
+			queryParams:  "retire1=0.01&retire2=0.002&walkover1=0.05&walkover2=0.1&retirement=settle",
+			expectedOpts: sim.Options{RetireA: 0.01, RetireB: 0.002, WalkoverA: 0.05, WalkoverB: 0.1},
+			expectedRule: format.RetirementSettle,
+		},
+		{
+			name:        "Invalid hazard format",
+			queryParams: "retire1=abc",
+			expectError: true,
+		},
+		{
+			name:        "Hazard out of range",
+			queryParams: "retire2=1.5",
+			expectError: true,
+		},
+		{
+			name:        "Unknown rule",
+			queryParams: "retirement=refund",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/?"+tt.queryParams, nil)
+			opts, rule, err := parseRetirementOptions(req.URL.Query())
+			if tt.expectError {
+				assert.Error(t, err, "Expected error for %s", tt.queryParams)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedOpts, opts, "Options mismatch")
+			assert.Equal(t, tt.expectedRule, rule, "Rule mismatch")
+		})
+	}
+}
+
+func TestHandlerRetirement(t *testing.T) {
+	tests := []struct {
+		name           string
+		queryParams    string
+		expectedStatus int
+	}{
+		{
+			name:           "Settle retirements",
+			queryParams:    "p1=0.6&p2=0.55&bestof=3&simulations=2000&retire1=0.01&retirement=settle",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Void retirements",
+			queryParams:    "p1=0.6&p2=0.55&bestof=3&simulations=2000&retire2=0.01&walkover1=0.1",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Invalid hazard",
+			queryParams:    "p1=0.6&p2=0.55&bestof=3&simulations=2000&retire1=2",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Every match voided",
+			queryParams:    "p1=0.6&p2=0.55&bestof=3&simulations=100&walkover2=1",
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/?"+tt.queryParams, nil)
+			w := httptest.NewRecorder()
+			handler(w, req)
+			assert.Equal(t, tt.expectedStatus, w.Code, "Unexpected status: %s", w.Body.String())
+			if tt.expectedStatus == http.StatusOK {
+				var result simulationResult
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result), "Failed to parse JSON response")
+				validateProbability(t, "Moneyline", result.Moneyline)
+			}
+		})
+	}
+}
+
+func TestParseOddsConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		queryParams  string
+		expectedCfg  format.OddsConfig
+		expectedOdds bool
+		expectError  bool
+	}{
+		{
+			name:        "No odds requested",
+			queryParams: "margin=0.05",
+		},
+		{
+			name:         "Default margin and method",
+			queryParams:  "odds=decimal",
+			expectedCfg:  format.OddsConfig{Format: format.Decimal, Method: format.Proportional},
+			expectedOdds: true,
+		},
+		{
+			name:         "Full configuration",
+			queryParams:  "odds=american&margin=0.06&marginMethod=power",
+			expectedCfg:  format.OddsConfig{Format: format.American, Margin: 0.06, Method: format.Power},
+			expectedOdds: true,
+		},
+		{
+			name:        "Unknown format",
+			queryParams: "odds=malay",
+			expectError: true,
+		},
+		{
+			name:        "Invalid margin",
+			queryParams: "odds=decimal&margin=1.5",
+			expectError: true,
+		},
+		{
+			name:        "Unknown method",
+			queryParams: "odds=decimal&marginMethod=flat",
+			expectError: true,
+		},
+		{
+			name:        "Ladder rounded toward the house by default",
+			queryParams: "odds=decimal&ladder=2:0.5,3:1",
+			expectedCfg: format.OddsConfig{
+				Format:   format.Decimal,
+				Method:   format.Proportional,
+				Ladder:   format.Ladder{1.5, 2, 3},
+				Rounding: format.RoundHouse,
+			},
+			expectedOdds: true,
+		},
+		{
+			name:        "Betfair ladder rounded to nearest",
+			queryParams: "odds=decimal&ladder=betfair&rounding=nearest",
+			expectedCfg: format.OddsConfig{
+				Format:   format.Decimal,
+				Method:   format.Proportional,
+				Ladder:   format.BetfairLadder(),
+				Rounding: format.RoundNearest,
+			},
+			expectedOdds: true,
+		},
+		{
+			name:        "Invalid ladder",
+			queryParams: "odds=decimal&ladder=exchange",
+			expectError: true,
+		},
+		{
+			name:        "Ladder band without a step",
+			queryParams: "odds=decimal&ladder=1.5:1",
+			expectError: true,
+		},
+		{
+			name:        "Unknown rounding",
+			queryParams: "odds=decimal&ladder=betfair&rounding=up",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/?"+tt.queryParams, nil)
+			cfg, withOdds, err := parseOddsConfig(req.URL.Query())
+			if tt.expectError {
+				assert.Error(t, err, "Expected error for %s", tt.queryParams)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedOdds, withOdds, "Odds requested mismatch")
+			assert.Equal(t, tt.expectedCfg, cfg, "Config mismatch")
+		})
+	}
+}
+
+func TestParseLineOptions(t *testing.T) {
+	tests := []struct {
+		queryParams string
+		expected    format.LineOptions
+		expectError bool
+	}{
+		{"", format.LineOptions{Type: format.HalfLines}, false},
+		{"lines=half", format.LineOptions{Type: format.HalfLines}, false},
+		{"lines=whole", format.LineOptions{Type: format.WholeLines}, false},
+		{"lines=quarter", format.LineOptions{Type: format.QuarterLines}, false},
+		{"lines=eighth", format.LineOptions{}, true},
+		{
+			"gameTotals=21.5,22.5,23.5&gameHandicaps=-3.5,-4.5",
+			format.LineOptions{
+				Type:          format.HalfLines,
+				GameTotals:    []float64{21.5, 22.5, 23.5},
+				GameHandicaps: []float64{-3.5, -4.5},
+			},
+			false,
+		},
+		{
+			"lines=quarter&setHandicaps=-1.5,1",
+			format.LineOptions{Type: format.QuarterLines, SetHandicaps: []float64{-1.5, 1}},
+			false,
+		},
+		{
+			"lines=whole&smooth=true",
+			format.LineOptions{Type: format.WholeLines, Smooth: true},
+			false,
+		},
+		{"smooth=maybe", format.LineOptions{}, true},
+		{"gameTotals=22.3", format.LineOptions{}, true},
+		{"gameHandicaps=-3.5,", format.LineOptions{}, true},
+		{"setHandicaps=one", format.LineOptions{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.queryParams, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/?"+tt.queryParams, nil)
+			lines, err := parseLineOptions(req.URL.Query())
+			if tt.expectError {
+				assert.Error(t, err, "Expected error for %s", tt.queryParams)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, lines)
+		})
+	}
+}
+
+func TestHandlerLines(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/?p1=0.6&p2=0.55&bestof=3&simulations=2000&lines=quarter", nil)
+	w := httptest.NewRecorder()
+	handler(w, req)
+	require.Equal(t, http.StatusOK, w.Code, "Unexpected status: %s", w.Body.String())
+
+	var result simulationResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result), "Failed to parse JSON response")
+	assert.Len(t, result.GameHandicaps, int(8*format.BO3_GAME_SPREAD+1), "Expected quarter handicap lines")
+	lines := make(map[string]format.Probability)
+	for _, p := range result.GameOU {
+		lines[p.Line] = p
+	}
+	require.Contains(t, lines, "22.0")
+	require.Contains(t, lines, "22.75")
+	assert.Positive(t, lines["22.0"].Push, "Whole line should push")
+	assert.InDelta(t, 1.0, lines["22.75"].ProbA+lines["22.75"].ProbB+lines["22.75"].Push, 1e-9)
+
+	req = httptest.NewRequest(
+		http.MethodGet,
+		"/?p1=0.6&p2=0.55&bestof=3&simulations=2000&gameTotals=21.5,22,22.75&gameHandicaps=-3.5&setHandicaps=-1.5",
+		nil,
+	)
+	w = httptest.NewRecorder()
+	handler(w, req)
+	require.Equal(t, http.StatusOK, w.Code, "Unexpected status: %s", w.Body.String())
+	result = simulationResult{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result), "Failed to parse JSON response")
+	require.Len(t, result.GameOU, 3, "Expected only the requested total lines")
+	assert.Equal(t, []string{"21.5", "22.0", "22.75"}, []string{
+		result.GameOU[0].Line,
+		result.GameOU[1].Line,
+		result.GameOU[2].Line,
+	})
+	require.Len(t, result.GameHandicaps, 1, "Expected only the requested handicap line")
+	assert.Equal(t, "-3.5", result.GameHandicaps[0].Line)
+	require.Len(t, result.SetHandicaps, 1, "Expected only the requested set handicap line")
+	assert.Equal(t, "-1.5", result.SetHandicaps[0].Line)
+
+	req = httptest.NewRequest(http.MethodGet, "/?p1=0.6&p2=0.55&bestof=3&lines=eighth", nil)
+	w = httptest.NewRecorder()
+	handler(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Expected bad request for unknown lines")
+}
+
+func TestHandlerMarkets(t *testing.T) {
+	req := httptest.NewRequest(
+		http.MethodGet,
+		"/?p1=0.6&p2=0.55&bestof=3&simulations=2000&markets=ML,SetOU,CorrectScore",
+		nil,
+	)
+	w := httptest.NewRecorder()
+	handler(w, req)
+	require.Equal(t, http.StatusOK, w.Code, "Unexpected status: %s", w.Body.String())
+
+	var result map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result), "Failed to parse JSON response")
+	keys := make([]string, 0, len(result))
+	for key := range result {
+		keys = append(keys, key)
+	}
+	assert.ElementsMatch(t, []string{"Moneyline", "SetOU", "CorrectScore"}, keys, "Expected the requested markets only")
+
+	req = httptest.NewRequest(
+		http.MethodGet,
+		"/?p1=0.6&p2=0.55&bestof=3&simulations=2000&markets=ML,GameOU&odds=decimal",
+		nil,
+	)
+	w = httptest.NewRecorder()
+	handler(w, req)
+	require.Equal(t, http.StatusOK, w.Code, "Unexpected status: %s", w.Body.String())
+	priced := decodeMarkets[pricedResult](t, json.RawMessage(w.Body.Bytes()))
+	assert.Equal(t, format.Decimal, priced.Moneyline.Format, "Expected decimal odds")
+	assert.NotEmpty(t, priced.GameOU, "Expected priced totals")
+	assert.Empty(t, priced.SetOU, "Expected no set totals")
+
+	req = httptest.NewRequest(
+		http.MethodGet,
+		"/?p1=0.6&p2=0.55&bestof=3&simulations=2000&markets=ML&odds=decimal&ladder=2:0.1,1000:1",
+		nil,
+	)
+	w = httptest.NewRecorder()
+	handler(w, req)
+	require.Equal(t, http.StatusOK, w.Code, "Unexpected status: %s", w.Body.String())
+	priced = decodeMarkets[pricedResult](t, json.RawMessage(w.Body.Bytes()))
+	oddsA, err := strconv.ParseFloat(priced.Moneyline.OddsA, 64)
+	require.NoError(t, err)
+	assert.Contains(t, []float64{1.1, 1.2, 1.3, 1.4, 1.5, 1.6, 1.7, 1.8, 1.9, 2}, oddsA, "Expected odds on the ladder")
+	assert.LessOrEqual(t, priced.Moneyline.RoundingA, 0.0, "Expected rounding toward the house")
+
+	req = httptest.NewRequest(http.MethodGet, "/?p1=0.6&p2=0.55&bestof=3&markets=ML,Corners", nil)
+	w = httptest.NewRecorder()
+	handler(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Expected bad request for an unknown market")
+}
+
+func TestHandlerOdds(t *testing.T) {
+	req := httptest.NewRequest(
+		http.MethodGet,
+		"/?p1=0.6&p2=0.55&bestof=3&simulations=2000&odds=fractional&margin=0.05&marginMethod=favourite-longshot",
+		nil,
+	)
+	w := httptest.NewRecorder()
+	handler(w, req)
+	require.Equal(t, http.StatusOK, w.Code, "Unexpected status: %s", w.Body.String())
+
+	var result pricedResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result), "Failed to parse JSON response")
+	assert.Equal(t, format.Fractional, result.Moneyline.Format, "Expected fractional odds")
+	assert.Contains(t, result.Moneyline.OddsA, "/", "Expected fractional notation")
+	assert.NotEmpty(t, result.SetHandicaps, "SetHandicaps should not be empty")
+	assert.NotEmpty(t, result.GameHandicaps, "GameHandicaps should not be empty")
+	assert.NotEmpty(t, result.SetOU, "SetOU should not be empty")
+	assert.NotEmpty(t, result.GameOU, "GameOU should not be empty")
+	require.NotEmpty(t, result.Sets, "Sets should not be empty")
+	assert.Equal(t, 1, result.Sets[0].Set, "Expected first set first")
+	assert.Contains(t, result.Sets[0].Winner.OddsA, "/", "Expected fractional notation for set winner")
+
+	req = httptest.NewRequest(http.MethodGet, "/?p1=0.6&p2=0.55&bestof=3&odds=malay", nil)
+	w = httptest.NewRecorder()
+	handler(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Expected bad request for unknown odds format")
+}
+
+func TestDevigHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		queryParams    string
+		expectedStatus int
+		expectedMarket format.Market
+		expectedLine   string
+	}{
+		{
+			name:           "Moneyline default method",
+			queryParams:    "market=ML&oddsA=1.40&oddsB=3.10",
+			expectedStatus: http.StatusOK,
+			expectedMarket: format.Moneyline,
+			expectedLine:   "ml",
+		},
+		{
+			name:           "Handicap with Shin",
+			queryParams:    "market=AH&line=-3.5&oddsA=1.95&oddsB=1.87&method=shin",
+			expectedStatus: http.StatusOK,
+			expectedMarket: format.Handicap,
+			expectedLine:   "-3.5",
+		},
+		{
+			name:           "Total with power",
+			queryParams:    "market=OU&line=22.5&oddsA=1.80&oddsB=2.02&method=power",
+			expectedStatus: http.StatusOK,
+			expectedMarket: format.Total,
+			expectedLine:   "22.5",
+		},
+		{
+			name:           "Quarter line",
+			queryParams:    "market=OU&line=22.75&oddsA=1.90&oddsB=1.92",
+			expectedStatus: http.StatusOK,
+			expectedMarket: format.Total,
+			expectedLine:   "22.75",
+		},
+		{
+			name:           "Unknown market",
+			queryParams:    "market=CS&oddsA=1.80&oddsB=2.02",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Missing line",
+			queryParams:    "market=OU&oddsA=1.80&oddsB=2.02",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid odds",
+			queryParams:    "market=ML&oddsA=0.8&oddsB=2.02",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unknown method",
+			queryParams:    "market=ML&oddsA=1.8&oddsB=2.02&method=magic",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/devig?"+tt.queryParams, nil)
+			w := httptest.NewRecorder()
+			devigHandler(w, req)
+			assert.Equal(t, tt.expectedStatus, w.Code, "Unexpected status: %s", w.Body.String())
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			var prob format.Probability
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &prob), "Failed to parse JSON response")
+			assert.Equal(t, tt.expectedMarket, prob.Market, "Market mismatch")
+			assert.Equal(t, tt.expectedLine, prob.Line, "Line mismatch")
+			validateProbability(t, "Devig", prob)
+		})
+	}
+}
+
+func TestParlayHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		queryParams    string
+		expectedStatus int
+		expectedLegs   int
+		withOdds       bool
+	}{
+		{
+			name:           "Correlated legs",
+			queryParams:    "p1=0.65&p2=0.6&bestof=3&simulations=2000&legs=ML:A,OU:over:22.5,SW:1:A",
+			expectedStatus: http.StatusOK,
+			expectedLegs:   3,
+		},
+		{
+			name:           "With odds",
+			queryParams:    "p1=0.65&p2=0.6&bestof=5&simulations=2000&legs=ML:B,TB:yes&odds=decimal&margin=0.05",
+			expectedStatus: http.StatusOK,
+			expectedLegs:   2,
+			withOdds:       true,
+		},
+		{
+			name:           "Missing legs",
+			queryParams:    "p1=0.65&p2=0.6&bestof=3",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid leg",
+			queryParams:    "p1=0.65&p2=0.6&bestof=3&legs=OU:over:22",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid bestof",
+			queryParams:    "p1=0.65&p2=0.6&bestof=4&legs=ML:A",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/parlay?"+tt.queryParams, nil)
+			w := httptest.NewRecorder()
+			parlayHandler(w, req)
+			assert.Equal(t, tt.expectedStatus, w.Code, "Unexpected status: %s", w.Body.String())
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			var parlay format.Parlay
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &parlay), "Failed to parse JSON response")
+			require.Len(t, parlay.Legs, tt.expectedLegs)
+			for _, leg := range parlay.Legs {
+				msg := "%s should not be below the parlay"
+				assert.GreaterOrEqual(t, leg.Probability, parlay.Probability, msg, leg.Leg)
+			}
+			assert.Equal(t, tt.withOdds, parlay.Odds != nil, "Odds should only be quoted when requested")
+		})
+	}
+}
+
+func TestValueHandler(t *testing.T) {
+	base := "p1=0.65&p2=0.6&bestof=3&simulations=2000"
+	tests := []struct {
+		name           string
+		queryParams    string
+		expectedStatus int
+		expectedValues int
+	}{
+		{
+			name:           "Moneyline and total",
+			queryParams:    base + "&offers=Moneyline:ml:A:1.9,GameOU:22.5:B:1.95&bankroll=1000&kelly=0.5",
+			expectedStatus: http.StatusOK,
+			expectedValues: 2,
+		},
+		{
+			name:           "Nested market with cap",
+			queryParams:    base + "&offers=Set1.Winner:ml:A:3.0&bankroll=1000&caps=Set1.Winner:10",
+			expectedStatus: http.StatusOK,
+			expectedValues: 1,
+		},
+		{
+			name:           "Caller line",
+			queryParams:    base + "&offers=GameOU:23:A:1.9&gameTotals=23",
+			expectedStatus: http.StatusOK,
+			expectedValues: 1,
+		},
+		{
+			name:           "Missing offers",
+			queryParams:    base,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unknown line",
+			queryParams:    base + "&offers=GameOU:23:A:1.9",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid kelly",
+			queryParams:    base + "&offers=Moneyline:ml:A:1.9&kelly=1.5",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid caps",
+			queryParams:    base + "&offers=Moneyline:ml:A:1.9&caps=Moneyline",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/value?"+tt.queryParams, nil)
+			w := httptest.NewRecorder()
+			valueHandler(w, req)
+			assert.Equal(t, tt.expectedStatus, w.Code, "Unexpected status: %s", w.Body.String())
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			var values []format.Value
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &values), "Failed to parse JSON response")
+			require.Len(t, values, tt.expectedValues)
+			for _, v := range values {
+				assert.GreaterOrEqual(t, v.Stake, 0.0, "Stakes should not be negative")
+				assert.InDelta(t, v.Probability*(v.Odds-1)-(1-v.Probability-v.Push), v.EV, 1e-9)
+			}
+		})
+	}
+}
+
+func TestSolveHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		queryParams    string
+		expectedStatus int
+	}{
+		{
+			name:           "Moneyline only",
+			queryParams:    "odds1=1.40&odds2=3.10&bestof=3&simulations=2000",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Moneyline and total main line",
+			queryParams:    "odds1=1.45&odds2=2.90&bestof=3&total=23.5&simulations=2000",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Moneyline and priced total",
+			queryParams:    "odds1=1.90&odds2=1.90&bestof=3&total=22.5&over=1.60&under=2.40&simulations=2000",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Missing odds",
+			queryParams:    "odds1=1.40&bestof=3",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Odds not above one",
+			queryParams:    "odds1=1.0&odds2=3.10&bestof=3",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Over without under",
+			queryParams:    "odds1=1.40&odds2=3.10&bestof=3&total=22.5&over=1.9",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid bestof",
+			queryParams:    "odds1=1.40&odds2=3.10&bestof=4",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unreachable total",
+			queryParams:    "odds1=1.40&odds2=3.10&bestof=3&total=40.5",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/solve?"+tt.queryParams, nil)
+			w := httptest.NewRecorder()
+			solveHandler(w, req)
+			assert.Equal(t, tt.expectedStatus, w.Code, "Unexpected status: %s", w.Body.String())
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			var result Simulation
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result), "Failed to parse JSON response")
+			validateSimulationResponse(t, result)
+		})
+	}
+}
+
 func TestHandlerHTTPMethods(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -276,7 +955,10 @@ func TestDeriveProbabilities(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := deriveProbabilities(tt.sim, tt.bestof)
+			cfg := format.MarketConfig{BestOf: tt.bestof, Lines: format.LineOptions{Type: format.HalfLines}}
+			all, err := registry.Select(nil)
+			require.NoError(t, err)
+			result := decodeMarkets[simulationResult](t, deriveProbabilities(tt.sim, cfg, all))
 			assert.Equal(
 				t,
 				format.Moneyline,
@@ -289,6 +971,30 @@ func TestDeriveProbabilities(t *testing.T) {
 			assert.NotEmpty(t, result.GameHandicaps, "GameHandicaps should not be empty")
 			assert.NotEmpty(t, result.SetOU, "SetOU should not be empty")
 			assert.NotEmpty(t, result.GameOU, "GameOU should not be empty")
+			assert.Len(t, result.CorrectScore, tt.bestof+1, "Expected %d correct scores", tt.bestof+1)
+			assert.NotEmpty(t, result.Sets, "Sets should not be empty")
+			assert.NotEmpty(t, result.PlayerAGameOU, "PlayerAGameOU should not be empty")
+			assert.NotEmpty(t, result.PlayerBGameOU, "PlayerBGameOU should not be empty")
+			assert.Len(t, result.Tiebreaks.Total, tt.bestof, "Expected %d tiebreak total lines", tt.bestof)
+			validateProbability(t, "Tiebreaks.InMatch", result.Tiebreaks.InMatch)
+			validateProbability(t, "GameOddEven", result.GameOddEven)
+			assert.NotEmpty(t, result.GameTotalPMF, "GameTotalPMF should not be empty")
+			assert.NotEmpty(t, result.GameTotalBands, "GameTotalBands should not be empty")
+			assert.Len(t, result.Combos.DoubleResult, 4, "Expected 4 double results")
+			for i, p := range result.Combos.WinAndTotal {
+				validateProbability(t, fmt.Sprintf("Combos.WinAndTotal[%d]", i), p)
+			}
+			assert.Empty(t, result.Breaks.TotalBreaks, "Breaks need recorded games")
+			require.Contains(t, result.MainLines, "GameOU", "Expected a main game total")
+			assert.Contains(t, result.MainLines, "Set1.GameOU", "Expected a main total for set 1")
+			assert.NotContains(t, result.MainLines, "Breaks.TotalBreaks", "Expected no main line without lines")
+			assert.Len(t, result.Distributions.SetScores, 2, "Expected the two simulated set scores")
+			assert.InDelta(t, 22, result.Distributions.TotalGames.Mean, 0.001, "Expected mean of 29 and 15 games")
+			assert.Len(t, result.Distributions.GameMargin.Quantiles, len(format.QUANTILES))
+			validateProbability(t, "MainLines.GameOU", format.Probability{
+				ProbA: result.MainLines["GameOU"].ProbA,
+				ProbB: result.MainLines["GameOU"].ProbB,
+			})
 			validateProbability(t, "Moneyline", result.Moneyline)
 			for i, sh := range result.SetHandicaps {
 				validateProbability(t, fmt.Sprintf("SetHandicaps[%d]", i), sh)
@@ -302,6 +1008,24 @@ func TestDeriveProbabilities(t *testing.T) {
 			for i, gt := range result.GameOU {
 				validateProbability(t, fmt.Sprintf("GameOU[%d]", i), gt)
 			}
+			for i, cs := range result.CorrectScore {
+				validateProbability(t, fmt.Sprintf("CorrectScore[%d]", i), cs)
+			}
+			for i, p := range result.PlayerAGameOU {
+				validateProbability(t, fmt.Sprintf("PlayerAGameOU[%d]", i), p)
+			}
+			for i, p := range result.PlayerBGameOU {
+				validateProbability(t, fmt.Sprintf("PlayerBGameOU[%d]", i), p)
+			}
+			for i, set := range result.Sets {
+				validateProbability(t, fmt.Sprintf("Sets[%d].Winner", i), set.Winner)
+				for j, p := range set.GameHandicaps {
+					validateProbability(t, fmt.Sprintf("Sets[%d].GameHandicaps[%d]", i, j), p)
+				}
+				for j, p := range set.GameOU {
+					validateProbability(t, fmt.Sprintf("Sets[%d].GameOU[%d]", i, j), p)
+				}
+			}
 		})
 	}
 }
@@ -310,23 +1034,23 @@ func TestSimulationStructJSON(t *testing.T) {
 	original := Simulation{
 		P1: 0.6,
 		P2: 0.55,
-		SimulationResult: SimulationResult{
-			Moneyline: format.Probability{
+		SimulationResult: format.Markets{
+			"Moneyline": format.Probability{
 				Market: format.Moneyline,
 				Line:   "ml",
 				ProbA:  0.6,
 				ProbB:  0.4,
 			},
-			SetHandicaps: []format.Probability{
+			"SetHandicaps": []format.Probability{
 				{Market: format.Handicap, Line: "0.5", ProbA: 0.55, ProbB: 0.45},
 			},
-			GameHandicaps: []format.Probability{
+			"GameHandicaps": []format.Probability{
 				{Market: format.Handicap, Line: "2.5", ProbA: 0.52, ProbB: 0.48},
 			},
-			SetOU: []format.Probability{
+			"SetOU": []format.Probability{
 				{Market: format.Total, Line: "2.5", ProbA: 0.5, ProbB: 0.5},
 			},
-			GameOU: []format.Probability{
+			"GameOU": []format.Probability{
 				{Market: format.Total, Line: "20.5", ProbA: 0.53, ProbB: 0.47},
 			},
 		},
@@ -343,8 +1067,8 @@ func TestSimulationStructJSON(t *testing.T) {
 	assert.Equal(t, original.P2, unmarshaled.P2, "P2 mismatch")
 	assert.Equal(
 		t,
-		original.SimulationResult.Moneyline.ProbA,
-		unmarshaled.SimulationResult.Moneyline.ProbA,
+		original.SimulationResult["Moneyline"].(format.Probability).ProbA,
+		decodeMarkets[simulationResult](t, unmarshaled.SimulationResult).Moneyline.ProbA,
 		"Moneyline ProbA mismatch",
 	)
 
@@ -364,6 +1088,16 @@ func TestSimulationStructJSON(t *testing.T) {
 	}
 }
 
+func TestHandlerDefaultMarkets(t *testing.T) {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/?p1=0.6&p2=0.55&bestof=3&simulations=2000", nil))
+	require.Equal(t, http.StatusOK, w.Code, "Unexpected status: %s", w.Body.String())
+
+	var result map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result), "Failed to parse JSON response")
+	assert.ElementsMatch(t, registry.Names(), slices.Collect(maps.Keys(result)), "Expected every market family")
+}
+
 func TestHandlerIntegration(t *testing.T) {
 	t.Run("Full integration test", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/?p1=0.6&p2=0.55&bestof=3", nil)
@@ -371,19 +1105,26 @@ func TestHandlerIntegration(t *testing.T) {
 		handler(w, req)
 		assert.Equal(t, http.StatusOK, w.Code, "Expected status 200, got %d", w.Code)
 		assert.Equal(t, "application/json", w.Header().Get("Content-Type"), "Expected JSON content type")
-		var result Simulation
+		var result simulationResult
 		err := json.Unmarshal(w.Body.Bytes(), &result)
 		require.NoError(t, err, "Failed to parse JSON response")
+		assert.NotEmpty(t, result.Breaks.TotalBreaks, "Expected break markets")
+		assert.Len(t, result.Breaks.FirstBreakGame, 13, "Expected games 1 to 12 and none")
+		assert.Greater(t, result.HoldA, result.HoldB, "The stronger server should hold more often")
+		mainTotal := result.MainLines["GameOU"]
+		assert.InDelta(t, 0.5, mainTotal.ProbA, 0.1, "Main total should be close to 50/50")
+		assert.InDelta(t, mainTotal.FairLine, 22, 4, "Fair total should be near a typical best of 3 total")
 	})
 }
 
 func TestSimulationResultStructure(t *testing.T) {
-	sr := SimulationResult{}
-	_ = sr.Moneyline
-	_ = sr.SetHandicaps
-	_ = sr.GameHandicaps
-	_ = sr.SetOU
-	_ = sr.GameOU
+	all, err := registry.Select(nil)
+	require.NoError(t, err)
+	sr := deriveProbabilities(
+		[]sim.SimulatedMatch{{ASets: 2, SetResults: []sim.SimulatedSet{{AGames: 6}, {AGames: 6}}}},
+		format.MarketConfig{BestOf: 3, Lines: format.LineOptions{Type: format.HalfLines}, P1: 0.6, P2: 0.6},
+		all,
+	)
 	jsonData, _ := json.Marshal(sr)
 	jsonString := string(jsonData)
 	expectedJSONFields := []string{
@@ -392,6 +1133,20 @@ func TestSimulationResultStructure(t *testing.T) {
 		"GameHandicaps",
 		"SetOU",
 		"GameOU",
+		"CorrectScore",
+		"Sets",
+		"PlayerAGameOU",
+		"PlayerBGameOU",
+		"Tiebreaks",
+		"Combos",
+		"GameOddEven",
+		"GameTotalPMF",
+		"GameTotalBands",
+		"Breaks",
+		"HoldA",
+		"HoldB",
+		"MainLines",
+		"Distributions",
 	}
 	for _, field := range expectedJSONFields {
 		assert.Contains(t, jsonString, field, "JSON should contain field '%s'", field)
@@ -419,9 +1174,12 @@ func BenchmarkDeriveProbabilities(b *testing.B) {
 		},
 	}
 
+	cfg := format.MarketConfig{BestOf: 3, Lines: format.LineOptions{Type: format.HalfLines}}
+	all, _ := registry.Select(nil)
+
 	b.ResetTimer()
 	for range b.N {
-		_ = deriveProbabilities(testSim, 3)
+		_ = deriveProbabilities(testSim, cfg, all)
 	}
 }
 
@@ -436,18 +1194,18 @@ func TestValidateSimulationResponse(t *testing.T) {
 			input: Simulation{
 				P1: 0.6,
 				P2: 0.55,
-				SimulationResult: SimulationResult{
-					Moneyline: format.Probability{Market: format.Moneyline, Line: "ml", ProbA: 0.6, ProbB: 0.4},
-					SetHandicaps: []format.Probability{
+				SimulationResult: format.Markets{
+					"Moneyline": format.Probability{Market: format.Moneyline, Line: "ml", ProbA: 0.6, ProbB: 0.4},
+					"SetHandicaps": []format.Probability{
 						{Market: format.Handicap, Line: "0.5", ProbA: 0.55, ProbB: 0.45},
 					},
-					GameHandicaps: []format.Probability{
+					"GameHandicaps": []format.Probability{
 						{Market: format.Handicap, Line: "2.5", ProbA: 0.52, ProbB: 0.48},
 					},
-					SetOU: []format.Probability{
+					"SetOU": []format.Probability{
 						{Market: format.Total, Line: "2.5", ProbA: 0.5, ProbB: 0.5},
 					},
-					GameOU: []format.Probability{
+					"GameOU": []format.Probability{
 						{Market: format.Total, Line: "20.5", ProbA: 0.53, ProbB: 0.47},
 					},
 				},
@@ -459,16 +1217,16 @@ func TestValidateSimulationResponse(t *testing.T) {
 			input: Simulation{
 				P1: -0.1,
 				P2: 1.2,
-				SimulationResult: SimulationResult{
-					Moneyline: format.Probability{Market: format.Moneyline, Line: "ml", ProbA: 0.6, ProbB: 0.4},
-					SetHandicaps: []format.Probability{
+				SimulationResult: format.Markets{
+					"Moneyline": format.Probability{Market: format.Moneyline, Line: "ml", ProbA: 0.6, ProbB: 0.4},
+					"SetHandicaps": []format.Probability{
 						{Market: format.Handicap, Line: "0.5", ProbA: 0.55, ProbB: 0.45},
 					},
-					GameHandicaps: []format.Probability{
+					"GameHandicaps": []format.Probability{
 						{Market: format.Handicap, Line: "2.5", ProbA: 0.52, ProbB: 0.48},
 					},
-					SetOU:  []format.Probability{{Market: format.Total, Line: "2.5", ProbA: 0.5, ProbB: 0.5}},
-					GameOU: []format.Probability{{Market: format.Total, Line: "20.5", ProbA: 0.53, ProbB: 0.47}},
+					"SetOU":  []format.Probability{{Market: format.Total, Line: "2.5", ProbA: 0.5, ProbB: 0.5}},
+					"GameOU": []format.Probability{{Market: format.Total, Line: "20.5", ProbA: 0.53, ProbB: 0.47}},
 				},
 			},
 			valid: false,
@@ -478,14 +1236,14 @@ func TestValidateSimulationResponse(t *testing.T) {
 			input: Simulation{
 				P1: 0.5,
 				P2: 0.5,
-				SimulationResult: SimulationResult{
-					Moneyline:    format.Probability{Market: format.Moneyline, Line: "ml", ProbA: 0.5, ProbB: 0.5},
-					SetHandicaps: []format.Probability{},
-					GameHandicaps: []format.Probability{
+				SimulationResult: format.Markets{
+					"Moneyline":    format.Probability{Market: format.Moneyline, Line: "ml", ProbA: 0.5, ProbB: 0.5},
+					"SetHandicaps": []format.Probability{},
+					"GameHandicaps": []format.Probability{
 						{Market: format.Handicap, Line: "2.5", ProbA: 0.52, ProbB: 0.48},
 					},
-					SetOU:  []format.Probability{{Market: format.Total, Line: "2.5", ProbA: 0.5, ProbB: 0.5}},
-					GameOU: []format.Probability{{Market: format.Total, Line: "20.5", ProbA: 0.53, ProbB: 0.47}},
+					"SetOU":  []format.Probability{{Market: format.Total, Line: "2.5", ProbA: 0.5, ProbB: 0.5}},
+					"GameOU": []format.Probability{{Market: format.Total, Line: "20.5", ProbA: 0.53, ProbB: 0.47}},
 				},
 			},
 			valid: false,
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gotennis/format"
+	"gotennis/risk"
+	"gotennis/sim"
+	"net/http"
+)
+
+// RiskMatch is a match of the book with the serve probabilities it is simulated with.
+type RiskMatch struct {
+	Match  string  `json:"match"`
+	P1     float64 `json:"p1"`
+	P2     float64 `json:"p2"`
+	BestOf int     `json:"bestof"`
+}
+
+// RiskRequest is the book to measure the risk of.
+type RiskRequest struct {
+	Matches     []RiskMatch      `json:"matches"`
+	Bets        []risk.Bet       `json:"bets"`
+	Simulations int              `json:"simulations"`
+	Shading     risk.ShadeConfig `json:"shading"`
+}
+
+// riskHandler reports the liabilities, profit per final score and price shading of a book of
+// bets, see RiskRequest, for every match.
+func riskHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req RiskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid book: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	reports, err := analyzeBook(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(reports)
+}
+
+// analyzeBook simulates every match of the book and measures its risk.
+func analyzeBook(req RiskRequest) ([]risk.Report, error) {
+	if len(req.Matches) == 0 {
+		return nil, errors.New("at least one match is required")
+	}
+	if req.Simulations <= 0 {
+		req.Simulations = 1000000
+	}
+
+	book := risk.NewBook()
+	for _, bet := range req.Bets {
+		if err := book.Add(bet); err != nil {
+			return nil, fmt.Errorf("bet on %q: %w", bet.Market, err)
+		}
+	}
+	known := make(map[string]bool, len(req.Matches))
+	for _, m := range req.Matches {
+		known[m.Match] = true
+	}
+	for _, match := range book.Matches() {
+		if !known[match] {
+			return nil, fmt.Errorf("bets on unknown match %q", match)
+		}
+	}
+
+	reports := make([]risk.Report, 0, len(req.Matches))
+	for _, m := range req.Matches {
+		if err := validateInputs(m.P1, m.P2, m.BestOf, nil, nil, nil); err != nil {
+			return nil, fmt.Errorf("match %q: %w", m.Match, err)
+		}
+		var markets []string
+		for _, bet := range req.Bets {
+			if bet.Match == m.Match {
+				markets = append(markets, bet.Market)
+			}
+		}
+		results, err := sim.SimulateMatchWithOptions(
+			m.P1,
+			m.P2,
+			m.BestOf,
+			sim.Options{Simulations: req.Simulations, RecordGames: registry.NeedsGames(markets)},
+		)
+		if err != nil {
+			return nil, err
+		}
+		cfg := format.MarketConfig{
+			BestOf: m.BestOf,
+			Lines:  format.LineOptions{Type: format.QuarterLines},
+			P1:     m.P1,
+			P2:     m.P2,
+		}
+		report, err := book.Analyze(registry, m.Match, results, cfg, req.Shading)
+		if err != nil {
+			return nil, fmt.Errorf("match %q: %w", m.Match, err)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
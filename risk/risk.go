@@ -0,0 +1,347 @@
+// Package risk tracks the liabilities of a trading book on every match and measures its profit
+// and loss over the final scores of the simulated match.
+package risk
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"gotennis/format"
+	"gotennis/sim"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Bet is a bet taken by the book: a customer backing one side of a market line.
+type Bet struct {
+	Match string `json:"match"`
+	// Market, Line and Side are as for format.Offer, e.g. "GameOU", "22.5" and "A" for over 22.5.
+	Market string      `json:"market"`
+	Line   string      `json:"line"`
+	Side   format.Side `json:"side"`
+	Odds   float64     `json:"odds"`
+	Stake  float64     `json:"stake"`
+}
+
+// Liability is the position of the book on both sides of a market line, ignoring pushes.
+type Liability struct {
+	Market string  `json:"market"`
+	Line   string  `json:"line"`
+	Bets   int     `json:"bets"`
+	Stake  float64 `json:"stake"`
+	// IfA and IfB are the profit of the book when side A or side B wins.
+	IfA float64 `json:"ifA"`
+	IfB float64 `json:"ifB"`
+}
+
+// Scenario is the profit of the book on a final score of the match.
+type Scenario struct {
+	// Score is the games of every set, e.g. "6-4 3-6 7-6", followed by the retiring player for
+	// retired matches.
+	Score       string  `json:"score"`
+	Probability float64 `json:"probability"`
+	// PnL is the profit of the book. Markets the score does not settle, such as breaks of serve,
+	// count with their expected value over the matches of the score.
+	PnL float64 `json:"pnl"`
+}
+
+// ShadeConfig decides when and how far the price of an exposed side is shaded.
+type ShadeConfig struct {
+	// Threshold is the loss of the book on one side of a market line above which its price is
+	// shaded. Zero disables shading.
+	Threshold float64 `json:"threshold"`
+	// Step is the implied probability added to the side per Threshold of loss, capped at Max
+	// unless Max is zero.
+	Step float64 `json:"step"`
+	Max  float64 `json:"max"`
+}
+
+// Shading is a recommended price for the exposed side of a market line.
+type Shading struct {
+	Market string      `json:"market"`
+	Line   string      `json:"line"`
+	Side   format.Side `json:"side"`
+	// Loss is what the book loses when the side wins.
+	Loss float64 `json:"loss"`
+	// Shade is the implied probability added to the fair price of the side.
+	Shade    float64 `json:"shade"`
+	FairOdds float64 `json:"fairOdds"`
+	Odds     float64 `json:"odds"`
+}
+
+// Report is the risk of the book on a match.
+type Report struct {
+	Match       string      `json:"match"`
+	Liabilities []Liability `json:"liabilities"`
+	// Scenarios are the final scores of the simulated matches, worst first.
+	Scenarios []Scenario `json:"scenarios"`
+	// Expected is the expected profit of the book and Worst its profit on the worst final score.
+	Expected   float64   `json:"expected"`
+	Worst      float64   `json:"worst"`
+	WorstScore string    `json:"worstScore"`
+	Shading    []Shading `json:"shading,omitempty"`
+}
+
+// Book accumulates the bets taken on every match.
+type Book struct {
+	matches []string
+	bets    map[string][]Bet
+}
+
+// NewBook returns an empty Book.
+func NewBook() *Book {
+	return &Book{bets: make(map[string][]Bet)}
+}
+
+// Add records a bet taken by the book.
+func (b *Book) Add(bet Bet) error {
+	if bet.Match == "" || bet.Market == "" || bet.Line == "" {
+		return errors.New("match, market and line are required")
+	}
+	if bet.Side != format.SideA && bet.Side != format.SideB {
+		return errors.New("side must be A or B")
+	}
+	if !(bet.Odds > 1) || math.IsInf(bet.Odds, 0) {
+		return errors.New("odds must be decimal odds greater than 1")
+	}
+	if !(bet.Stake > 0) || math.IsInf(bet.Stake, 0) {
+		return errors.New("stake must be positive")
+	}
+	if _, ok := b.bets[bet.Match]; !ok {
+		b.matches = append(b.matches, bet.Match)
+	}
+	b.bets[bet.Match] = append(b.bets[bet.Match], bet)
+	return nil
+}
+
+// Matches returns the matches with bets in the order of their first bet.
+func (b *Book) Matches() []string {
+	return b.matches
+}
+
+// Liabilities returns the position of the book on every market line of the match it has bets on,
+// in the order of their first bet.
+func (b *Book) Liabilities(match string) []Liability {
+	var out []Liability
+	index := make(map[[2]string]int)
+	for _, bet := range b.bets[match] {
+		k := [2]string{bet.Market, bet.Line}
+		i, ok := index[k]
+		if !ok {
+			i = len(out)
+			index[k] = i
+			out = append(out, Liability{Market: bet.Market, Line: bet.Line})
+		}
+		l := &out[i]
+		l.Bets++
+		l.Stake += bet.Stake
+		if bet.Side == format.SideA {
+			l.IfA -= bet.Stake * (bet.Odds - 1)
+			l.IfB += bet.Stake
+		} else {
+			l.IfA += bet.Stake
+			l.IfB -= bet.Stake * (bet.Odds - 1)
+		}
+	}
+	return out
+}
+
+// Analyze measures the profit of the book on the match over the final scores of the simulated
+// matches and recommends shading the price of every side whose loss crosses the threshold. Bets
+// are settled with the markets of the registry derived from the matches of each final score, so
+// that every market is priced and settled by the same code.
+func (b *Book) Analyze(
+	r *format.Registry,
+	match string,
+	results []sim.SimulatedMatch,
+	cfg format.MarketConfig,
+	shade ShadeConfig,
+) (Report, error) {
+	bets := b.bets[match]
+	if len(bets) == 0 {
+		return Report{}, fmt.Errorf("no bets on match %q", match)
+	}
+	if len(results) == 0 {
+		return Report{}, errors.New("no simulated matches")
+	}
+	if shade.Threshold < 0 || shade.Step < 0 || shade.Max < 0 {
+		return Report{}, errors.New("the shading threshold, step and maximum must not be negative")
+	}
+
+	cfg.Lines = quoteLines(cfg.Lines, bets)
+	offers := make([]format.Offer, 0, len(bets))
+	for _, bet := range bets {
+		offers = append(offers, format.Offer{Market: bet.Market, Line: bet.Line, Side: bet.Side, Odds: bet.Odds})
+	}
+	probs, families, err := deriveFamilies(r, results, cfg, bets)
+	if err != nil {
+		return Report{}, err
+	}
+	sel, err := r.Select(families)
+	if err != nil {
+		return Report{}, err
+	}
+
+	// Unknown markets and lines are errors, while markets and lines missing from a final score,
+	// such as those of sets that were not played, are void.
+	if _, err := format.GetValues(probs, offers, format.StakeConfig{}); err != nil {
+		return Report{}, err
+	}
+
+	out := Report{Match: match, Liabilities: b.Liabilities(match)}
+	for score, group := range groupByScore(results) {
+		s := Scenario{Score: score, Probability: float64(len(group)) / float64(len(results))}
+		s.PnL, err = bookProfit(r.Probabilities(r.Derive(group, cfg, sel)), bets, offers)
+		if err != nil {
+			return Report{}, err
+		}
+		out.Scenarios = append(out.Scenarios, s)
+		out.Expected += s.Probability * s.PnL
+	}
+	slices.SortFunc(out.Scenarios, func(x, y Scenario) int {
+		return cmp.Or(cmp.Compare(x.PnL, y.PnL), strings.Compare(x.Score, y.Score))
+	})
+	out.Worst, out.WorstScore = out.Scenarios[0].PnL, out.Scenarios[0].Score
+
+	out.Shading, err = recommendShading(probs, out.Liabilities, shade)
+	if err != nil {
+		return Report{}, err
+	}
+	return out, nil
+}
+
+// quoteLines adds the lines of the bets to the lines to quote, see format.LineOptions.AddLine.
+func quoteLines(lines format.LineOptions, bets []Bet) format.LineOptions {
+	lines.GameHandicaps = slices.Clone(lines.GameHandicaps)
+	lines.GameTotals = slices.Clone(lines.GameTotals)
+	lines.SetHandicaps = slices.Clone(lines.SetHandicaps)
+	extra := make(map[string][]float64, len(lines.Extra))
+	for market, l := range lines.Extra {
+		extra[market] = slices.Clone(l)
+	}
+	lines.Extra = extra
+	for _, bet := range bets {
+		lines.AddLine(bet.Market, bet.Line)
+	}
+	return lines
+}
+
+// deriveFamilies derives every family of the registry from all the matches and returns their
+// markets together with the names of the families the bets are on.
+func deriveFamilies(
+	r *format.Registry,
+	results []sim.SimulatedMatch,
+	cfg format.MarketConfig,
+	bets []Bet,
+) (map[string][]format.Probability, []string, error) {
+	probs := make(map[string][]format.Probability)
+	var families []string
+	for _, name := range r.Names() {
+		if name == format.MainLinesKey {
+			continue
+		}
+		sel, err := r.Select([]string{name})
+		if err != nil {
+			return nil, nil, err
+		}
+		derived := r.Probabilities(r.Derive(results, cfg, sel))
+		for market, p := range derived {
+			probs[market] = p
+		}
+		if slices.ContainsFunc(bets, func(bet Bet) bool { return derived[bet.Market] != nil }) {
+			families = append(families, name)
+		}
+	}
+	return probs, families, nil
+}
+
+// bookProfit returns the profit of the book on the bets under the probabilities of a final score,
+// which quote the line of every bet as the lines of the bets are quoted, see quoteLines. Bets on
+// markets or lines missing from them, such as those of sets that were not played, are void.
+func bookProfit(probs map[string][]format.Probability, bets []Bet, offers []format.Offer) (float64, error) {
+	var pnl float64
+	for i, o := range offers {
+		if _, ok := format.FindProbability(probs[o.Market], o.Line); !ok {
+			continue
+		}
+		values, err := format.GetValues(probs, offers[i:i+1], format.StakeConfig{})
+		if err != nil {
+			return 0, err
+		}
+		pnl -= bets[i].Stake * values[0].EV
+	}
+	return pnl, nil
+}
+
+// groupByScore groups the matches by their final score.
+func groupByScore(results []sim.SimulatedMatch) map[string][]sim.SimulatedMatch {
+	groups := make(map[string][]sim.SimulatedMatch)
+	for _, m := range results {
+		score := scoreString(m)
+		groups[score] = append(groups[score], m)
+	}
+	return groups
+}
+
+// scoreString writes the games of every set of the match followed by the retiring player.
+func scoreString(m sim.SimulatedMatch) string {
+	sets := make([]string, 0, len(m.SetResults)+1)
+	for _, s := range m.SetResults {
+		sets = append(sets, fmt.Sprintf("%d-%d", s.AGames, s.BGames))
+	}
+	retired := "B"
+	if m.RetiredA {
+		retired = "A"
+	}
+	switch m.Ending {
+	case sim.Retired:
+		sets = append(sets, retired+" retired")
+	case sim.Walkover:
+		sets = append(sets, "walkover by "+retired)
+	case sim.Completed:
+	}
+	return strings.Join(sets, " ")
+}
+
+// recommendShading shades the fair price of every side of a market line the book loses more
+// than the threshold on when it wins, in proportion to the loss.
+func recommendShading(
+	probs map[string][]format.Probability,
+	liabilities []Liability,
+	cfg ShadeConfig,
+) ([]Shading, error) {
+	if cfg.Threshold == 0 {
+		return nil, nil
+	}
+	var out []Shading
+	for _, l := range liabilities {
+		s := Shading{Market: l.Market, Line: l.Line, Side: format.SideA, Loss: -l.IfA}
+		if -l.IfB > s.Loss {
+			s.Side, s.Loss = format.SideB, -l.IfB
+		}
+		if s.Loss <= cfg.Threshold {
+			continue
+		}
+
+		values, err := format.GetValues(
+			probs,
+			[]format.Offer{{Market: l.Market, Line: l.Line, Side: s.Side, Odds: 2}},
+			format.StakeConfig{},
+		)
+		if err != nil {
+			return nil, err
+		}
+		s.FairOdds = values[0].FairOdds
+		if s.FairOdds == 0 {
+			continue
+		}
+		s.Shade = cfg.Step * s.Loss / cfg.Threshold
+		if cfg.Max > 0 {
+			s.Shade = min(s.Shade, cfg.Max)
+		}
+		s.Odds = 1 / (1/s.FairOdds + s.Shade)
+		out = append(out, s)
+	}
+	return out, nil
+}
@@ -0,0 +1,183 @@
+package risk
+
+import (
+	"gotennis/format"
+	"gotennis/sim"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMatch(sets ...[2]int) sim.SimulatedMatch {
+	var m sim.SimulatedMatch
+	for _, s := range sets {
+		m.SetResults = append(m.SetResults, sim.SimulatedSet{AGames: s[0], BGames: s[1]})
+		if s[0] > s[1] {
+			m.ASets++
+		} else {
+			m.BSets++
+		}
+	}
+	return m
+}
+
+func testBook(t *testing.T) *Book {
+	t.Helper()
+	b := NewBook()
+	for _, bet := range []Bet{
+		{Match: "m1", Market: "Moneyline", Line: "ml", Side: format.SideA, Odds: 2, Stake: 10},
+		{Match: "m1", Market: "GameOU", Line: "24.5", Side: format.SideB, Odds: 1.9, Stake: 10},
+		{Match: "m1", Market: "Set3.Winner", Line: "ml", Side: format.SideA, Odds: 2, Stake: 5},
+		{Match: "m2", Market: "Moneyline", Line: "ml", Side: format.SideB, Odds: 3, Stake: 10},
+		{Match: "m1", Market: "Moneyline", Line: "ml", Side: format.SideB, Odds: 2.5, Stake: 4},
+	} {
+		require.NoError(t, b.Add(bet))
+	}
+	return b
+}
+
+func TestAdd(t *testing.T) {
+	b := testBook(t)
+	assert.Equal(t, []string{"m1", "m2"}, b.Matches())
+
+	for name, bet := range map[string]Bet{
+		"Missing match": {Market: "Moneyline", Line: "ml", Side: format.SideA, Odds: 2, Stake: 1},
+		"Invalid side":  {Match: "m1", Market: "Moneyline", Line: "ml", Side: "C", Odds: 2, Stake: 1},
+		"Invalid odds":  {Match: "m1", Market: "Moneyline", Line: "ml", Side: format.SideA, Odds: 1, Stake: 1},
+		"Invalid stake": {Match: "m1", Market: "Moneyline", Line: "ml", Side: format.SideA, Odds: 2},
+	} {
+		assert.Error(t, b.Add(bet), name)
+	}
+}
+
+func TestLiabilities(t *testing.T) {
+	assert.Equal(t, []Liability{
+		{Market: "Moneyline", Line: "ml", Bets: 2, Stake: 14, IfA: -10 + 4, IfB: 10 - 6},
+		{Market: "GameOU", Line: "24.5", Bets: 1, Stake: 10, IfA: 10, IfB: -9},
+		{Market: "Set3.Winner", Line: "ml", Bets: 1, Stake: 5, IfA: -5, IfB: 5},
+	}, testBook(t).Liabilities("m1"))
+	assert.Empty(t, testBook(t).Liabilities("m3"))
+}
+
+func TestAnalyze(t *testing.T) {
+	results := []sim.SimulatedMatch{
+		testMatch([2]int{6, 4}, [2]int{6, 4}),
+		testMatch([2]int{6, 4}, [2]int{6, 4}),
+		testMatch([2]int{4, 6}, [2]int{6, 4}, [2]int{4, 6}),
+	}
+	r := format.DefaultRegistry()
+	cfg := format.MarketConfig{BestOf: 3}
+	shade := ShadeConfig{Threshold: 5, Step: 0.02, Max: 0.03}
+
+	report, err := testBook(t).Analyze(r, "m1", results, cfg, shade)
+	require.NoError(t, err)
+	assert.Equal(t, "m1", report.Match)
+	require.Len(t, report.Scenarios, 2)
+
+	// A wins 2-0 in 20 games: both moneyline bets, under 24.5 and a void set 3 bet.
+	assert.Equal(t, "6-4 6-4", report.Scenarios[0].Score)
+	assert.InDelta(t, 2.0/3, report.Scenarios[0].Probability, 1e-9)
+	assert.InDelta(t, -10+4-9, report.Scenarios[0].PnL, 1e-9)
+	// B wins 2-1 in 30 games.
+	assert.Equal(t, "4-6 6-4 4-6", report.Scenarios[1].Score)
+	assert.InDelta(t, 10-6+10+5, report.Scenarios[1].PnL, 1e-9)
+
+	assert.InDelta(t, (2*-15.0+19)/3, report.Expected, 1e-9)
+	assert.InDelta(t, -15, report.Worst, 1e-9)
+	assert.Equal(t, "6-4 6-4", report.WorstScore)
+
+	// Under 24.5 loses 9 > 5 and is shaded by the maximum; the moneyline loses 6 on A.
+	require.Len(t, report.Shading, 2)
+	ml := report.Shading[0]
+	assert.Equal(t, format.SideA, ml.Side)
+	assert.InDelta(t, 6, ml.Loss, 1e-9)
+	assert.InDelta(t, 0.02*6/5, ml.Shade, 1e-9)
+	assert.InDelta(t, 1.5, ml.FairOdds, 1e-9)
+	assert.InDelta(t, 1/(2.0/3+0.024), ml.Odds, 1e-9)
+	ou := report.Shading[1]
+	assert.Equal(t, "GameOU", ou.Market)
+	assert.Equal(t, format.SideB, ou.Side)
+	assert.InDelta(t, 0.03, ou.Shade, 1e-9)
+
+	report, err = testBook(t).Analyze(r, "m1", results, cfg, ShadeConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, report.Shading, "Expected no shading without a threshold")
+
+	_, err = testBook(t).Analyze(r, "m3", results, cfg, shade)
+	assert.Error(t, err, "Expected an error without bets")
+	_, err = testBook(t).Analyze(r, "m1", nil, cfg, shade)
+	assert.Error(t, err, "Expected an error without simulated matches")
+	_, err = testBook(t).Analyze(r, "m1", results, cfg, ShadeConfig{Threshold: -1})
+	assert.Error(t, err, "Expected an error for a negative threshold")
+
+	b := NewBook()
+	require.NoError(t, b.Add(Bet{Match: "m1", Market: "Nope", Line: "ml", Side: format.SideA, Odds: 2, Stake: 1}))
+	_, err = b.Analyze(r, "m1", results, cfg, shade)
+	assert.Error(t, err, "Expected an error for an unknown market")
+}
+
+func TestAnalyzeFarLines(t *testing.T) {
+	results := []sim.SimulatedMatch{
+		testMatch([2]int{6, 4}, [2]int{6, 4}),
+		testMatch([2]int{6, 4}, [2]int{6, 4}),
+		testMatch([2]int{4, 6}, [2]int{6, 4}, [2]int{4, 6}),
+	}
+	b := NewBook()
+	// B wins 8 and 16 games, so over 14.5 is quoted around the mean of both scores but only around
+	// the games of the second one.
+	bet := Bet{Match: "m1", Market: "PlayerBGameOU", Line: "14.5", Side: format.SideA, Odds: 2, Stake: 10}
+	require.NoError(t, b.Add(bet))
+
+	report, err := b.Analyze(format.DefaultRegistry(), "m1", results, format.MarketConfig{BestOf: 3}, ShadeConfig{})
+	require.NoError(t, err)
+	require.Len(t, report.Scenarios, 2)
+	assert.Equal(t, "4-6 6-4 4-6", report.Scenarios[0].Score)
+	assert.InDelta(t, -10, report.Scenarios[0].PnL, 1e-9)
+	assert.Equal(t, "6-4 6-4", report.Scenarios[1].Score)
+	assert.InDelta(t, 10, report.Scenarios[1].PnL, 1e-9, "Expected the bet to lose its whole stake")
+	assert.InDelta(t, (2*10.0-10)/3, report.Expected, 1e-9)
+}
+
+func TestAnalyzeUnplayedTiebreaks(t *testing.T) {
+	tiebreak := testMatch([2]int{4, 6}, [2]int{6, 4}, [2]int{7, 6})
+	tiebreak.SetResults[2].Tiebreak = true
+	results := []sim.SimulatedMatch{testMatch([2]int{6, 4}, [2]int{6, 4}), tiebreak}
+	b := NewBook()
+	for _, bet := range []Bet{
+		{Match: "m1", Market: "Tiebreaks.InSet", Line: "3", Side: format.SideA, Odds: 2, Stake: 10},
+		{Match: "m1", Market: "Tiebreaks.Winner", Line: "3", Side: format.SideA, Odds: 2, Stake: 5},
+	} {
+		require.NoError(t, b.Add(bet))
+	}
+
+	report, err := b.Analyze(format.DefaultRegistry(), "m1", results, format.MarketConfig{BestOf: 3}, ShadeConfig{})
+	require.NoError(t, err, "Expected the bets on the third set to be void when it was not played")
+	require.Len(t, report.Scenarios, 2)
+	assert.Equal(t, "4-6 6-4 7-6", report.Scenarios[0].Score)
+	assert.InDelta(t, -15, report.Scenarios[0].PnL, 1e-9)
+	assert.Equal(t, "6-4 6-4", report.Scenarios[1].Score)
+	assert.InDelta(t, 0, report.Scenarios[1].PnL, 1e-9)
+}
+
+func TestAnalyzeSimulated(t *testing.T) {
+	results, err := sim.SimulateMatchWithOptions(0.65, 0.6, 5, sim.Options{Simulations: 5000, RecordGames: true})
+	require.NoError(t, err)
+	b := NewBook()
+	for _, bet := range []Bet{
+		{Match: "m1", Market: "GameHandicaps", Line: "-3.75", Side: format.SideA, Odds: 2, Stake: 10},
+		{Match: "m1", Market: "Breaks.TotalBreaks", Line: "5.5", Side: format.SideB, Odds: 2, Stake: 10},
+	} {
+		require.NoError(t, b.Add(bet))
+	}
+
+	report, err := b.Analyze(format.DefaultRegistry(), "m1", results, format.MarketConfig{BestOf: 5}, ShadeConfig{})
+	require.NoError(t, err)
+	var total float64
+	for _, s := range report.Scenarios {
+		total += s.Probability
+		assert.GreaterOrEqual(t, s.PnL, report.Worst)
+	}
+	assert.InDelta(t, 1, total, 1e-9)
+	assert.LessOrEqual(t, report.Worst, report.Expected)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"gotennis/risk"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiskHandler(t *testing.T) {
+	matches := `"matches":[{"match":"m1","p1":0.65,"p2":0.6,"bestof":3},` +
+		`{"match":"m2","p1":0.6,"p2":0.62,"bestof":5}],"simulations":2000`
+	bets := `"bets":[{"match":"m1","market":"Moneyline","line":"ml","side":"A","odds":1.5,"stake":100},` +
+		`{"match":"m1","market":"GameOU","line":"22.75","side":"B","odds":1.9,"stake":20},` +
+		`{"match":"m2","market":"GameHandicaps","line":"1.5","side":"B","odds":2,"stake":10}]`
+	book := "{" + matches + "," + bets + "}"
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{"Book", "{" + matches + "," + bets + `,"shading":{"threshold":20,"step":0.01}}`, http.StatusOK},
+		{"Missing matches", "{" + bets + "}", http.StatusBadRequest},
+		{"Unknown match", strings.Replace(book, `"match":"m2"`, `"match":"m3"`, 1), http.StatusBadRequest},
+		{"Invalid bestof", strings.Replace(book, `"bestof":5`, `"bestof":4`, 1), http.StatusBadRequest},
+		{"Invalid bet", strings.Replace(book, `"odds":2`, `"odds":1`, 1), http.StatusBadRequest},
+		{"Malformed body", "{", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/risk", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			riskHandler(rr, req)
+			require.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var reports []risk.Report
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &reports), "Failed to parse JSON response")
+			require.Len(t, reports, 2)
+			assert.Equal(t, "m1", reports[0].Match)
+			assert.Len(t, reports[0].Liabilities, 2)
+			assert.NotEmpty(t, reports[0].Scenarios)
+			assert.LessOrEqual(t, reports[0].Worst, reports[0].Expected)
+			require.Len(t, reports[0].Shading, 1, "Expected the moneyline to be shaded")
+			assert.Less(t, reports[0].Shading[0].Odds, reports[0].Shading[0].FairOdds)
+			assert.Empty(t, reports[1].Shading)
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	riskHandler(rr, httptest.NewRequest(http.MethodGet, "/risk", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+}
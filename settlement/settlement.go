@@ -0,0 +1,230 @@
+// Package settlement settles bets on the markets of the format package from the final score of a
+// match. A final score is a single match, so every market is settled by deriving it from that
+// match with the same code that prices it from the simulated ones.
+package settlement
+
+import (
+	"errors"
+	"fmt"
+	"gotennis/format"
+	"gotennis/sim"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Outcome is the result of a bet on one side of a market.
+type Outcome string
+
+const (
+	Win  Outcome = "win"
+	Lose Outcome = "lose"
+	// Push returns the stake.
+	Push Outcome = "push"
+	// HalfWin and HalfLose settle half of the stake as a win or loss and return the other half,
+	// as for Asian quarter lines.
+	HalfWin  Outcome = "half-win"
+	HalfLose Outcome = "half-lose"
+	// Void returns the stake as the bet does not stand.
+	Void Outcome = "void"
+)
+
+// tolerance absorbs rounding in the derived probabilities, which are 0, 0.5 or 1 for a single match.
+const tolerance = 1e-9
+
+// Rules decide how the markets of a match that was not completed are settled.
+type Rules struct {
+	// Retirement settles the markets of a retired match, either void or as if the score at
+	// retirement was final with the retiring player losing the match.
+	Retirement format.RetirementRule `json:"retirement"`
+	// CompletedSets lets bets on sets completed before a retirement or abandonment stand when the
+	// match markets are void.
+	CompletedSets bool `json:"completedSets"`
+}
+
+// Score is the final score of a match.
+type Score struct {
+	// Sets holds the games of every set played, including an unfinished last set.
+	Sets []sim.SimulatedSet `json:"sets"`
+	// Retired is the player that retired or gave a walkover, "A" or "B", or empty. A match that
+	// was neither completed nor retired was abandoned.
+	Retired string `json:"retired,omitempty"`
+}
+
+// Settlement is the outcome of both sides of a market line.
+type Settlement struct {
+	// Market is the name of the market as returned by format.MarketFamily.Probabilities and Type
+	// the kind of market, e.g. AH for every handicap market.
+	Market string        `json:"market"`
+	Type   format.Market `json:"type"`
+	Line   string        `json:"line"`
+	A      Outcome       `json:"A"`
+	B      Outcome       `json:"B"`
+}
+
+// Settlements are the settled markets of a match.
+type Settlements []Settlement
+
+// ParseScore parses a score such as "6-4 3-6 7-6(5)", with the sets separated by spaces or
+// commas and optional tiebreak points in brackets. A set is won 6-0 to 6-4, 7-5 or 7-6 in a
+// tiebreak, and a set that was not completed has no more than six games for either player.
+// retired names the player that retired, "A" or "B", or is empty; a retirement without any set is
+// a walkover.
+func ParseScore(sets, retired string) (Score, error) {
+	score := Score{Retired: strings.ToUpper(strings.TrimSpace(retired))}
+	if score.Retired != "" && score.Retired != "A" && score.Retired != "B" {
+		return Score{}, fmt.Errorf("invalid retired player %q: must be A or B", retired)
+	}
+
+	for _, s := range strings.FieldsFunc(sets, func(r rune) bool { return r == ' ' || r == ',' }) {
+		if i := strings.Index(s, "("); i >= 0 && strings.HasSuffix(s, ")") {
+			s = s[:i]
+		}
+		aStr, bStr, ok := strings.Cut(s, "-")
+		a, errA := strconv.Atoi(aStr)
+		b, errB := strconv.Atoi(bStr)
+		if !ok || errA != nil || errB != nil || a < 0 || b < 0 {
+			return Score{}, fmt.Errorf("invalid set score %q", s)
+		}
+		if hi, lo := max(a, b), min(a, b); hi > 7 || (hi == 7 && lo != 5 && lo != 6) {
+			return Score{}, fmt.Errorf("invalid set score %q: a set is won 6-0 to 6-4, 7-5 or 7-6", s)
+		}
+		score.Sets = append(score.Sets, sim.SimulatedSet{
+			AGames:   a,
+			BGames:   b,
+			Tiebreak: (a == 7 && b == 6) || (a == 6 && b == 7),
+		})
+	}
+	return score, nil
+}
+
+// Settle settles every market of the registry that can be derived from the final score. Under
+// rules that void the match, every market is void apart from those of completed sets when
+// rules.CompletedSets is set. Break markets need the games of every set and are not settled.
+// Markets such as player totals have lines generated around the result, so the lines of bets far
+// from it have to be quoted in cfg.Lines, see format.LineOptions.AddLine, to be settled.
+func Settle(r *format.Registry, score Score, cfg format.MarketConfig, rules Rules) (Settlements, error) {
+	m, err := score.Match(cfg.BestOf)
+	if err != nil {
+		return nil, err
+	}
+	standing, err := format.ApplyRetirementRule([]sim.SimulatedMatch{m}, rules.Retirement)
+	if err != nil {
+		return nil, err
+	}
+	abandoned := m.Ending == sim.Completed && max(m.ASets, m.BSets) < cfg.BestOf/2+1
+	stands := len(standing) == 1 && !abandoned
+
+	sel, err := r.Select(nil)
+	if err != nil {
+		return nil, err
+	}
+	var out Settlements
+	for name, probs := range r.Probabilities(r.Derive([]sim.SimulatedMatch{m}, cfg, sel)) {
+		setStands := rules.CompletedSets && m.Ending != sim.Walkover && IsSetMarket(name)
+		for _, p := range probs {
+			s := Settlement{Market: name, Line: p.Line, Type: p.Market, A: Void, B: Void}
+			if stands || setStands {
+				s.A = outcome(p.ProbA, p.ProbB, p.Push)
+				s.B = outcome(p.ProbB, p.ProbA, p.Push)
+			}
+			out = append(out, s)
+		}
+	}
+	slices.SortStableFunc(out, func(x, y Settlement) int { return strings.Compare(x.Market, y.Market) })
+	return out, nil
+}
+
+// Match returns the score as a match. Sets are only counted once completed and the last set may
+// only be unfinished when the match was not completed.
+func (s Score) Match(bestof int) (sim.SimulatedMatch, error) {
+	if bestof != 3 && bestof != 5 {
+		return sim.SimulatedMatch{}, errors.New("invalid bestof value: must be 3 or 5")
+	}
+	setsToWin := bestof/2 + 1
+
+	m := sim.SimulatedMatch{SetResults: s.Sets}
+	for i, set := range s.Sets {
+		if max(m.ASets, m.BSets) == setsToWin {
+			return sim.SimulatedMatch{}, fmt.Errorf("set %d was played after the match was won", i+1)
+		}
+		switch {
+		case setCompleted(set) && set.AGames > set.BGames:
+			m.ASets++
+		case setCompleted(set):
+			m.BSets++
+		case i < len(s.Sets)-1:
+			return sim.SimulatedMatch{}, fmt.Errorf("set %d was not completed", i+1)
+		}
+	}
+
+	completed := max(m.ASets, m.BSets) == setsToWin
+	switch {
+	case completed && s.Retired != "":
+		return sim.SimulatedMatch{}, errors.New("a completed match cannot have a retirement")
+	case s.Retired != "" && len(s.Sets) == 0:
+		m.Ending = sim.Walkover
+		m.RetiredA = s.Retired == "A"
+	case s.Retired != "":
+		m.Ending = sim.Retired
+		m.RetiredA = s.Retired == "A"
+	}
+	return m, nil
+}
+
+// Outcome returns the outcome of a bet on one side of a market line, comparing numeric lines by
+// value. Bets on markets or lines that were not settled, such as sets that were not played, are
+// void; the reported bool is false for them.
+func (s Settlements) Outcome(market, line string, side format.Side) (Outcome, bool) {
+	want, wantErr := strconv.ParseFloat(line, 64)
+	for _, st := range s {
+		if st.Market != market {
+			continue
+		}
+		got, err := strconv.ParseFloat(st.Line, 64)
+		if st.Line == line || (err == nil && wantErr == nil && got == want) {
+			if side == format.SideB {
+				return st.B, true
+			}
+			return st.A, true
+		}
+	}
+	return Void, false
+}
+
+// IsSetMarket reports whether the market is on a single set, which stands once the set is
+// completed. Only completed sets have such markets, so bets on them are void when Settle does not
+// settle their line.
+func IsSetMarket(name string) bool {
+	if name == "Tiebreaks.InSet" || name == "Tiebreaks.Winner" {
+		return true
+	}
+	rest, ok := strings.CutPrefix(name, "Set")
+	return ok && len(rest) > 0 && rest[0] >= '0' && rest[0] <= '9'
+}
+
+// outcome returns the outcome of the side of a single match market that wins with probability
+// win, loses with probability lose and pushes with probability push.
+func outcome(win, lose, push float64) Outcome {
+	switch {
+	case math.Abs(win-1) < tolerance:
+		return Win
+	case math.Abs(lose-1) < tolerance:
+		return Lose
+	case math.Abs(push-1) < tolerance:
+		return Push
+	case win > tolerance && push > tolerance:
+		return HalfWin
+	case lose > tolerance && push > tolerance:
+		return HalfLose
+	default:
+		return Void
+	}
+}
+
+// setCompleted reports whether a player won the set, 6-0 to 6-4, 7-5 or 7-6 in a tiebreak.
+func setCompleted(set sim.SimulatedSet) bool {
+	hi, lo := max(set.AGames, set.BGames), min(set.AGames, set.BGames)
+	return (hi == 6 && lo <= 4) || (hi == 7 && (lo == 5 || lo == 6))
+}
@@ -0,0 +1,222 @@
+package settlement
+
+import (
+	"gotennis/format"
+	"gotennis/sim"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScore(t *testing.T) {
+	score, err := ParseScore("6-4, 3-6 7-6(5)", "")
+	require.NoError(t, err)
+	assert.Equal(t, []sim.SimulatedSet{
+		{AGames: 6, BGames: 4},
+		{AGames: 3, BGames: 6},
+		{AGames: 7, BGames: 6, Tiebreak: true},
+	}, score.Sets)
+
+	score, err = ParseScore("6-4 2-1", "b")
+	require.NoError(t, err)
+	assert.Equal(t, "B", score.Retired)
+
+	for _, sets := range []string{"6-0 7-5", "6-4 6-6", "6-4 6-5"} {
+		_, err := ParseScore(sets, "")
+		assert.NoError(t, err, "Expected %q to parse", sets)
+	}
+
+	for _, tt := range []struct{ sets, retired string }{
+		{"6-4 x-1", ""},
+		{"6-4 6", ""},
+		{"6--4", ""},
+		{"6-4", "C"},
+		{"9-0", ""},
+		{"6-4 8-6", ""},
+		{"7-4", ""},
+		{"7-7", ""},
+		{"6-4 3-6 12-10", ""},
+	} {
+		_, err := ParseScore(tt.sets, tt.retired)
+		assert.Error(t, err, "Expected error for %q retired %q", tt.sets, tt.retired)
+	}
+}
+
+func TestScoreMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		sets     string
+		retired  string
+		bestof   int
+		expected sim.SimulatedMatch
+		wantErr  bool
+	}{
+		{name: "Completed", sets: "6-4 3-6 7-6", bestof: 3, expected: sim.SimulatedMatch{ASets: 2, BSets: 1}},
+		{
+			name:     "Retired",
+			sets:     "6-4 2-1",
+			retired:  "A",
+			bestof:   3,
+			expected: sim.SimulatedMatch{ASets: 1, Ending: sim.Retired, RetiredA: true},
+		},
+		{name: "Walkover", retired: "B", bestof: 3, expected: sim.SimulatedMatch{Ending: sim.Walkover}},
+		{name: "Abandoned", sets: "6-4 5-5", bestof: 3, expected: sim.SimulatedMatch{ASets: 1}},
+		{name: "Set after the match", sets: "6-4 6-4 6-4", bestof: 3, wantErr: true},
+		{name: "Unfinished set before the last", sets: "5-4 6-4", bestof: 3, wantErr: true},
+		{name: "Retired after completion", sets: "6-4 6-4", retired: "B", bestof: 3, wantErr: true},
+		{name: "Invalid bestof", sets: "6-4 6-4", bestof: 4, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, err := ParseScore(tt.sets, tt.retired)
+			require.NoError(t, err)
+			m, err := score.Match(tt.bestof)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			tt.expected.SetResults = score.Sets
+			assert.Equal(t, tt.expected, m)
+		})
+	}
+}
+
+func settle(t *testing.T, sets, retired string, cfg format.MarketConfig, rules Rules) Settlements {
+	t.Helper()
+	score, err := ParseScore(sets, retired)
+	require.NoError(t, err)
+	settled, err := Settle(format.DefaultRegistry(), score, cfg, rules)
+	require.NoError(t, err)
+	return settled
+}
+
+func TestSettle(t *testing.T) {
+	cfg := format.MarketConfig{
+		BestOf: 3,
+		Lines:  format.LineOptions{Type: format.QuarterLines, GameHandicaps: []float64{-2, -1.75, -2.5}},
+	}
+	// 17 games to 15 for A, 32 in total
+	settled := settle(t, "6-3 4-6 7-6", "", cfg, Rules{Retirement: format.RetirementVoid})
+
+	tests := []struct {
+		market   string
+		line     string
+		side     format.Side
+		expected Outcome
+	}{
+		{"Moneyline", "ml", format.SideA, Win},
+		{"Moneyline", "ml", format.SideB, Lose},
+		{"GameHandicaps", "-2", format.SideA, Push},
+		{"GameHandicaps", "-1.75", format.SideA, HalfWin},
+		{"GameHandicaps", "-1.75", format.SideB, HalfLose},
+		{"GameHandicaps", "-2.5", format.SideB, Win},
+		{"GameOU", "31.5", format.SideA, Win},
+		{"GameOU", "32.00", format.SideB, Push},
+		{"GameOU", "32.25", format.SideB, HalfWin},
+		{"SetOU", "2.5", format.SideA, Win},
+		{"CorrectScore", "2-1", format.SideA, Win},
+		{"CorrectScore", "2-0", format.SideA, Lose},
+		{"Set2.Winner", "ml", format.SideB, Win},
+		{"Set3.CorrectScore", "7-6", format.SideA, Win},
+		{"Tiebreaks.InMatch", "match", format.SideA, Win},
+		{"Tiebreaks.Winner", "3", format.SideA, Win},
+		{"Combos.DoubleResult", "A/A", format.SideA, Win},
+		{"GameOddEven", "odd", format.SideB, Win},
+	}
+	for _, tt := range tests {
+		got, ok := settled.Outcome(tt.market, tt.line, tt.side)
+		assert.True(t, ok, "Expected %s %s to be settled", tt.market, tt.line)
+		assert.Equal(t, tt.expected, got, "%s %s side %s", tt.market, tt.line, tt.side)
+	}
+
+	got, ok := settled.Outcome("Tiebreaks.Winner", "1", format.SideA)
+	assert.False(t, ok, "Expected no tiebreak winner market without a tiebreak")
+	assert.Equal(t, Void, got)
+}
+
+func TestSettleFarLines(t *testing.T) {
+	bets := []struct {
+		market, line string
+	}{
+		{"GameOU", "22.5"},
+		{"GameTotalPMF", "22"},
+		{"GameTotalBands", "21-23"},
+		{"Combos.WinAndTotal", "A/over 22.5"},
+		{"PlayerAGameOU", "20.5"},
+		{"PlayerBGameOU", "9.5"},
+	}
+	cfg := format.MarketConfig{BestOf: 3, Lines: format.LineOptions{Type: format.HalfLines}}
+	for _, b := range bets {
+		require.True(t, cfg.Lines.AddLine(b.market, b.line), "%s %s", b.market, b.line)
+	}
+	// 14 games, far below the lines of the bets.
+	settled := settle(t, "6-1 6-1", "", cfg, Rules{Retirement: format.RetirementVoid})
+	for _, b := range bets {
+		got, ok := settled.Outcome(b.market, b.line, format.SideA)
+		assert.True(t, ok, "Expected %s %s to be settled", b.market, b.line)
+		assert.Equal(t, Lose, got, "%s %s", b.market, b.line)
+		got, _ = settled.Outcome(b.market, b.line, format.SideB)
+		assert.Equal(t, Win, got, "%s %s", b.market, b.line)
+	}
+
+	got, ok := settled.Outcome("Combos.WinAndTotal", "A/under 22.5", format.SideA)
+	assert.True(t, ok, "Expected every side of a quoted total to be settled")
+	assert.Equal(t, Win, got)
+}
+
+func TestSettleRetirement(t *testing.T) {
+	cfg := format.MarketConfig{BestOf: 3, Lines: format.LineOptions{Type: format.HalfLines}}
+
+	t.Run("Settled as final", func(t *testing.T) {
+		settled := settle(t, "6-4 2-1", "A", cfg, Rules{Retirement: format.RetirementSettle})
+		got, _ := settled.Outcome("Moneyline", "ml", format.SideB)
+		assert.Equal(t, Win, got, "Expected the retiring player to lose")
+		got, _ = settled.Outcome("Set1.Winner", "ml", format.SideA)
+		assert.Equal(t, Win, got)
+	})
+
+	t.Run("Void", func(t *testing.T) {
+		settled := settle(t, "6-4 2-1", "A", cfg, Rules{Retirement: format.RetirementVoid})
+		for _, s := range settled {
+			assert.Equal(t, Void, s.A, "%s %s", s.Market, s.Line)
+			assert.Equal(t, Void, s.B, "%s %s", s.Market, s.Line)
+		}
+	})
+
+	t.Run("Completed sets stand", func(t *testing.T) {
+		rules := Rules{Retirement: format.RetirementVoid, CompletedSets: true}
+		settled := settle(t, "6-4 2-1", "A", cfg, rules)
+		got, _ := settled.Outcome("Set1.Winner", "ml", format.SideA)
+		assert.Equal(t, Win, got)
+		got, _ = settled.Outcome("Tiebreaks.InSet", "1", format.SideA)
+		assert.Equal(t, Lose, got)
+		got, _ = settled.Outcome("Moneyline", "ml", format.SideA)
+		assert.Equal(t, Void, got)
+		_, ok := settled.Outcome("Set2.Winner", "ml", format.SideA)
+		assert.False(t, ok, "Expected no market on the unfinished set")
+	})
+
+	t.Run("Abandoned", func(t *testing.T) {
+		settled := settle(t, "6-4 5-5", "", cfg, Rules{Retirement: format.RetirementSettle, CompletedSets: true})
+		got, _ := settled.Outcome("Moneyline", "ml", format.SideA)
+		assert.Equal(t, Void, got, "Expected abandoned matches to be void under either rule")
+		got, _ = settled.Outcome("Set1.Winner", "ml", format.SideA)
+		assert.Equal(t, Win, got)
+	})
+
+	t.Run("Walkover", func(t *testing.T) {
+		settled := settle(t, "", "B", cfg, Rules{Retirement: format.RetirementSettle, CompletedSets: true})
+		got, _ := settled.Outcome("Moneyline", "ml", format.SideA)
+		assert.Equal(t, Void, got)
+	})
+
+	t.Run("Unknown rule", func(t *testing.T) {
+		score, err := ParseScore("6-4 6-4", "")
+		require.NoError(t, err)
+		_, err = Settle(format.DefaultRegistry(), score, cfg, Rules{Retirement: "maybe"})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,118 @@
+package sim
+
+import "errors"
+
+// MatchOutcome is a final match score together with the probability of the match ending with it.
+type MatchOutcome struct {
+	ASets  int     `json:"ASets"`
+	BSets  int     `json:"BSets"`
+	AGames int     `json:"AGames"`
+	BGames int     `json:"BGames"`
+	Prob   float64 `json:"prob"`
+}
+
+// SetOutcome is a set score together with the probability of the set ending with it.
+type SetOutcome struct {
+	SimulatedSet
+	Prob float64 `json:"prob"`
+}
+
+// ExactOutcomes returns the exact distribution of final match scores under the same point model
+// SimulateMatch samples from, without the Monte Carlo noise. Retirements are not modelled.
+func ExactOutcomes(playerA, playerB float64, bo int) ([]MatchOutcome, error) {
+	if bo != 3 && bo != 5 {
+		return nil, errors.New("invalid number of sets")
+	}
+	setsToWin := bo/2 + 1
+
+	// set distributions from A's point of view, depending on who serves first
+	aFirst := exactSet(playerA, playerB)
+	bFirst := exactSet(playerB, playerA)
+	for i := range bFirst {
+		bFirst[i].AGames, bFirst[i].BGames = bFirst[i].BGames, bFirst[i].AGames
+	}
+
+	type state struct {
+		aSets, bSets, aGames, bGames int
+	}
+	current := map[state]float64{{}: 1}
+	final := make(map[state]float64)
+	for len(current) > 0 {
+		next := make(map[state]float64)
+		for st, p := range current {
+			sets := aFirst
+			if (st.aSets+st.bSets)%2 != 0 {
+				sets = bFirst
+			}
+			for _, set := range sets {
+				ns := state{st.aSets, st.bSets, st.aGames + set.AGames, st.bGames + set.BGames}
+				if set.AGames > set.BGames {
+					ns.aSets++
+				} else {
+					ns.bSets++
+				}
+				if ns.aSets == setsToWin || ns.bSets == setsToWin {
+					final[ns] += p * set.Prob
+				} else {
+					next[ns] += p * set.Prob
+				}
+			}
+		}
+		current = next
+	}
+
+	out := make([]MatchOutcome, 0, len(final))
+	for st, p := range final {
+		out = append(out, MatchOutcome{ASets: st.aSets, BSets: st.bSets, AGames: st.aGames, BGames: st.bGames, Prob: p})
+	}
+	return out, nil
+}
+
+// exactSet returns the distribution of set scores when player1, winning a point on serve with
+// probability 'a', serves the first game against player2 winning a point on serve with probability 'b'.
+func exactSet(a, b float64) []SetOutcome {
+	aGameWinProb := simulateGame(a)
+	bGameWinProb := simulateGame(b)
+	tiebreak := tiebreakProb(a, b, true)
+
+	// reach[i][j] is the probability of the set passing through the score i-j
+	var reach [7][7]float64
+	reach[0][0] = 1
+	var out []SetOutcome
+	for total := 0; total <= 12; total++ {
+		for i := max(0, total-6); i <= min(6, total); i++ {
+			j := total - i
+			p := reach[i][j]
+			if p == 0 {
+				continue
+			}
+
+			if i == 6 && j == 6 {
+				out = append(out,
+					SetOutcome{SimulatedSet{AGames: 7, BGames: 6, Tiebreak: true}, p * tiebreak},
+					SetOutcome{SimulatedSet{AGames: 6, BGames: 7, Tiebreak: true}, p * (1 - tiebreak)},
+				)
+				continue
+			}
+
+			probPlayer1WinsGame := aGameWinProb
+			if total%2 != 0 {
+				probPlayer1WinsGame = 1 - bGameWinProb
+			}
+			for _, g := range []struct {
+				i, j int
+				p    float64
+			}{
+				{i + 1, j, p * probPlayer1WinsGame},
+				{i, j + 1, p * (1 - probPlayer1WinsGame)},
+			} {
+				if (g.i >= 6 || g.j >= 6) && (g.i-g.j >= 2 || g.j-g.i >= 2) {
+					out = append(out, SetOutcome{SimulatedSet{AGames: g.i, BGames: g.j}, g.p})
+				} else {
+					reach[g.i][g.j] += g.p
+				}
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,106 @@
+package sim
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExactSet(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b float64
+	}{
+		{"Equal servers", 0.6, 0.6},
+		{"Strong player1", 0.75, 0.55},
+		{"Weak player1", 0.5, 0.7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total := 0.0
+			for _, o := range exactSet(tt.a, tt.b) {
+				assert.True(t, isValidSetScore(o.AGames, o.BGames), "invalid set score: %d-%d", o.AGames, o.BGames)
+				total += o.Prob
+			}
+			assert.InDelta(t, 1.0, total, 1e-9, "set probabilities should sum to 1")
+		})
+	}
+}
+
+func TestExactOutcomes(t *testing.T) {
+	tests := []struct {
+		name   string
+		pA, pB float64
+		bo     int
+	}{
+		{"BO3 equal players", 0.62, 0.62, 3},
+		{"BO3 strong A", 0.68, 0.6, 3},
+		{"BO5 strong B", 0.6, 0.66, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outcomes, err := ExactOutcomes(tt.pA, tt.pB, tt.bo)
+			require.NoError(t, err)
+
+			setsToWin := tt.bo/2 + 1
+			total, aWin := 0.0, 0.0
+			for _, o := range outcomes {
+				assert.True(
+					t,
+					o.ASets == setsToWin || o.BSets == setsToWin,
+					"match should end at %d sets, got %d-%d",
+					setsToWin,
+					o.ASets,
+					o.BSets,
+				)
+				total += o.Prob
+				if o.ASets > o.BSets {
+					aWin += o.Prob
+				}
+			}
+			assert.InDelta(t, 1.0, total, 1e-9, "match probabilities should sum to 1")
+
+			const n = 20000
+			results, err := SimulateMatch(tt.pA, tt.pB, tt.bo, n)
+			require.NoError(t, err)
+			simulated := 0
+			for _, m := range results {
+				if m.AWins() {
+					simulated++
+				}
+			}
+			assert.InDelta(
+				t,
+				aWin,
+				float64(simulated)/n,
+				0.02,
+				"exact and simulated match win probabilities should agree",
+			)
+		})
+	}
+}
+
+func TestExactOutcomesSymmetry(t *testing.T) {
+	for _, bo := range []int{3, 5} {
+		t.Run(fmt.Sprintf("BO%d", bo), func(t *testing.T) {
+			outcomes, err := ExactOutcomes(0.63, 0.63, bo)
+			require.NoError(t, err)
+			aWin := 0.0
+			for _, o := range outcomes {
+				if o.ASets > o.BSets {
+					aWin += o.Prob
+				}
+			}
+			assert.InDelta(t, 0.5, aWin, 1e-9, "equal players should have equal chances")
+		})
+	}
+}
+
+func TestExactOutcomesInvalidBestOf(t *testing.T) {
+	_, err := ExactOutcomes(0.6, 0.6, 4)
+	assert.EqualError(t, err, "invalid number of sets")
+}
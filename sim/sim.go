@@ -6,43 +6,136 @@ import (
 	"math/rand/v2"
 )
 
-// SimResult represents the result of a single simulated game between two players.
+// SimResult represents the result of a single simulated game between two players: A or B is 1
+// for the player that won the game, and ServingA reports whether A served it. For a tiebreak
+// ServingA reports whether A served its first point.
 type SimResult struct {
-	A        int  `json:"A"`
-	B        int  `json:"B"`
+	A        int8 `json:"A"`
+	B        int8 `json:"B"`
 	ServingA bool `json:"servingA"`
 }
 
+// Ending describes how a simulated match finished.
+type Ending int
+
+const (
+	// Completed matches were played until one player won the required number of sets.
+	Completed Ending = iota
+	// Retired matches were abandoned part way through after a player retired.
+	Retired
+	// Walkover matches were never started because a player withdrew.
+	Walkover
+)
+
 // SimulatedMatch represents the result of a simulated tennis match.
+// For matches that did not complete, the last entry of SetResults holds the games
+// played in the unfinished set.
 type SimulatedMatch struct {
 	ASets      int `json:"ASets"`
 	BSets      int `json:"BSets"`
 	SetResults []SimulatedSet
+	Ending     Ending `json:"ending"`
+	// RetiredA reports whether player A (rather than B) retired or gave the walkover.
+	RetiredA bool `json:"retiredA"`
+}
+
+// AWins reports whether player A is the winner of the match. A player that retires
+// or gives a walkover loses the match.
+func (m SimulatedMatch) AWins() bool {
+	if m.Ending != Completed {
+		return !m.RetiredA
+	}
+	return m.ASets > m.BSets
 }
 
 // SimulatedSet represents the result of a simulated tennis set.
 type SimulatedSet struct {
 	AGames int `json:"AGames"`
 	BGames int `json:"BGames"`
+	// Tiebreak reports whether the set was decided by a tiebreak.
+	Tiebreak bool `json:"tiebreak"`
+	// Games holds every game of the set in order when Options.RecordGames is set.
+	Games []SimResult `json:"games,omitempty"`
+}
+
+// Options configures a batch of match simulations.
+type Options struct {
+	// Simulations is the number of matches to simulate, 1,000,000 when not positive.
+	Simulations int
+	// RetireA and RetireB are per-game hazard rates: the probability that the player
+	// retires before any given game of the match.
+	RetireA float64
+	RetireB float64
+	// WalkoverA and WalkoverB are the probabilities that the player withdraws before the match.
+	WalkoverA float64
+	WalkoverB float64
+	// RecordGames records the server and winner of every game in SimulatedSet.Games.
+	RecordGames bool
+	// Seed, when not zero, makes the simulation reproducible. Every match draws from its own stream
+	// of the seed, so that simulations with the same seed at nearby serve probabilities use common
+	// random numbers and their results differ only where the probabilities do.
+	Seed uint64
 }
 
+// setOptions configures the simulation of a single set from the point of view of the player
+// serving first.
+type setOptions struct {
+	retire1     float64
+	retire2     float64
+	recordGames bool
+	// rng is the source of the random numbers, the global one when nil.
+	rng *rand.Rand
+}
+
+// retirement identifies which player, if any, retired during a set.
+type retirement int
+
+const (
+	noRetirement retirement = iota
+	player1Retired
+	player2Retired
+)
+
 // SimulateMatch simulates a tennis match between two players n times and returns the simulation results.
 func SimulateMatch(playerA, playerB float64, bo int, n ...int) ([]SimulatedMatch, error) {
+	var opts Options
+	if len(n) > 0 {
+		opts.Simulations = n[0]
+	}
+	return SimulateMatchWithOptions(playerA, playerB, bo, opts)
+}
+
+// SimulateMatchWithOptions simulates a tennis match between two players according to opts
+// and returns the simulation results.
+func SimulateMatchWithOptions(playerA, playerB float64, bo int, opts Options) ([]SimulatedMatch, error) {
 	if bo != 3 && bo != 5 {
 		return nil, errors.New("invalid number of sets")
 	}
+	for _, p := range []float64{opts.RetireA, opts.RetireB, opts.WalkoverA, opts.WalkoverB} {
+		if p < 0 || p > 1 {
+			return nil, errors.New("retirement and walkover probabilities must be between 0 and 1")
+		}
+	}
 
 	setsToWinForMatch := (bo / 2) + 1
-	var numSimulations int
-	if len(n) > 0 && n[0] > 0 {
-		numSimulations = n[0]
-	} else {
+	numSimulations := opts.Simulations
+	if numSimulations <= 0 {
 		numSimulations = 1000000
 	}
 
+	var rng *rand.Rand
+	var pcg *rand.PCG
+	if opts.Seed != 0 {
+		pcg = rand.NewPCG(opts.Seed, 0)
+		rng = rand.New(pcg)
+	}
+
 	res := make([]SimulatedMatch, 0, numSimulations)
-	for range numSimulations {
-		res = append(res, simulateSingleMatch(playerA, playerB, setsToWinForMatch))
+	for i := range numSimulations {
+		if pcg != nil {
+			pcg.Seed(opts.Seed, uint64(i))
+		}
+		res = append(res, playMatch(rng, playerA, playerB, setsToWinForMatch, opts))
 	}
 
 	return res, nil
@@ -50,11 +143,28 @@ func SimulateMatch(playerA, playerB float64, bo int, n ...int) ([]SimulatedMatch
 
 // simulateSingleMatch simulates a single tennis match between two players in given bestof n match.
 func simulateSingleMatch(pA, pB float64, setsToWin int) SimulatedMatch {
+	return playMatch(nil, pA, pB, setsToWin, Options{})
+}
+
+// playMatch simulates a single tennis match with the random numbers of rng, or the global source
+// when nil, allowing either player to withdraw or retire according to the hazard rates in opts.
+func playMatch(rng *rand.Rand, pA, pB float64, setsToWin int, opts Options) SimulatedMatch {
 	matchResult := SimulatedMatch{
 		SetResults: make([]SimulatedSet, 0, setsToWin*2-1),
 	}
 
+	if opts.WalkoverA > 0 && draw(rng) < opts.WalkoverA {
+		matchResult.Ending = Walkover
+		matchResult.RetiredA = true
+		return matchResult
+	}
+	if opts.WalkoverB > 0 && draw(rng) < opts.WalkoverB {
+		matchResult.Ending = Walkover
+		return matchResult
+	}
+
 	var set SimulatedSet
+	var retired retirement
 	for {
 		if matchResult.ASets == setsToWin || matchResult.BSets == setsToWin {
 			return matchResult
@@ -62,29 +172,52 @@ func simulateSingleMatch(pA, pB float64, setsToWin int) SimulatedMatch {
 
 		aServesFirstGameOfSet := (matchResult.ASets+matchResult.BSets)%2 == 0
 		if aServesFirstGameOfSet {
-			set = simulateSet(pA, pB, true)
+			set, retired = playSet(pA, pB, true, setOptions{opts.RetireA, opts.RetireB, opts.RecordGames, rng})
 		} else {
-			set = simulateSet(pB, pA, true)
+			// the set is played from B's point of view, so swap it back to A/B orientation
+			set, retired = playSet(pB, pA, true, setOptions{opts.RetireB, opts.RetireA, opts.RecordGames, rng})
+			set.AGames, set.BGames = set.BGames, set.AGames
+			for i, g := range set.Games {
+				set.Games[i] = SimResult{A: g.B, B: g.A, ServingA: !g.ServingA}
+			}
+			switch retired {
+			case player1Retired:
+				retired = player2Retired
+			case player2Retired:
+				retired = player1Retired
+			case noRetirement:
+			}
+		}
+
+		matchResult.SetResults = append(matchResult.SetResults, set)
+		if retired != noRetirement {
+			matchResult.Ending = Retired
+			matchResult.RetiredA = retired == player1Retired
+			return matchResult
 		}
 
 		if set.AGames > set.BGames {
-			if aServesFirstGameOfSet {
-				matchResult.ASets++
-			} else {
-				matchResult.BSets++
-			}
+			matchResult.ASets++
 		} else {
-			if aServesFirstGameOfSet {
-				matchResult.BSets++
-			} else {
-				matchResult.ASets++
-			}
+			matchResult.BSets++
 		}
-		matchResult.SetResults = append(matchResult.SetResults, set)
 	}
 }
 
-func aWinsTiebreak(probAonServe, probBonServe float64, aServesFirstPointInTiebreak bool) bool {
+func aWinsTiebreak(rng *rand.Rand, probAonServe, probBonServe float64, aServesFirstPointInTiebreak bool) bool {
+	return tiebreakProb(probAonServe, probBonServe, aServesFirstPointInTiebreak) > draw(rng)
+}
+
+// draw returns a uniform random number from rng, or from the global source when rng is nil.
+func draw(rng *rand.Rand) float64 {
+	if rng == nil {
+		return rand.Float64()
+	}
+	return rng.Float64()
+}
+
+// tiebreakProb returns the probability that player A wins a tiebreak to 7 points.
+func tiebreakProb(probAonServe, probBonServe float64, aServesFirstPointInTiebreak bool) float64 {
 	const maxTotalTiebreakPoints = 30
 	memo := make([][]float64, maxTotalTiebreakPoints+1)
 	for i := range memo {
@@ -140,14 +273,25 @@ func aWinsTiebreak(probAonServe, probBonServe float64, aServesFirstPointInTiebre
 		return res
 	}
 
-	return tiebreakProbRecursive(0, 0) > rand.Float64()
+	return tiebreakProbRecursive(0, 0)
 }
 
 // simulateSet simulates a tennis set between two players given their serve probabilities.
 // 'a' is prob player1 wins point on their serve, 'b' is prob player2 wins point on their serve.
 // 'player1ServesFirstGame' indicates if player1 (associated with prob 'a') serves the first game of the set.
 func simulateSet(a, b float64, player1ServesFirstGame bool) SimulatedSet {
+	res, _ := playSet(a, b, player1ServesFirstGame, setOptions{})
+	return res
+}
+
+// playSet simulates a tennis set like simulateSet, but before every game player1 retires with
+// probability 'opts.retire1' and player2 with probability 'opts.retire2'. If a player retires the
+// games played so far are returned together with the retiring player.
+func playSet(a, b float64, player1ServesFirstGame bool, opts setOptions) (SimulatedSet, retirement) {
 	res := SimulatedSet{AGames: 0, BGames: 0}
+	if opts.recordGames {
+		res.Games = make([]SimResult, 0, 13)
+	}
 
 	serverGame := 1
 	if !player1ServesFirstGame {
@@ -158,12 +302,22 @@ func simulateSet(a, b float64, player1ServesFirstGame bool) SimulatedSet {
 	aGameWinProb := simulateGame(a)
 	bGameWinProb := simulateGame(b)
 	for {
+		if opts.retire1 > 0 && draw(opts.rng) < opts.retire1 {
+			return res, player1Retired
+		}
+		if opts.retire2 > 0 && draw(opts.rng) < opts.retire2 {
+			return res, player2Retired
+		}
+
 		if res.AGames == 6 && res.BGames == 6 {
-			if aWinsTiebreak(a, b, player1ServesFirstPointInTiebreak) {
+			res.Tiebreak = true
+			player1Wins := aWinsTiebreak(opts.rng, a, b, player1ServesFirstPointInTiebreak)
+			if player1Wins {
 				res.AGames++
 			} else {
 				res.BGames++
 			}
+			res.recordGame(player1Wins, player1ServesFirstPointInTiebreak)
 			break
 		}
 
@@ -174,7 +328,8 @@ func simulateSet(a, b float64, player1ServesFirstGame bool) SimulatedSet {
 			probServerWinsGame = bGameWinProb
 		}
 
-		if rand.Float64() < probServerWinsGame {
+		serverWins := draw(opts.rng) < probServerWinsGame
+		if serverWins {
 			if serverGame == 1 {
 				res.AGames++
 			} else {
@@ -187,6 +342,7 @@ func simulateSet(a, b float64, player1ServesFirstGame bool) SimulatedSet {
 				res.AGames++
 			}
 		}
+		res.recordGame(serverWins == (serverGame == 1), serverGame == 1)
 
 		if (res.AGames >= 6 || res.BGames >= 6) && math.Abs(float64(res.AGames-res.BGames)) >= 2 {
 			break
@@ -194,7 +350,25 @@ func simulateSet(a, b float64, player1ServesFirstGame bool) SimulatedSet {
 		serverGame = 3 - serverGame
 	}
 
-	return res
+	return res, noRetirement
+}
+
+// recordGame appends a game won by A (or B) with A (or B) serving to the recorded games, if any.
+func (s *SimulatedSet) recordGame(aWins, aServes bool) {
+	if s.Games == nil {
+		return
+	}
+	g := SimResult{B: 1, ServingA: aServes}
+	if aWins {
+		g = SimResult{A: 1, ServingA: aServes}
+	}
+	s.Games = append(s.Games, g)
+}
+
+// HoldProbability returns the probability of a player winning a point on serve with probability
+// p holding their service game.
+func HoldProbability(p float64) float64 {
+	return simulateGame(p)
 }
 
 // simulateGame simulates a single tennis game based on given serve probabilities.
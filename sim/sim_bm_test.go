@@ -18,7 +18,7 @@ func BenchmarkSimulateSingleMatch(b *testing.B) {
 
 func BenchmarkAWinsTiebreak(b *testing.B) {
 	for range b.N {
-		aWinsTiebreak(0.65, 0.60, true)
+		aWinsTiebreak(nil, 0.65, 0.60, true)
 	}
 }
 
@@ -33,3 +33,9 @@ func BenchmarkSimulateGame(b *testing.B) {
 		simulateGame(0.65)
 	}
 }
+
+func BenchmarkExactOutcomes(b *testing.B) {
+	for range b.N {
+		_, _ = ExactOutcomes(0.65, 0.60, 3)
+	}
+}
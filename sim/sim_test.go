@@ -222,7 +222,7 @@ func TestAWinsTiebreak(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			aWins := 0
 			for range tt.iterations {
-				if aWinsTiebreak(tt.a, tt.b, tt.aServing) {
+				if aWinsTiebreak(nil, tt.a, tt.b, tt.aServing) {
 					aWins++
 				}
 			}
@@ -601,3 +601,199 @@ func TestAgainstDiscoverMD(t *testing.T) {
 		)
 	})
 }
+
+func TestSimulateMatchWithOptions(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         Options
+		expectError  bool
+		errorMessage string
+	}{
+		{
+			name: "No retirements",
+			opts: Options{Simulations: 200},
+		},
+		{
+			name: "Retirement hazard",
+			opts: Options{Simulations: 200, RetireA: 0.05, RetireB: 0.02},
+		},
+		{
+			name: "Walkovers",
+			opts: Options{Simulations: 200, WalkoverA: 0.3, WalkoverB: 0.3},
+		},
+		{
+			name:         "Negative hazard",
+			opts:         Options{RetireA: -0.1},
+			expectError:  true,
+			errorMessage: "retirement and walkover probabilities must be between 0 and 1",
+		},
+		{
+			name:         "Walkover above one",
+			opts:         Options{WalkoverB: 1.1},
+			expectError:  true,
+			errorMessage: "retirement and walkover probabilities must be between 0 and 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := SimulateMatchWithOptions(0.65, 0.6, 3, tt.opts)
+			if tt.expectError {
+				require.Error(t, err, "expected error for options %+v", tt.opts)
+				assert.EqualError(t, err, tt.errorMessage, "expected error message '%s'", tt.errorMessage)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, result, tt.opts.Simulations, "expected %d simulations", tt.opts.Simulations)
+			for _, m := range result {
+				switch m.Ending {
+				case Completed:
+					assert.True(t, m.ASets == 2 || m.BSets == 2, "completed match should have a winner")
+					assert.Equal(t, m.ASets > m.BSets, m.AWins(), "winner should follow the set count")
+				case Retired:
+					assert.Less(t, m.ASets, 2, "retired match should be unfinished, got A=%d", m.ASets)
+					assert.Less(t, m.BSets, 2, "retired match should be unfinished, got B=%d", m.BSets)
+					assert.Len(t, m.SetResults, m.ASets+m.BSets+1, "unfinished set should be recorded")
+					assert.Equal(t, !m.RetiredA, m.AWins(), "retiring player should lose")
+				case Walkover:
+					assert.Empty(t, m.SetResults, "walkover should have no sets")
+					assert.Equal(t, !m.RetiredA, m.AWins(), "withdrawing player should lose")
+				}
+			}
+		})
+	}
+}
+
+func TestSimulateMatchSeed(t *testing.T) {
+	opts := Options{Simulations: 2000, Seed: 42}
+	first, err := SimulateMatchWithOptions(0.65, 0.6, 3, opts)
+	require.NoError(t, err)
+	second, err := SimulateMatchWithOptions(0.65, 0.6, 3, opts)
+	require.NoError(t, err)
+	assert.Equal(t, first, second, "the same seed should give the same matches")
+
+	other, err := SimulateMatchWithOptions(0.65, 0.6, 3, Options{Simulations: 2000, Seed: 7})
+	require.NoError(t, err)
+	assert.NotEqual(t, first, other, "another seed should give other matches")
+
+	// Common random numbers: a better server rarely loses a match it won with the same draws,
+	// while independent draws would turn about a quarter of the matches into such losses.
+	better, err := SimulateMatchWithOptions(0.67, 0.6, 3, opts)
+	require.NoError(t, err)
+	changed := 0
+	for i := range first {
+		if first[i].AWins() && !better[i].AWins() {
+			changed++
+		}
+	}
+	assert.Less(t, changed, len(first)/20, "bumped matches should follow the same draws")
+}
+
+func TestPlayMatchRetirement(t *testing.T) {
+	t.Run("Certain retirement ends the match before the first game", func(t *testing.T) {
+		m := playMatch(nil, 0.6, 0.6, 2, Options{RetireA: 1})
+		assert.Equal(t, Retired, m.Ending)
+		assert.True(t, m.RetiredA, "expected A to retire")
+		assert.False(t, m.AWins(), "A should lose after retiring")
+		require.Len(t, m.SetResults, 1)
+		assert.Equal(t, SimulatedSet{}, m.SetResults[0], "no games should have been played")
+	})
+
+	t.Run("Certain walkover", func(t *testing.T) {
+		m := playMatch(nil, 0.6, 0.6, 2, Options{WalkoverB: 1})
+		assert.Equal(t, Walkover, m.Ending)
+		assert.False(t, m.RetiredA, "expected B to withdraw")
+		assert.True(t, m.AWins(), "A should win by walkover")
+	})
+
+	t.Run("Retirement hazard shortens matches", func(t *testing.T) {
+		const n = 2000
+		var gamesFull, gamesHazard int
+		for range n {
+			for _, s := range playMatch(nil, 0.6, 0.6, 2, Options{}).SetResults {
+				gamesFull += s.AGames + s.BGames
+			}
+			for _, s := range playMatch(nil, 0.6, 0.6, 2, Options{RetireB: 0.05}).SetResults {
+				gamesHazard += s.AGames + s.BGames
+			}
+		}
+		assert.Less(t, gamesHazard, gamesFull, "retirements should reduce the games played")
+	})
+}
+
+func TestPlayMatchSetOrientation(t *testing.T) {
+	const n = 500
+	aSets, bSets := 0, 0
+	for range n {
+		m := simulateSingleMatch(0.8, 0.4, 3)
+		for _, s := range m.SetResults {
+			if s.AGames > s.BGames {
+				aSets++
+			} else {
+				bSets++
+			}
+		}
+		assert.Equal(t, m.ASets+m.BSets, len(m.SetResults), "every set should be counted")
+	}
+	assert.Greater(t, aSets, 10*bSets, "set scores should be recorded from A's point of view")
+}
+
+func TestSimulateSetTiebreakFlag(t *testing.T) {
+	tiebreaks := 0
+	for range 500 {
+		set := simulateSet(0.9, 0.9, true)
+		isTiebreakScore := (set.AGames == 7 && set.BGames == 6) || (set.AGames == 6 && set.BGames == 7)
+		assert.Equal(
+			t,
+			isTiebreakScore,
+			set.Tiebreak,
+			"tiebreak flag should match the set score %d-%d",
+			set.AGames,
+			set.BGames,
+		)
+		if set.Tiebreak {
+			tiebreaks++
+		}
+	}
+	assert.Positive(t, tiebreaks, "dominant servers should play tiebreaks")
+
+	m := simulateSingleMatch(0.95, 0.95, 3)
+	for _, set := range m.SetResults {
+		assert.Equal(t, set.AGames+set.BGames == 13, set.Tiebreak, "tiebreak flag should survive set orientation")
+	}
+}
+
+func TestRecordGames(t *testing.T) {
+	results, err := SimulateMatchWithOptions(0.7, 0.6, 5, Options{Simulations: 500, RecordGames: true})
+	require.NoError(t, err)
+
+	for _, m := range results {
+		for _, s := range m.SetResults {
+			require.Len(t, s.Games, s.AGames+s.BGames, "every game of the set should be recorded")
+			aGames, bGames := 0, 0
+			for i, g := range s.Games {
+				assert.Equal(t, int8(1), g.A+g.B, "every game should have exactly one winner")
+				aGames += int(g.A)
+				bGames += int(g.B)
+				if i > 0 {
+					assert.NotEqual(t, s.Games[i-1].ServingA, g.ServingA, "serve should alternate")
+				}
+			}
+			assert.Equal(t, s.AGames, aGames, "recorded games should add up to A's games")
+			assert.Equal(t, s.BGames, bGames, "recorded games should add up to B's games")
+		}
+	}
+
+	results, err = SimulateMatchWithOptions(0.7, 0.6, 3, Options{Simulations: 10})
+	require.NoError(t, err)
+	for _, m := range results {
+		for _, s := range m.SetResults {
+			assert.Nil(t, s.Games, "games should only be recorded on request")
+		}
+	}
+}
+
+func TestHoldProbability(t *testing.T) {
+	assert.InDelta(t, 0.5, HoldProbability(0.5), 1e-9)
+	assert.Greater(t, HoldProbability(0.65), 0.65, "holding serve should be likelier than winning a point")
+}
@@ -0,0 +1,146 @@
+package solver
+
+import (
+	"errors"
+	"fmt"
+	"gotennis/sim"
+)
+
+// AverageServe is the serve point win probability assumed for an average player. It fixes the
+// overall serve level when only a moneyline is given.
+const AverageServe float64 = 0.63
+
+const (
+	minServeLevel = 0.5
+	maxServeLevel = 0.95
+	iterations    = 40
+)
+
+// Target holds the fair market probabilities the solver has to reproduce.
+type Target struct {
+	BestOf int `json:"bestof"`
+	// MoneylineA is the probability of player A winning the match.
+	MoneylineA float64 `json:"moneylineA"`
+	// TotalLine is the total games line, zero when only the moneyline is known.
+	TotalLine float64 `json:"totalLine"`
+	// TotalOver is the probability of the match going over TotalLine, 0.5 for a main line.
+	TotalOver float64 `json:"totalOver"`
+}
+
+// Result holds the inferred serve probabilities and the market probabilities they reproduce.
+type Result struct {
+	P1         float64 `json:"p1"`
+	P2         float64 `json:"p2"`
+	MoneylineA float64 `json:"moneylineA"`
+	TotalOver  float64 `json:"totalOver"`
+}
+
+// Solve finds the probabilities of each player winning a point on serve that reproduce the
+// target through the exact match model of sim. Without a total line the average serve level of
+// the two players is fixed at AverageServe.
+func Solve(t Target) (Result, error) {
+	if t.BestOf != 3 && t.BestOf != 5 {
+		return Result{}, errors.New("invalid number of sets")
+	}
+	if t.MoneylineA <= 0 || t.MoneylineA >= 1 {
+		return Result{}, errors.New("moneyline probability must be between 0 and 1")
+	}
+	if t.TotalLine == 0 {
+		return solveMoneyline(t, AverageServe)
+	}
+	if t.TotalOver == 0 {
+		t.TotalOver = 0.5
+	}
+	if t.TotalOver <= 0 || t.TotalOver >= 1 {
+		return Result{}, errors.New("total probability must be between 0 and 1")
+	}
+
+	// the total games grow with the serve level, so bisect on it for the total line while
+	// solving the moneyline at every step
+	lo, hi := minServeLevel, maxServeLevel
+	low, err := solveMoneyline(t, lo)
+	if err != nil {
+		return Result{}, err
+	}
+	high, err := solveMoneyline(t, hi)
+	if err != nil {
+		return Result{}, err
+	}
+	if t.TotalOver < low.TotalOver || t.TotalOver > high.TotalOver {
+		return Result{}, fmt.Errorf(
+			"total probability %.3f at %.1f games cannot be reached with this moneyline",
+			t.TotalOver,
+			t.TotalLine,
+		)
+	}
+
+	var res Result
+	for range iterations {
+		mid := (lo + hi) / 2
+		res, err = solveMoneyline(t, mid)
+		if err != nil {
+			return Result{}, err
+		}
+		if res.TotalOver < t.TotalOver {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return res, nil
+}
+
+// solveMoneyline finds the serve probabilities averaging 'level' that reproduce the moneyline.
+func solveMoneyline(t Target, level float64) (Result, error) {
+	maxDiff := 2 * min(level, 1-level)
+	lo, hi := -maxDiff, maxDiff
+	low, err := evaluate(t, level+lo/2, level-lo/2)
+	if err != nil {
+		return Result{}, err
+	}
+	high, err := evaluate(t, level+hi/2, level-hi/2)
+	if err != nil {
+		return Result{}, err
+	}
+	if t.MoneylineA < low.MoneylineA || t.MoneylineA > high.MoneylineA {
+		return Result{}, fmt.Errorf(
+			"moneyline probability %.3f cannot be reached at serve level %.3f",
+			t.MoneylineA,
+			level,
+		)
+	}
+
+	var res Result
+	for range iterations {
+		mid := (lo + hi) / 2
+		res, err = evaluate(t, level+mid/2, level-mid/2)
+		if err != nil {
+			return Result{}, err
+		}
+		if res.MoneylineA < t.MoneylineA {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return res, nil
+}
+
+// evaluate prices the target markets for the given serve probabilities.
+func evaluate(t Target, p1, p2 float64) (Result, error) {
+	outcomes, err := sim.ExactOutcomes(p1, p2, t.BestOf)
+	if err != nil {
+		return Result{}, err
+	}
+
+	res := Result{P1: p1, P2: p2}
+	for _, o := range outcomes {
+		if o.ASets > o.BSets {
+			res.MoneylineA += o.Prob
+		}
+		if t.TotalLine > 0 && float64(o.AGames+o.BGames) > t.TotalLine {
+			res.TotalOver += o.Prob
+		}
+	}
+	return res, nil
+}
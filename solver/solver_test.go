@@ -0,0 +1,68 @@
+package solver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSolve(t *testing.T) {
+	tests := []struct {
+		name   string
+		target Target
+	}{
+		{"BO3 moneyline only", Target{BestOf: 3, MoneylineA: 0.7}},
+		{"BO3 underdog", Target{BestOf: 3, MoneylineA: 0.25}},
+		{"BO3 with total main line", Target{BestOf: 3, MoneylineA: 0.7, TotalLine: 23.5}},
+		{"BO3 with priced total", Target{BestOf: 3, MoneylineA: 0.55, TotalLine: 22.5, TotalOver: 0.6}},
+		{"BO5 with total main line", Target{BestOf: 5, MoneylineA: 0.65, TotalLine: 39.5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Solve(tt.target)
+			require.NoError(t, err)
+			assert.InDelta(t, tt.target.MoneylineA, res.MoneylineA, 1e-6, "moneyline should be reproduced")
+			assert.GreaterOrEqual(t, res.P1, 0.0, "p1 should be >= 0")
+			assert.LessOrEqual(t, res.P1, 1.0, "p1 should be <= 1")
+			assert.GreaterOrEqual(t, res.P2, 0.0, "p2 should be >= 0")
+			assert.LessOrEqual(t, res.P2, 1.0, "p2 should be <= 1")
+			if tt.target.TotalLine == 0 {
+				assert.InDelta(t, AverageServe, (res.P1+res.P2)/2, 1e-6, "serve level should be the average")
+				return
+			}
+			over := tt.target.TotalOver
+			if over == 0 {
+				over = 0.5
+			}
+			assert.InDelta(t, over, res.TotalOver, 1e-6, "total should be reproduced")
+		})
+	}
+}
+
+func TestSolveFavouriteServesBetter(t *testing.T) {
+	res, err := Solve(Target{BestOf: 3, MoneylineA: 0.8})
+	require.NoError(t, err)
+	assert.Greater(t, res.P1, res.P2, "the favourite should win more points on serve")
+}
+
+func TestSolveErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		target Target
+	}{
+		{"Invalid best of", Target{BestOf: 4, MoneylineA: 0.6}},
+		{"Moneyline of zero", Target{BestOf: 3, MoneylineA: 0}},
+		{"Moneyline of one", Target{BestOf: 3, MoneylineA: 1}},
+		{"Total probability out of range", Target{BestOf: 3, MoneylineA: 0.6, TotalLine: 22.5, TotalOver: 1.2}},
+		{"Unreachable total", Target{BestOf: 3, MoneylineA: 0.6, TotalLine: 40.5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Solve(tt.target)
+			assert.Error(t, err, "expected error for %+v", tt.target)
+		})
+	}
+}